@@ -3,12 +3,15 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	AppPort            string
+	JWTSecret          string
 	DbHost             string
 	DbPort             string
 	DbUser             string
@@ -18,6 +21,31 @@ type Config struct {
 	AwsRegion          string
 	AwsAccessKeyId     string
 	AwsSecretAccessKey string
+	S3Endpoint         string
+	S3PublicReadACL    bool
+
+	StorageDriver       string
+	LocalStorageDir     string
+	LocalStorageBaseURL string
+	FileMaxSize         int64
+
+	ThumbnailMaxDimension int
+	ThumbnailQuality      int
+
+	DeletedProductRetention time.Duration
+	ProductPurgeInterval    time.Duration
+
+	ReservationTTL           time.Duration
+	ReservationSweepInterval time.Duration
+
+	NotifierDriver     string
+	AlertWebhookURL    string
+	SMTPHost           string
+	SMTPPort           string
+	SMTPUsername       string
+	SMTPPassword       string
+	SMTPFrom           string
+	StockAlertInterval time.Duration
 }
 
 func LoadConfig() *Config {
@@ -25,19 +53,55 @@ func LoadConfig() *Config {
 		log.Println("No .env file found")
 	}
 
-	return &Config{
-		AppPort: getEnv("APP_PORT", "8080"),
-		DbHost:  getEnv("DB_HOST", "localhost"),
-		DbPort:  getEnv("DB_PORT", "5432"),
-		DbUser:  getEnv("DB_USER", "postgres"),
-		DbPass:  getEnv("DB_PASSWORD", "password"),
-		DbName:  getEnv("DB_NAME", "mydb"),
+	cfg := &Config{
+		AppPort:   getEnv("APP_PORT", "8080"),
+		JWTSecret: getEnv("JWT_SECRET", ""),
+		DbHost:    getEnv("DB_HOST", "localhost"),
+		DbPort:    getEnv("DB_PORT", "5432"),
+		DbUser:    getEnv("DB_USER", "postgres"),
+		DbPass:    getEnv("DB_PASSWORD", "password"),
+		DbName:    getEnv("DB_NAME", "mydb"),
 
 		S3Bucket:           getEnv("AWS_S3_BUCKET_NAME", ""),
 		AwsRegion:          getEnv("AWS_REGION", ""),
 		AwsAccessKeyId:     getEnv("AWS_ACCESS_KEY_ID", ""),
 		AwsSecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		S3Endpoint:         getEnv("AWS_S3_ENDPOINT", ""),
+		S3PublicReadACL:    getEnvBool("AWS_S3_PUBLIC_READ_ACL", false),
+
+		StorageDriver:       getEnv("STORAGE_DRIVER", "local"),
+		LocalStorageDir:     getEnv("LOCAL_STORAGE_DIR", "./uploads"),
+		LocalStorageBaseURL: getEnv("LOCAL_STORAGE_BASE_URL", "http://localhost:8080/uploads"),
+		FileMaxSize:         getEnvInt64("FILE_MAX_SIZE", 100*1024),
+
+		ThumbnailMaxDimension: int(getEnvInt64("THUMBNAIL_MAX_DIMENSION", 256)),
+		ThumbnailQuality:      int(getEnvInt64("THUMBNAIL_JPEG_QUALITY", 70)),
+
+		DeletedProductRetention: getEnvDuration("DELETED_PRODUCT_RETENTION", 30*24*time.Hour),
+		ProductPurgeInterval:    getEnvDuration("PRODUCT_PURGE_INTERVAL", time.Hour),
+
+		ReservationTTL:           getEnvDuration("RESERVATION_TTL", 30*time.Minute),
+		ReservationSweepInterval: getEnvDuration("RESERVATION_SWEEP_INTERVAL", time.Minute),
+
+		NotifierDriver:     getEnv("NOTIFIER_DRIVER", "log"),
+		AlertWebhookURL:    getEnv("ALERT_WEBHOOK_URL", ""),
+		SMTPHost:           getEnv("SMTP_HOST", ""),
+		SMTPPort:           getEnv("SMTP_PORT", "587"),
+		SMTPUsername:       getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:       getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:           getEnv("SMTP_FROM", ""),
+		StockAlertInterval: getEnvDuration("STOCK_ALERT_INTERVAL", 30*time.Second),
+	}
+
+	// Out of range values would only surface as failing uploads
+	if cfg.ThumbnailMaxDimension <= 0 {
+		log.Fatalf("THUMBNAIL_MAX_DIMENSION must be greater than 0, got %d", cfg.ThumbnailMaxDimension)
 	}
+	if cfg.ThumbnailQuality < 1 || cfg.ThumbnailQuality > 100 {
+		log.Fatalf("THUMBNAIL_JPEG_QUALITY must be between 1 and 100, got %d", cfg.ThumbnailQuality)
+	}
+
+	return cfg
 }
 
 func getEnv(key, defaultValue string) string {
@@ -46,3 +110,47 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s, using default %d", key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvBool parses a boolean such as "true" or "1".
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s, using default %t", key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvDuration parses a Go duration such as "720h" or "30m".
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value for %s, using default %s", key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,382 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockKey identifies the advisory lock held while migrating so that
+// two instances starting at once cannot apply the same migration twice.
+const migrationLockKey int64 = 7402166419
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var (
+	ErrMigrationDrift          = errors.New("applied migrations do not match the migration files")
+	ErrForeignSchemaMigrations = errors.New("schema_migrations was created by golang-migrate")
+)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("conflicting names for migration %d: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies up to steps pending migrations in order, or all of them when
+// steps is not positive.
+func (m *Migrator) Up(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn, m.Migrations)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, migration := range m.Migrations {
+			if steps > 0 && count >= steps {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := applyMigration(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back up to steps applied migrations, newest first, or all of
+// them when steps is not positive.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn, m.Migrations)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			if steps > 0 && count >= steps {
+				break
+			}
+			migration := m.Migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := applyMigration(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+}
+
+// Goto migrates up or down until exactly the migrations with a version less
+// than or equal to version are applied.
+func (m *Migrator) Goto(ctx context.Context, version uint) error {
+	known := version == 0
+	for _, migration := range m.Migrations {
+		if migration.Version == version {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("migration version %d does not exist", version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn, m.Migrations)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			migration := m.Migrations[i]
+			if _, ok := applied[migration.Version]; ok && migration.Version > version {
+				if err := applyMigration(ctx, conn, migration, false); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err := applyMigration(ctx, conn, migration, true); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn, m.Migrations)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			appliedAt, ok := applied[migration.Version]
+			statuses = append(statuses, MigrationStatus{
+				Migration: migration,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock, creating the schema_migrations table first if needed.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	if err := checkForeignSchemaMigrations(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// checkForeignSchemaMigrations refuses a schema_migrations table left by
+// golang-migrate, which the migrations were run with before this runner
+// existed. Its (version, dirty) rows say nothing about which of these
+// migrations are applied, and a dirty one means a migration stopped halfway.
+func checkForeignSchemaMigrations(ctx context.Context, conn *sql.Conn) error {
+	var hasDirty bool
+	err := conn.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'schema_migrations' AND column_name = 'dirty'
+		)
+	`).Scan(&hasDirty)
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema_migrations: %v", err)
+	}
+	if !hasDirty {
+		return nil
+	}
+
+	var version uint
+	var dirty bool
+	err = conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations ORDER BY version DESC LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrForeignSchemaMigrations
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	if dirty {
+		return fmt.Errorf("%w: version %d is dirty, repair the schema by hand first", ErrForeignSchemaMigrations, version)
+	}
+	return fmt.Errorf("%w: it is at version %d", ErrForeignSchemaMigrations, version)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn, migrations []Migration) (map[uint]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[uint]time.Time)
+	names := make(map[uint]string)
+	for rows.Next() {
+		var version uint
+		var name string
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &name, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+		names[version] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, checkApplied(migrations, names)
+}
+
+// checkApplied compares the migrations recorded as applied, by version and
+// name, with the ones embedded in the binary. A version that is unknown or
+// recorded under another name means a shipped migration was renumbered or
+// the database comes from a different build, and migrating either way would
+// run the wrong scripts.
+func checkApplied(migrations []Migration, applied map[uint]string) error {
+	known := make(map[uint]string, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = migration.Name
+	}
+
+	versions := make([]uint, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for _, version := range versions {
+		name, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: %d_%s is applied but has no migration file", ErrMigrationDrift, version, applied[version])
+		}
+		if name != applied[version] {
+			return fmt.Errorf("%w: %d is applied as %s but the migration file is %s", ErrMigrationDrift, version, applied[version], name)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs one direction of a migration and records the result in
+// schema_migrations inside a single transaction.
+func applyMigration(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	script, direction := migration.Down, "down"
+	if up {
+		script, direction = migration.Up, "up"
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %v", migration.Version, migration.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %v", migration.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %v", migration.Version, err)
+	}
+
+	log.Printf("Migrated %d_%s %s", migration.Version, migration.Name, direction)
+	return nil
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/lib/pq"
+)
+
+func TestLoadMigrationsOrder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/000010_add_index.up.sql":      {Data: []byte("CREATE INDEX")},
+		"migrations/000010_add_index.down.sql":    {Data: []byte("DROP INDEX")},
+		"migrations/000002_add_column.up.sql":     {Data: []byte("ALTER TABLE a ADD")},
+		"migrations/000002_add_column.down.sql":   {Data: []byte("ALTER TABLE a DROP")},
+		"migrations/000001_create_table.up.sql":   {Data: []byte("CREATE TABLE a")},
+		"migrations/000001_create_table.down.sql": {Data: []byte("DROP TABLE a")},
+		"migrations/README.md":                    {Data: []byte("not a migration")},
+	}
+
+	migrations, err := loadMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	// Versions sort numerically, not by file name
+	want := []Migration{
+		{Version: 1, Name: "create_table", Up: "CREATE TABLE a", Down: "DROP TABLE a"},
+		{Version: 2, Name: "add_column", Up: "ALTER TABLE a ADD", Down: "ALTER TABLE a DROP"},
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX", Down: "DROP INDEX"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("loadMigrations: got %d migrations, want %d", len(migrations), len(want))
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Fatalf("migration %d: got %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing down": {
+			"migrations/000001_create_table.up.sql": {Data: []byte("CREATE TABLE a")},
+		},
+		"conflicting names": {
+			"migrations/000001_create_table.up.sql":   {Data: []byte("CREATE TABLE a")},
+			"migrations/000001_create_other.down.sql": {Data: []byte("DROP TABLE a")},
+		},
+	} {
+		if _, err := loadMigrations(fsys, "migrations"); err == nil {
+			t.Fatalf("loadMigrations with %s: got nil error", name)
+		}
+	}
+}
+
+// shippedMigrations pins the start of the sha256 of every up script that has
+// been released. Applied migrations are never run again, so changing one
+// leaves databases that ran the old script out of step with new ones; fix a
+// shipped migration by appending another. Down scripts may still be fixed.
+var shippedMigrations = map[uint]string{
+	1:  "ce2d0e04fcae5b8e", // create_users_table
+	2:  "feb569d16d3bfb3a", // create_files_table
+	3:  "cdd9a641c65e6279", // create_product_categories_table
+	4:  "963cd62fcd19300d", // create_products_table
+	5:  "7442570a1b207327", // add_bank_account_to_users
+	6:  "4adac6a64e26cfa7", // create_purchases_table
+	7:  "95189d37f20ee6fc", // create_sales_table
+	8:  "07c4f887e6d0b5dd", // add_file_id_to_users
+	9:  "0d1a5c5bea6d8e0b", // add_version_to_products
+	10: "7b6af7505c691652", // add_search_to_products
+	11: "deb46007230d03d2", // add_created_at_id_index_to_products
+	12: "8acb27706f6d14af", // add_is_active_to_product_categories
+	13: "46b442ca078a78cd", // add_is_admin_to_users
+	14: "2c2ab7d499e9b1fc", // add_parent_id_to_product_categories
+	15: "b1f3b2388c5238ad", // create_product_files_table
+	16: "3817faa95d831fec", // create_product_variants_table
+	17: "8db81bb4966e3b78", // add_soft_delete_to_products
+	18: "2e199265e4a71c5a", // create_stock_movements_table
+	19: "ec7cfa06a50cbd66", // add_stock_reservations
+	20: "03cf8991b3155f37", // create_stock_alerts_table
+	21: "122575875d3f255a", // add_unique_product_sku
+	22: "6e57a8f9a03807b9", // add_public_id_to_purchases
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != uint(i+1) {
+			t.Fatalf("migration %d_%s: want version %d, versions must have no gaps", migration.Version, migration.Name, i+1)
+		}
+
+		sum := sha256.Sum256([]byte(migration.Up))
+		pinned, ok := shippedMigrations[migration.Version]
+		if ok && !strings.HasPrefix(hex.EncodeToString(sum[:]), pinned) {
+			t.Fatalf("migration %d_%s: the up script of a shipped migration changed, append a new migration instead", migration.Version, migration.Name)
+		}
+	}
+	if len(migrations) < len(shippedMigrations) {
+		t.Fatalf("got %d migrations, want at least the %d shipped", len(migrations), len(shippedMigrations))
+	}
+}
+
+func TestCheckApplied(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "create_users_table"},
+		{Version: 2, Name: "create_files_table"},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		applied map[uint]string
+		drift   bool
+	}{
+		{"nothing applied", map[uint]string{}, false},
+		{"some applied", map[uint]string{1: "create_users_table"}, false},
+		{"all applied", map[uint]string{1: "create_users_table", 2: "create_files_table"}, false},
+		{"renumbered", map[uint]string{1: "create_users_table", 2: "create_users_table"}, true},
+		{"unknown version", map[uint]string{1: "create_users_table", 3: "add_column"}, true},
+	} {
+		err := checkApplied(migrations, tc.applied)
+		if tc.drift != errors.Is(err, ErrMigrationDrift) || (!tc.drift && err != nil) {
+			t.Fatalf("checkApplied %s: got %v, want drift %v", tc.name, err, tc.drift)
+		}
+	}
+}
+
+// TestMigratorPostgres migrates a database it may wipe, given as a lib/pq
+// connection string in TEST_DATABASE_URL, all the way up and down.
+func TestMigratorPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	ctx := context.Background()
+	reset := func() {
+		t.Helper()
+		if _, err := conn.ExecContext(ctx, "DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+			t.Fatalf("failed to reset schema: %v", err)
+		}
+	}
+
+	migrator, err := NewMigrator(conn)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+
+	reset()
+	if err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := migrator.Down(ctx, 0); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("Up after Down: %v", err)
+	}
+
+	// A version recorded under another name is drift
+	if _, err := conn.ExecContext(ctx, "UPDATE schema_migrations SET name = 'create_products_table' WHERE version = 1"); err != nil {
+		t.Fatalf("failed to rename migration: %v", err)
+	}
+	if err := migrator.Up(ctx, 0); !errors.Is(err, ErrMigrationDrift) {
+		t.Fatalf("Up with a renamed migration: got %v, want ErrMigrationDrift", err)
+	}
+
+	// golang-migrate's table is refused, dirty or not
+	for _, dirty := range []bool{false, true} {
+		reset()
+		if _, err := conn.ExecContext(ctx, "CREATE TABLE schema_migrations (version BIGINT PRIMARY KEY, dirty BOOLEAN NOT NULL)"); err != nil {
+			t.Fatalf("failed to create golang-migrate table: %v", err)
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES (4, $1)", dirty); err != nil {
+			t.Fatalf("failed to record golang-migrate version: %v", err)
+		}
+		err := migrator.Up(ctx, 0)
+		if !errors.Is(err, ErrForeignSchemaMigrations) || strings.Contains(err.Error(), "dirty") != dirty {
+			t.Fatalf("Up over golang-migrate's table with dirty %v: got %v", dirty, err)
+		}
+	}
+	reset()
+}
@@ -1 +0,0 @@
-DROP TABLE IF EXISTS products;
\ No newline at end of file
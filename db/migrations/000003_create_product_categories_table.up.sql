@@ -0,0 +1,11 @@
+CREATE TABLE product_categories (
+    id SERIAL PRIMARY KEY,
+    type VARCHAR(32) NOT NULL UNIQUE
+);
+
+INSERT INTO product_categories (type) VALUES
+    ('Food'),
+    ('Beverage'),
+    ('Clothes'),
+    ('Furniture'),
+    ('Tools');
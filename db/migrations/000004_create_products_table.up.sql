@@ -0,0 +1,17 @@
+CREATE TABLE products (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL,
+    category VARCHAR(32) NOT NULL REFERENCES product_categories(type) ON UPDATE CASCADE,
+    qty INT NOT NULL CHECK (qty >= 0),
+    price DECIMAL(10, 2) NOT NULL CHECK (price >= 100),
+    sku VARCHAR(32) NOT NULL,
+    file_id UUID NOT NULL REFERENCES files(id),
+    user_id INT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_products_name ON products (name);
+CREATE INDEX idx_products_category ON products (category);
+CREATE INDEX idx_products_file_id ON products (file_id);
+CREATE INDEX idx_products_user_id ON products (user_id);
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS bank_account_name,
+    DROP COLUMN IF EXISTS bank_account_holder,
+    DROP COLUMN IF EXISTS bank_account_number;
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN bank_account_name VARCHAR(32) NOT NULL DEFAULT '',
+    ADD COLUMN bank_account_holder VARCHAR(32) NOT NULL DEFAULT '',
+    ADD COLUMN bank_account_number VARCHAR(32) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS purchase_items;
+DROP TABLE IF EXISTS purchases;
@@ -0,0 +1,22 @@
+CREATE TABLE purchases (
+    id SERIAL PRIMARY KEY,
+    sender_name VARCHAR(55) NOT NULL,
+    sender_contact_type VARCHAR(8) NOT NULL CHECK (sender_contact_type IN ('email', 'phone')),
+    sender_contact_detail VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid')),
+    total_price DECIMAL(12, 2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE purchase_items (
+    id SERIAL PRIMARY KEY,
+    purchase_id INT NOT NULL REFERENCES purchases(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    seller_id INT NOT NULL REFERENCES users(id),
+    qty INT NOT NULL CHECK (qty >= 1),
+    price DECIMAL(10, 2) NOT NULL
+);
+
+CREATE INDEX idx_purchase_items_purchase_id ON purchase_items (purchase_id);
+CREATE INDEX idx_purchase_items_product_id ON purchase_items (product_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sales;
+DROP TABLE IF EXISTS purchase_payments;
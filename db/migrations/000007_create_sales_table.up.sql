@@ -0,0 +1,19 @@
+CREATE TABLE purchase_payments (
+    id SERIAL PRIMARY KEY,
+    purchase_id INT NOT NULL REFERENCES purchases(id) ON DELETE CASCADE,
+    seller_id INT NOT NULL REFERENCES users(id),
+    file_id UUID NOT NULL REFERENCES files(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (purchase_id, seller_id)
+);
+
+CREATE TABLE sales (
+    id SERIAL PRIMARY KEY,
+    purchase_id INT NOT NULL REFERENCES purchases(id),
+    product_id INT NOT NULL REFERENCES products(id),
+    qty INT NOT NULL CHECK (qty >= 1),
+    price DECIMAL(10, 2) NOT NULL,
+    sold_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sales_product_id_sold_at ON sales (product_id, sold_at);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN file_id UUID REFERENCES files(id);
@@ -0,0 +1 @@
+ALTER TABLE product_categories DROP COLUMN IF EXISTS is_active;
@@ -0,0 +1 @@
+ALTER TABLE product_categories ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_product_categories_parent_id;
+ALTER TABLE product_categories DROP COLUMN IF EXISTS parent_id;
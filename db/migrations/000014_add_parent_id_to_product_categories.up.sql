@@ -0,0 +1,4 @@
+ALTER TABLE product_categories ADD COLUMN parent_id INT REFERENCES product_categories(id);
+ALTER TABLE product_categories ADD CONSTRAINT product_categories_parent_id_check CHECK (parent_id <> id);
+
+CREATE INDEX idx_product_categories_parent_id ON product_categories (parent_id);
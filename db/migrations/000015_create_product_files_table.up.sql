@@ -0,0 +1,11 @@
+CREATE TABLE product_files (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    file_id UUID NOT NULL REFERENCES files(id),
+    position INT NOT NULL CHECK (position >= 0),
+    PRIMARY KEY (product_id, position)
+);
+
+CREATE INDEX idx_product_files_file_id ON product_files (file_id);
+
+INSERT INTO product_files (product_id, file_id, position)
+SELECT id, file_id, 0 FROM products;
@@ -0,0 +1,3 @@
+ALTER TABLE purchase_items DROP COLUMN IF EXISTS variant_id;
+DROP TABLE IF EXISTS product_variants;
+ALTER TABLE products DROP COLUMN IF EXISTS option_names;
@@ -0,0 +1,16 @@
+ALTER TABLE products ADD COLUMN option_names TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    option_values TEXT[] NOT NULL,
+    sku VARCHAR(32) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL CHECK (price >= 100),
+    qty INT NOT NULL CHECK (qty >= 0),
+    file_id UUID REFERENCES files(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (product_id, option_values)
+);
+
+ALTER TABLE purchase_items ADD COLUMN variant_id INT REFERENCES product_variants(id);
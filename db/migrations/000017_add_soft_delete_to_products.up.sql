@@ -0,0 +1,4 @@
+ALTER TABLE products ADD COLUMN is_archived BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_products_deleted_at ON products (deleted_at) WHERE deleted_at IS NOT NULL;
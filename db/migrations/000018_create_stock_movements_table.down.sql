@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS stock_movements;
+ALTER TABLE product_variants ADD CONSTRAINT product_variants_qty_check CHECK (qty >= 0);
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_qty_check;
+ALTER TABLE products ADD CONSTRAINT products_qty_check CHECK (qty >= 0);
+ALTER TABLE products DROP COLUMN IF EXISTS allow_negative_stock;
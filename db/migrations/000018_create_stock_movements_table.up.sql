@@ -0,0 +1,29 @@
+ALTER TABLE products ADD COLUMN allow_negative_stock BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_qty_check;
+ALTER TABLE products ADD CONSTRAINT products_qty_check CHECK (qty >= 0 OR allow_negative_stock);
+ALTER TABLE product_variants DROP CONSTRAINT IF EXISTS product_variants_qty_check;
+
+CREATE TABLE stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id INT REFERENCES product_variants(id) ON DELETE SET NULL,
+    reason VARCHAR(16) NOT NULL CHECK (reason IN ('sale', 'restock', 'adjustment', 'return', 'correction')),
+    delta INT NOT NULL CHECK (delta <> 0),
+    qty_after INT NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    purchase_id INT REFERENCES purchases(id),
+    user_id INT REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stock_movements_product_id ON stock_movements (product_id, id DESC);
+
+INSERT INTO stock_movements (product_id, reason, delta, qty_after, note)
+SELECT id, 'correction', qty, qty, 'opening balance'
+FROM products
+WHERE qty <> 0 AND NOT EXISTS (SELECT 1 FROM product_variants WHERE product_variants.product_id = products.id);
+
+INSERT INTO stock_movements (product_id, variant_id, reason, delta, qty_after, note)
+SELECT product_id, id, 'correction', qty, qty, 'opening balance'
+FROM product_variants
+WHERE qty <> 0;
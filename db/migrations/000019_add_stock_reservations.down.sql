@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_purchases_reserved_until;
+-- Expired purchases already gave their stock back and the older schema has
+-- no status for them; making them pending again would revive purchases that
+-- hold nothing
+DELETE FROM purchases WHERE status = 'expired';
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+ALTER TABLE purchases ADD CONSTRAINT purchases_status_check CHECK (status IN ('pending', 'paid'));
+ALTER TABLE purchases DROP COLUMN IF EXISTS reserved_until;
+
+ALTER TABLE product_variants DROP COLUMN IF EXISTS reserved_qty;
+ALTER TABLE products DROP COLUMN IF EXISTS reserved_qty;
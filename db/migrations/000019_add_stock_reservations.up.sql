@@ -0,0 +1,8 @@
+ALTER TABLE products ADD COLUMN reserved_qty INT NOT NULL DEFAULT 0 CHECK (reserved_qty >= 0);
+ALTER TABLE product_variants ADD COLUMN reserved_qty INT NOT NULL DEFAULT 0 CHECK (reserved_qty >= 0);
+
+ALTER TABLE purchases ADD COLUMN reserved_until TIMESTAMP;
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+ALTER TABLE purchases ADD CONSTRAINT purchases_status_check CHECK (status IN ('pending', 'paid', 'expired'));
+
+CREATE INDEX idx_purchases_reserved_until ON purchases (reserved_until) WHERE status = 'pending';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS stock_alerts;
+DROP INDEX IF EXISTS idx_products_low_stock;
+ALTER TABLE products DROP COLUMN IF EXISTS low_stock_alerted;
+ALTER TABLE products DROP COLUMN IF EXISTS low_stock_threshold;
@@ -0,0 +1,16 @@
+ALTER TABLE products ADD COLUMN low_stock_threshold INT NOT NULL DEFAULT 0 CHECK (low_stock_threshold >= 0);
+ALTER TABLE products ADD COLUMN low_stock_alerted BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_products_low_stock ON products (user_id) WHERE low_stock_threshold > 0 AND qty <= low_stock_threshold;
+
+CREATE TABLE stock_alerts (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    qty INT NOT NULL,
+    threshold INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX idx_stock_alerts_pending ON stock_alerts (id) WHERE delivered_at IS NULL;
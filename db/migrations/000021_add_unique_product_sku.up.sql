@@ -0,0 +1,21 @@
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('user %s has sku %L on products %s', user_id, sku, ids), '; ')
+    INTO duplicates
+    FROM (
+        SELECT user_id, sku, string_agg(id::text, ', ' ORDER BY id) AS ids
+        FROM products
+        WHERE deleted_at IS NULL
+        GROUP BY user_id, sku
+        HAVING COUNT(*) > 1
+    ) AS duplicated;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'products share a sku: %', duplicates
+            USING HINT = 'Give each of these products a distinct sku, or delete the extras, then migrate again. They are listed by: SELECT user_id, sku, array_agg(id ORDER BY id) FROM products WHERE deleted_at IS NULL GROUP BY user_id, sku HAVING COUNT(*) > 1';
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX idx_products_user_id_sku ON products (user_id, sku) WHERE deleted_at IS NULL;
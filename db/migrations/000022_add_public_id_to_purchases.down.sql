@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_purchases_public_id;
+ALTER TABLE purchases DROP COLUMN IF EXISTS public_id;
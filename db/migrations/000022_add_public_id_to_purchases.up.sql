@@ -0,0 +1,4 @@
+-- Purchases are confirmed without logging in, so the API addresses them by an
+-- id that can't be guessed from the ones before it
+ALTER TABLE purchases ADD COLUMN public_id UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX idx_purchases_public_id ON purchases (public_id);
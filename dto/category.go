@@ -0,0 +1,29 @@
+package dto
+
+// Category names are joined with commas when filtering products, so they
+// may not contain one.
+type CreateCategoryRequest struct {
+	Type     string `json:"type" validate:"required,max=32,excludes=0x2C"` // Required, maxLength: 32, no commas
+	ParentID string `json:"parentId" validate:"omitempty,numeric"`         // Optional, categoryId of the parent
+}
+
+// UpdateCategoryRequest renames, moves and/or (de)activates a category; nil
+// fields are left unchanged
+type UpdateCategoryRequest struct {
+	Type     *string `json:"type" validate:"omitempty,min=1,max=32,excludes=0x2C"` // Optional, maxLength: 32, no commas
+	ParentID *string `json:"parentId" validate:"omitempty,numeric|len=0"`          // Optional, categoryId of the new parent, "" for top level
+	IsActive *bool   `json:"isActive"`                                             // Optional, inactive categories can't be assigned to products
+}
+
+type CategoryResponse struct {
+	CategoryID string `json:"categoryId"` // string
+	Type       string `json:"type"`       // category name, as used in products
+	ParentID   string `json:"parentId"`   // categoryId of the parent, "" for top level
+	IsActive   bool   `json:"isActive"`   // boolean
+}
+
+type CategoryTreeResponse struct {
+	CategoryID string                 `json:"categoryId"` // string
+	Type       string                 `json:"type"`       // category name, as used in products
+	Children   []CategoryTreeResponse `json:"children"`   // subcategories, ordered by categoryId
+}
@@ -0,0 +1,7 @@
+package dto
+
+type FileResponse struct {
+	FileID           string `json:"fileId"`           // string
+	FileUri          string `json:"fileUri"`          // uploaded file URI
+	FileThumbnailUri string `json:"fileThumbnailUri"` // thumbnail file URI
+}
@@ -0,0 +1,21 @@
+package dto
+
+type ImportRowResponse struct {
+	Line      int      `json:"line"`      // line of the row in the file, the csv header being line 1
+	SKU       string   `json:"sku"`       // string
+	Action    string   `json:"action"`    // create or update, what the row does or would do, "" when it failed
+	ProductID string   `json:"productId"` // product created or updated, "" when nothing was written for a new product
+	Errors    []string `json:"errors"`    // why the row failed, empty when it did not
+}
+
+// ImportResponse is what POST /v1/product/import responds with. Either every
+// row is imported or none is, so failed rows leave created and updated as
+// what would have happened.
+type ImportResponse struct {
+	DryRun   bool                `json:"dryRun"`   // nothing was written because dryRun=true
+	Imported bool                `json:"imported"` // the rows were written
+	Created  int                 `json:"created"`  // rows that create a product
+	Updated  int                 `json:"updated"`  // rows that update the product with their sku
+	Failed   int                 `json:"failed"`   // rows with errors
+	Rows     []ImportRowResponse `json:"rows"`     // one per row, in file order
+}
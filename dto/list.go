@@ -0,0 +1,12 @@
+package dto
+
+type ListMeta struct {
+	Total  int `json:"total"`  // number of items matching the filters
+	Limit  int `json:"limit"`  // page size
+	Offset int `json:"offset"` // 0 when paging by cursor
+}
+
+type ListLinks struct {
+	Next *string `json:"next"` // null on the last page
+	Prev *string `json:"prev"` // null on the first page and when paging by cursor
+}
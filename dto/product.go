@@ -3,42 +3,106 @@ package dto
 import "time"
 
 type CreateProductRequest struct {
-	Name     string `json:"name" validate:"required,min=4,max=32"` // Required, minLength: 4, maxLength: 32
-	Category string `json:"category" validate:"required"`          // Required, should be an enum of product category types
-	Qty      int    `json:"qty" validate:"required,min=1"`         // Required, min: 1
-	Price    int    `json:"price" validate:"required,min=100"`     // Required, min: 100
-	SKU      string `json:"sku" validate:"required,max=32"`        // Required, maxLength: 32
-	FileID   string `json:"fileId" validate:"required"`            // Required, should be a valid fileId
+	Name               string                 `json:"name" validate:"required,min=4,max=32"`                                               // Required, minLength: 4, maxLength: 32
+	Category           string                 `json:"category" validate:"required"`                                                        // Required, should be an enum of product category types
+	Qty                int                    `json:"qty" validate:"required_without=Variants,excluded_with=Variants,omitempty,min=1"`     // Required unless variants are set, min: 1
+	Price              int                    `json:"price" validate:"required_without=Variants,excluded_with=Variants,omitempty,min=100"` // Required unless variants are set, min: 100
+	SKU                string                 `json:"sku" validate:"required,max=32"`                                                      // Required, maxLength: 32
+	FileID             string                 `json:"fileId" validate:"required_without=FileIDs,omitempty,uuid"`                           // Required unless fileIds is set, should be a valid fileId
+	FileIDs            []string               `json:"fileIds" validate:"omitempty,max=10,unique,dive,required,uuid"`                       // Optional, gallery in display order, the first is the primary image
+	Options            []string               `json:"options" validate:"required_with=Variants,max=3,unique,dive,required,max=16"`         // Required with variants, option names such as size and color
+	Variants           []CreateVariantRequest `json:"variants" validate:"omitempty,max=100,dive"`                                          // Optional, the product's qty and price then come from its variants
+	AllowNegativeStock bool                   `json:"allowNegativeStock"`                                                                  // Optional, lets sales and adjustments take qty below zero
+	LowStockThreshold  int                    `json:"lowStockThreshold" validate:"min=0"`                                                  // Optional, alert the seller once qty falls to it, 0 never alerts
+}
+
+type CreateVariantRequest struct {
+	Options map[string]string `json:"options" validate:"required,dive,keys,required,endkeys,required,max=32"` // Required, one value per product option, e.g. {"size": "M"}
+	SKU     string            `json:"sku" validate:"required,max=32"`                                         // Required, maxLength: 32
+	Price   int               `json:"price" validate:"required,min=100"`                                      // Required, min: 100
+	Qty     int               `json:"qty" validate:"min=0"`                                                   // Optional, min: 0
+	FileID  string            `json:"fileId" validate:"omitempty,uuid"`                                       // Optional, should be a valid fileId
+}
+
+// UpdateVariantRequest is a partial update like UpdateProductRequest
+type UpdateVariantRequest struct {
+	SKU    *string `json:"sku,omitempty" validate:"omitempty,min=1,max=32"` // Optional, minLength: 1, maxLength: 32
+	Price  *int    `json:"price,omitempty" validate:"omitempty,min=100"`    // Optional, min: 100
+	Qty    *int    `json:"qty,omitempty" validate:"omitempty,min=0"`        // Optional, min: 0
+	FileID *string `json:"fileId,omitempty" validate:"omitempty,eq=|uuid"`  // Optional, should be a valid fileId, "" removes the image
 }
 
 type ProductResponse struct {
-	ProductID        string    `json:"productId"`        // string | Use any id you want
-	Name             string    `json:"name"`             // string
-	Category         string    `json:"category"`         // string
-	Qty              int       `json:"qty"`              // number
-	Price            float64   `json:"price"`            // number
-	SKU              string    `json:"sku"`              // string
-	FileID           string    `json:"fileId"`           // string
-	FileUri          string    `json:"fileUri"`          // related file URI
-	FileThumbnailUri string    `json:"fileThumbnailUri"` // related file thumbnail URI
-	CreatedAt        time.Time `json:"createdAt"`        // timestamp
-	UpdatedAt        time.Time `json:"updatedAt"`        // timestamp
+	ProductID          string            `json:"productId"`          // string | Use any id you want
+	Name               string            `json:"name"`               // string
+	Category           string            `json:"category"`           // string
+	Qty                int               `json:"qty"`                // on hand
+	AvailableQty       int               `json:"availableQty"`       // on hand less what unpaid purchases hold
+	Price              float64           `json:"price"`              // number
+	SKU                string            `json:"sku"`                // string
+	FileID             string            `json:"fileId"`             // string
+	FileUri            string            `json:"fileUri"`            // related file URI
+	FileThumbnailUri   string            `json:"fileThumbnailUri"`   // related file thumbnail URI
+	Images             []FileResponse    `json:"images"`             // gallery in display order, the first is the primary image above
+	MinPrice           float64           `json:"minPrice"`           // cheapest variant, or price
+	MaxPrice           float64           `json:"maxPrice"`           // dearest variant, or price
+	Options            []string          `json:"options"`            // option names the variants differ by
+	Variants           []VariantResponse `json:"variants"`           // empty for products without variants
+	Archived           bool              `json:"archived"`           // hidden from everyone but the owner
+	AllowNegativeStock bool              `json:"allowNegativeStock"` // sales and adjustments may take qty below zero
+	LowStockThreshold  int               `json:"lowStockThreshold"`  // the seller is alerted once qty falls to it, 0 when off
+	UserID             string            `json:"userId"`             // owner of the product
+	CreatedAt          time.Time         `json:"createdAt"`          // timestamp
+	UpdatedAt          time.Time         `json:"updatedAt"`          // timestamp
 }
 
 type FilterProductRequest struct {
-	Limit     int    `form:"limit" binding:"omitempty"`
-	Offset    int    `form:"offset" binding:"omitempty"`
-	Category  string `form:"category" binding:"omitempty,oneof=Food Beverage Clothes Furniture Tools"`
-	ProductId string `form:"productId " binding:"omitempty"`
-	SKU       string `form:"sku" binding:"omitempty"`
-	SortBy    string `form:"sortBy" binding:"omitempty,oneof=createdAt updatedAt"`
+	Limit         int      `form:"limit" binding:"omitempty"`
+	Offset        int      `form:"offset" binding:"omitempty"`
+	Category      []string `form:"category" binding:"omitempty"` // Repeatable, e.g. category=Food&category=Beverage
+	ProductId     string   `form:"productId" binding:"omitempty"`
+	SKU           string   `form:"sku" binding:"omitempty"`
+	UserID        string   `form:"userId" binding:"omitempty,numeric"`
+	Q             string   `form:"q" binding:"omitempty,max=64"`
+	MinPrice      string   `form:"minPrice" binding:"omitempty"`      // Integer, inclusive
+	MaxPrice      string   `form:"maxPrice" binding:"omitempty"`      // Integer, inclusive
+	InStock       string   `form:"inStock" binding:"omitempty"`       // true or false
+	CreatedAfter  string   `form:"createdAfter" binding:"omitempty"`  // RFC 3339 timestamp, inclusive
+	CreatedBefore string   `form:"createdBefore" binding:"omitempty"` // RFC 3339 timestamp, exclusive
+	SortBy        string   `form:"sortBy" binding:"omitempty"`
+	Cursor        string   `form:"cursor" binding:"omitempty"` // Opaque, from links.next; an empty cursor= starts keyset paging
+}
+
+type VariantResponse struct {
+	VariantID        string            `json:"variantId"`        // string
+	Options          map[string]string `json:"options"`          // option name to value
+	SKU              string            `json:"sku"`              // string
+	Price            float64           `json:"price"`            // number
+	Qty              int               `json:"qty"`              // on hand
+	AvailableQty     int               `json:"availableQty"`     // on hand less what unpaid purchases hold
+	FileID           string            `json:"fileId"`           // string, "" when the variant has no image
+	FileUri          string            `json:"fileUri"`          // related file URI
+	FileThumbnailUri string            `json:"fileThumbnailUri"` // related file thumbnail URI
+}
+
+// ProductListResponse is the envelope GET /v1/product responds with
+type ProductListResponse struct {
+	Data  []ProductResponse `json:"data"`
+	Meta  ListMeta          `json:"meta"`
+	Links ListLinks         `json:"links"`
 }
 
+// UpdateProductRequest is a partial update: nil fields are left unchanged
+// and validation only applies to the fields that were sent.
 type UpdateProductRequest struct {
-	Name     string `json:"name" validate:"required,min=4,max=32"` // Required, minLength: 4, maxLength: 32
-	Category string `json:"category" validate:"required"`          // Required, should be an enum of product category types
-	Qty      int    `json:"qty" validate:"required,min=1"`         // Required, min: 1
-	Price    int    `json:"price" validate:"required,min=100"`     // Required, min: 100
-	SKU      string `json:"sku" validate:"required,max=32"`        // Required, maxLength: 32
-	FileID   string `json:"fileId" validate:"required"`            // Required, should be a valid fileId
+	Name               *string   `json:"name,omitempty" validate:"omitempty,min=4,max=32"`                              // Optional, minLength: 4, maxLength: 32
+	Category           *string   `json:"category,omitempty" validate:"omitempty"`                                       // Optional, should be an enum of product category types
+	Qty                *int      `json:"qty,omitempty" validate:"omitempty,min=0"`                                      // Optional, min: 0
+	Price              *int      `json:"price,omitempty" validate:"omitempty,min=100"`                                  // Optional, min: 100
+	SKU                *string   `json:"sku,omitempty" validate:"omitempty,min=1,max=32"`                               // Optional, minLength: 1, maxLength: 32
+	FileID             *string   `json:"fileId,omitempty" validate:"omitnil,uuid"`                                      // Optional, replaces the primary image only
+	FileIDs            *[]string `json:"fileIds,omitempty" validate:"omitempty,min=1,max=10,unique,dive,required,uuid"` // Optional, replaces the whole gallery
+	Archived           *bool     `json:"archived,omitempty"`                                                            // Optional, archived products are only listed to their owner
+	AllowNegativeStock *bool     `json:"allowNegativeStock,omitempty"`                                                  // Optional, lets sales and adjustments take qty below zero
+	LowStockThreshold  *int      `json:"lowStockThreshold,omitempty" validate:"omitempty,min=0"`                        // Optional, 0 turns low stock alerts off
 }
@@ -0,0 +1,43 @@
+package dto
+
+import "time"
+
+type PurchasedItemRequest struct {
+	ProductID string `json:"productId" validate:"required,numeric"`  // Required, should be a valid productId
+	VariantID string `json:"variantId" validate:"omitempty,numeric"` // Required for products with variants
+	Qty       int    `json:"qty" validate:"required,min=1"`          // Required, min: 1
+}
+
+type CreatePurchaseRequest struct {
+	PurchasedItems      []PurchasedItemRequest `json:"purchasedItems" validate:"required,min=1,dive"`           // Required, at least one item
+	SenderName          string                 `json:"senderName" validate:"required,min=4,max=55"`             // Required, minLength: 4, maxLength: 55
+	SenderContactType   string                 `json:"senderContactType" validate:"required,oneof=email phone"` // Required, enum of email or phone
+	SenderContactDetail string                 `json:"senderContactDetail" validate:"required"`                 // Required, email or phone depending on senderContactType
+}
+
+type ConfirmPurchaseRequest struct {
+	FileIDs []string `json:"fileIds" validate:"required,min=1,dive,required,uuid"` // Required, one payment proof fileId per seller
+}
+
+type PurchasedItemResponse struct {
+	ProductResponse
+	Variant      *VariantResponse `json:"variant"`      // the variant bought, null for products without variants
+	PurchasedQty int              `json:"purchasedQty"` // number of units bought
+}
+
+type PaymentDetailResponse struct {
+	SellerID          string  `json:"sellerId"`          // string
+	BankAccountName   string  `json:"bankAccountName"`   // string
+	BankAccountHolder string  `json:"bankAccountHolder"` // string
+	BankAccountNumber string  `json:"bankAccountNumber"` // string
+	TotalPrice        float64 `json:"totalPrice"`        // amount to transfer to this seller
+}
+
+type PurchaseResponse struct {
+	PurchaseID     string                  `json:"purchaseId"`     // string
+	Status         string                  `json:"status"`         // pending, paid or expired
+	PurchasedItems []PurchasedItemResponse `json:"purchasedItems"` // items in the purchase
+	TotalPrice     float64                 `json:"totalPrice"`     // sum over all sellers
+	PaymentDetails []PaymentDetailResponse `json:"paymentDetails"` // one entry per seller
+	ReservedUntil  *time.Time              `json:"reservedUntil"`  // pay before then or the stock is released, null once it is not held
+}
@@ -0,0 +1,39 @@
+package dto
+
+import "time"
+
+// StockAdjustmentRequest records a stock movement other than a sale, which
+// only purchases make.
+type StockAdjustmentRequest struct {
+	Delta     int    `json:"delta" validate:"required"`                                             // Required, non-zero, negative takes stock out
+	Reason    string `json:"reason" validate:"required,oneof=restock adjustment return correction"` // Required, restock and return must add stock
+	VariantID string `json:"variantId" validate:"omitempty,numeric"`                                // Required for products with variants
+	Note      string `json:"note" validate:"max=255"`                                               // Optional, maxLength: 255
+}
+
+type StockMovementResponse struct {
+	MovementID string    `json:"movementId"` // string
+	ProductID  string    `json:"productId"`  // string
+	VariantID  string    `json:"variantId"`  // "" for products without variants
+	Reason     string    `json:"reason"`     // sale, restock, adjustment, return or correction
+	Delta      int       `json:"delta"`      // change in stock, negative when taken out
+	QtyAfter   int       `json:"qtyAfter"`   // stock of the product, or variant, after the movement
+	Note       string    `json:"note"`       // string
+	PurchaseID string    `json:"purchaseId"` // "" unless a sale
+	UserID     string    `json:"userId"`     // "" for sales
+	CreatedAt  time.Time `json:"createdAt"`  // timestamp
+}
+
+// StockHistoryResponse is the envelope GET /v1/product/:productId/stock/history
+// responds with, newest movement first
+type StockHistoryResponse struct {
+	Data []StockMovementResponse `json:"data"`
+	Meta ListMeta                `json:"meta"`
+}
+
+// LowStockResponse is the envelope GET /v1/product/low-stock responds with,
+// products with the least stock first
+type LowStockResponse struct {
+	Data []ProductResponse `json:"data"`
+	Meta ListMeta          `json:"meta"`
+}
@@ -0,0 +1,61 @@
+package dto
+
+import "strings"
+
+// Normalizer is implemented by requests that clean up their fields before
+// they are validated.
+type Normalizer interface {
+	Normalize()
+}
+
+// NormalizeEmail trims and lowercases an email so that one address always
+// maps to one account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+type EmailAuthRequest struct {
+	Email    string `json:"email" validate:"required,email"`           // Required, should be a valid email
+	Password string `json:"password" validate:"required,min=8,max=32"` // Required, minLength: 8, maxLength: 32
+}
+
+func (r *EmailAuthRequest) Normalize() { r.Email = NormalizeEmail(r.Email) }
+
+type PhoneAuthRequest struct {
+	Phone    string `json:"phone" validate:"required,e164"`            // Required, international format starting with "+"
+	Password string `json:"password" validate:"required,min=8,max=32"` // Required, minLength: 8, maxLength: 32
+}
+
+type LinkEmailRequest struct {
+	Email string `json:"email" validate:"required,email"` // Required, should be a valid email
+}
+
+func (r *LinkEmailRequest) Normalize() { r.Email = NormalizeEmail(r.Email) }
+
+type LinkPhoneRequest struct {
+	Phone string `json:"phone" validate:"required,e164"` // Required, international format starting with "+"
+}
+
+type AuthResponse struct {
+	Email string `json:"email"` // string, empty when not linked
+	Phone string `json:"phone"` // string, empty when not linked
+	Token string `json:"token"` // JWT bearer token
+}
+
+type UpdateUserRequest struct {
+	FileID            string `json:"fileId" validate:"omitempty,uuid"`                   // Optional, should be a valid fileId
+	BankAccountName   string `json:"bankAccountName" validate:"required,min=4,max=32"`   // Required, minLength: 4, maxLength: 32
+	BankAccountHolder string `json:"bankAccountHolder" validate:"required,min=4,max=32"` // Required, minLength: 4, maxLength: 32
+	BankAccountNumber string `json:"bankAccountNumber" validate:"required,min=4,max=32"` // Required, minLength: 4, maxLength: 32
+}
+
+type UserResponse struct {
+	Email             string `json:"email"`             // string, empty when not linked
+	Phone             string `json:"phone"`             // string, empty when not linked
+	FileID            string `json:"fileId"`            // string, empty when no profile image
+	FileUri           string `json:"fileUri"`           // related file URI
+	FileThumbnailUri  string `json:"fileThumbnailUri"`  // related file thumbnail URI
+	BankAccountName   string `json:"bankAccountName"`   // string
+	BankAccountHolder string `json:"bankAccountHolder"` // string
+	BankAccountNumber string `json:"bankAccountNumber"` // string
+}
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+	"tutuplapak/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// unknownUserHash stands in for the password hash of a user that does not
+// exist; login refuses users without an id even if the password matches.
+var unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
+
+type AuthHandler struct {
+	Repo repositories.UserStore
+}
+
+func NewAuthHandler(db *sql.DB) *AuthHandler {
+	return &AuthHandler{
+		Repo: repositories.NewUserRepository(db),
+	}
+}
+
+func (h *AuthHandler) RegisterEmail(c *gin.Context) {
+	var req dto.EmailAuthRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	h.register(c, req.Email, "", req.Password)
+}
+
+func (h *AuthHandler) RegisterPhone(c *gin.Context) {
+	var req dto.PhoneAuthRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	h.register(c, "", req.Phone, req.Password)
+}
+
+func (h *AuthHandler) LoginEmail(c *gin.Context) {
+	var req dto.EmailAuthRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	user, err := h.Repo.GetUserByEmail(req.Email)
+	h.login(c, user, err, req.Password)
+}
+
+func (h *AuthHandler) LoginPhone(c *gin.Context) {
+	var req dto.PhoneAuthRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	user, err := h.Repo.GetUserByPhone(req.Phone)
+	h.login(c, user, err, req.Password)
+}
+
+func (h *AuthHandler) register(c *gin.Context, email, phone, password string) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user, err := h.Repo.CreateUser(email, phone, string(hash))
+	if errors.Is(err, repositories.ErrUserAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondWithToken(c, http.StatusCreated, user)
+}
+
+// login answers an unknown user and a wrong password alike, down to
+// spending a bcrypt comparison on both, so it never tells which accounts
+// exist.
+func (h *AuthHandler) login(c *gin.Context, user models.User, err error, password string) {
+	if err != nil && !errors.Is(err, repositories.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash := []byte(user.PasswordHash)
+	if err != nil {
+		hash = unknownUserHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || user.ID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	respondWithToken(c, http.StatusOK, user)
+}
+
+func respondWithToken(c *gin.Context, status int, user models.User) {
+	token, err := utils.GenerateJWT(user.ID, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(status, dto.AuthResponse{
+		Email: user.Email,
+		Phone: user.Phone,
+		Token: token,
+	})
+}
+
+// bindAndValidate decodes the JSON body into req, normalizes it if it is a
+// dto.Normalizer and runs the struct's validate tags, writing a 400 response
+// and returning false on failure.
+func bindAndValidate(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if normalizer, ok := req.(dto.Normalizer); ok {
+		normalizer.Normalize()
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"tutuplapak/repositories/memory"
+	"tutuplapak/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAuthServer serves the register and login routes from an in-memory
+// store. The returned function posts body to path and decodes the response.
+func newAuthServer(t *testing.T) (*memory.Store, func(path, body string) (int, gin.H)) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	utils.JWTSecret = []byte("test-secret")
+
+	store := memory.New()
+	authHandler := &AuthHandler{Repo: store}
+	router := gin.New()
+	router.POST("/v1/register/email", authHandler.RegisterEmail)
+	router.POST("/v1/register/phone", authHandler.RegisterPhone)
+	router.POST("/v1/login/email", authHandler.LoginEmail)
+	router.POST("/v1/login/phone", authHandler.LoginPhone)
+
+	post := func(path, body string) (int, gin.H) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response gin.H
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("POST %s: decoding %q: %v", path, w.Body, err)
+		}
+		return w.Code, response
+	}
+	return store, post
+}
+
+// assertToken checks that an auth response carries a token for the user
+// with the given email.
+func assertToken(t *testing.T, what string, response gin.H, userId uint, email string) {
+	t.Helper()
+
+	token, _ := response["token"].(string)
+	claims, err := utils.ValidateJWT(token)
+	if err != nil || claims.UserID != userId || claims.Email != email {
+		t.Fatalf("%s: got token %q with claims %+v, %v, want user %d with email %q", what, token, claims, err, userId, email)
+	}
+}
+
+func TestRegisterAndLoginEmail(t *testing.T) {
+	store, post := newAuthServer(t)
+
+	code, response := post("/v1/register/email", `{"email":"  Seller@Example.COM ","password":"password123"}`)
+	if code != http.StatusCreated || response["email"] != "seller@example.com" || response["phone"] != "" {
+		t.Fatalf("register: got status %d and %v, want 201 with the lowercased email", code, response)
+	}
+	user, err := store.GetUserByEmail("seller@example.com")
+	if err != nil || user.PasswordHash == "password123" {
+		t.Fatalf("registered user: got %+v, %v, want a hashed password", user, err)
+	}
+	assertToken(t, "register", response, user.ID, "seller@example.com")
+
+	// Any spelling of the address is the same account
+	if code, _ := post("/v1/register/email", `{"email":"seller@example.com","password":"password456"}`); code != http.StatusConflict {
+		t.Fatalf("register a taken email: got status %d, want 409", code)
+	}
+
+	code, response = post("/v1/login/email", `{"email":"SELLER@example.com","password":"password123"}`)
+	if code != http.StatusOK {
+		t.Fatalf("login: got status %d and %v, want 200", code, response)
+	}
+	assertToken(t, "login", response, user.ID, "seller@example.com")
+
+	for _, body := range []string{
+		`{"email":"seller@example.com","password":"password456"}`,
+		`{"email":"nobody@example.com","password":"password123"}`,
+	} {
+		if code, response := post("/v1/login/email", body); code != http.StatusUnauthorized || response["error"] != "Invalid credentials" {
+			t.Fatalf("login with %s: got status %d and %v, want 401 Invalid credentials", body, code, response)
+		}
+	}
+
+	for _, body := range []string{
+		`{"email":"not-an-email","password":"password123"}`,
+		`{"email":"seller@example.com","password":"short"}`,
+		`{"email":"seller@example.com"}`,
+	} {
+		if code, _ := post("/v1/register/email", body); code != http.StatusBadRequest {
+			t.Fatalf("register with %s: got status %d, want 400", body, code)
+		}
+	}
+}
+
+func TestRegisterAndLoginPhone(t *testing.T) {
+	store, post := newAuthServer(t)
+
+	code, response := post("/v1/register/phone", `{"phone":"+6281234567890","password":"password123"}`)
+	if code != http.StatusCreated || response["phone"] != "+6281234567890" || response["email"] != "" {
+		t.Fatalf("register: got status %d and %v, want 201", code, response)
+	}
+	user, err := store.GetUserByPhone("+6281234567890")
+	if err != nil {
+		t.Fatalf("GetUserByPhone: %v", err)
+	}
+	assertToken(t, "register", response, user.ID, "")
+
+	if code, _ := post("/v1/register/phone", `{"phone":"+6281234567890","password":"password456"}`); code != http.StatusConflict {
+		t.Fatalf("register a taken phone: got status %d, want 409", code)
+	}
+	if code, _ := post("/v1/register/phone", `{"phone":"081234567890","password":"password123"}`); code != http.StatusBadRequest {
+		t.Fatalf("register a phone without a country code: got status %d, want 400", code)
+	}
+
+	code, response = post("/v1/login/phone", `{"phone":"+6281234567890","password":"password123"}`)
+	if code != http.StatusOK {
+		t.Fatalf("login: got status %d and %v, want 200", code, response)
+	}
+	assertToken(t, "login", response, user.ID, "")
+
+	wrongPassword, wrongResponse := post("/v1/login/phone", `{"phone":"+6281234567890","password":"password456"}`)
+	unknown, unknownResponse := post("/v1/login/phone", `{"phone":"+6289999999999","password":"password123"}`)
+	if wrongPassword != http.StatusUnauthorized || unknown != wrongPassword || unknownResponse["error"] != wrongResponse["error"] {
+		t.Fatalf("wrong password and unknown phone: got %d %v and %d %v, want the same 401", wrongPassword, wrongResponse, unknown, unknownResponse)
+	}
+}
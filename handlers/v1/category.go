@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	Repo  repositories.CategoryStore
+	Cache *repositories.CategoryCache
+}
+
+func NewCategoryHandler(db *sql.DB, cache *repositories.CategoryCache) *CategoryHandler {
+	return &CategoryHandler{
+		Repo:  repositories.NewCategoryRepository(db),
+		Cache: cache,
+	}
+}
+
+func toCategoryResponse(category models.ProductCategory) dto.CategoryResponse {
+	return dto.CategoryResponse{
+		CategoryID: formatCategoryId(category.ID),
+		Type:       category.Type,
+		ParentID:   formatCategoryId(category.ParentID),
+		IsActive:   category.IsActive,
+	}
+}
+
+func formatCategoryId(id uint) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// GetCategories lists the categories products can currently be created in.
+func (h *CategoryHandler) GetCategories(c *gin.Context) {
+	categories, err := h.Repo.ListCategories(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]dto.CategoryResponse, 0, len(categories))
+	for _, category := range categories {
+		response = append(response, toCategoryResponse(category))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetCategoryTree returns the active categories nested under their parents.
+// Deactivating a category hides its whole subtree.
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	categories, err := h.Repo.ListCategories(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	children := make(map[uint][]models.ProductCategory)
+	for _, category := range categories {
+		children[category.ParentID] = append(children[category.ParentID], category)
+	}
+
+	c.JSON(http.StatusOK, categoryTree(children, 0))
+}
+
+// categoryTree builds the nodes under parentId. Categories are listed by
+// id, so children keep that order.
+func categoryTree(children map[uint][]models.ProductCategory, parentId uint) []dto.CategoryTreeResponse {
+	nodes := make([]dto.CategoryTreeResponse, 0, len(children[parentId]))
+	for _, category := range children[parentId] {
+		nodes = append(nodes, dto.CategoryTreeResponse{
+			CategoryID: formatCategoryId(category.ID),
+			Type:       category.Type,
+			Children:   categoryTree(children, category.ID),
+		})
+	}
+	return nodes
+}
+
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req dto.CreateCategoryRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	var parentId uint64
+	if req.ParentID != "" {
+		var err error
+		if parentId, err = strconv.ParseUint(req.ParentID, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+			return
+		}
+	}
+
+	category, err := h.Repo.CreateCategory(req.Type, uint(parentId))
+	if err != nil {
+		respondCategoryMutationError(c, err)
+		return
+	}
+	h.Cache.Invalidate()
+
+	c.JSON(http.StatusCreated, toCategoryResponse(category))
+}
+
+// UpdateCategory renames, moves and/or (de)activates a category. Products
+// keep a deactivated category, but it can no longer be assigned.
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	categoryId, err := strconv.ParseUint(c.Param("categoryId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var req dto.UpdateCategoryRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	if req.Type == nil && req.ParentID == nil && req.IsActive == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request body must contain at least one field to update"})
+		return
+	}
+
+	category, err := h.Repo.UpdateCategory(uint(categoryId), req)
+	if err != nil {
+		respondCategoryMutationError(c, err)
+		return
+	}
+	h.Cache.Invalidate()
+
+	c.JSON(http.StatusOK, toCategoryResponse(category))
+}
+
+// DeleteCategory removes a category that has no products or subcategories.
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	categoryId, err := strconv.ParseUint(c.Param("categoryId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	if err := h.Repo.DeleteCategory(uint(categoryId)); err != nil {
+		respondCategoryMutationError(c, err)
+		return
+	}
+	h.Cache.Invalidate()
+
+	c.JSON(http.StatusOK, "Category deleted")
+}
+
+func respondCategoryMutationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, repositories.ErrCategoryParentNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+	case errors.Is(err, repositories.ErrCategoryCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrCategoryAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Category already exists"})
+	case errors.Is(err, repositories.ErrCategoryInUse), errors.Is(err, repositories.ErrCategoryHasDeletedProducts):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"tutuplapak/repositories"
+)
+
+func TestCategoryRenameInvalidatesProductETag(t *testing.T) {
+	s := newTestServer(t)
+	categoryHandler := &CategoryHandler{Repo: s.store, Cache: repositories.NewCategoryCache(s.store, time.Minute)}
+	s.router.PATCH("/v1/category/:categoryId", categoryHandler.UpdateCategory)
+
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 10, 25000)
+	path := fmt.Sprintf("/v1/product/%d", product.ID)
+
+	w := s.serve(s.request(http.MethodGet, path, "", ""))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("get: got status %d and ETag %q", w.Code, etag)
+	}
+
+	categories, err := s.store.ListCategories(true)
+	if err != nil {
+		t.Fatalf("ListCategories: %v", err)
+	}
+	var beverageId uint
+	for _, category := range categories {
+		if category.Type == "Beverage" {
+			beverageId = category.ID
+		}
+	}
+	if code := s.do(http.MethodPatch, fmt.Sprintf("/v1/category/%d", beverageId), "application/json", `{"type":"Drinks"}`, nil); code != http.StatusOK {
+		t.Fatalf("rename category: got status %d, want 200", code)
+	}
+
+	// The old ETag no longer matches, so the client gets the new category
+	req := s.request(http.MethodGet, path, "", "")
+	req.Header.Set("If-None-Match", etag)
+	w = s.serve(req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("get with the pre-rename ETag: got status %d and ETag %q, want 200 and a new ETag", w.Code, w.Header().Get("ETag"))
+	}
+	var got struct {
+		Category string `json:"category"`
+	}
+	if code := s.do(http.MethodGet, path, "", "", &got); code != http.StatusOK || got.Category != "Drinks" {
+		t.Fatalf("get after rename: got status %d and category %q, want 200 and Drinks", code, got.Category)
+	}
+}
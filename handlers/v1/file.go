@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"tutuplapak/config"
+	"tutuplapak/dto"
+	"tutuplapak/repositories"
+	"tutuplapak/storage"
+	"tutuplapak/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FileHandler struct {
+	Repo    repositories.FileStore
+	Storage storage.Storage
+	MaxSize int64
+
+	ThumbnailMaxDimension int
+	ThumbnailQuality      int
+}
+
+func NewFileHandler(db *sql.DB, cfg *config.Config, store storage.Storage) *FileHandler {
+	return &FileHandler{
+		Repo:    repositories.NewFileRepository(db),
+		Storage: store,
+		MaxSize: cfg.FileMaxSize,
+
+		ThumbnailMaxDimension: cfg.ThumbnailMaxDimension,
+		ThumbnailQuality:      cfg.ThumbnailQuality,
+	}
+}
+
+func (h *FileHandler) UploadFile(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	if header.Size > h.MaxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("file must not exceed %d bytes", h.MaxSize)})
+		return
+	}
+
+	if !utils.HasImageExtension(header.Filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file must be a jpg, jpeg or png image"})
+		return
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, h.MaxSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	if int64(len(data)) > h.MaxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("file must not exceed %d bytes", h.MaxSize)})
+		return
+	}
+
+	contentType, ok := utils.SniffImageContentType(data)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file content must be a jpg, jpeg or png image"})
+		return
+	}
+
+	thumbnail, err := utils.GenerateThumbnail(data, h.ThumbnailMaxDimension, h.ThumbnailQuality)
+	if errors.Is(err, utils.ErrImageTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file content must be a valid jpg, jpeg or png image"})
+		return
+	}
+
+	key, err := newObjectKey(header.Filename)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file name"})
+		return
+	}
+
+	fileUri, err := h.Storage.Upload(c.Request.Context(), key, bytes.NewReader(data), int64(len(data)), contentType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	thumbnailKey := strings.TrimSuffix(key, filepath.Ext(key)) + "_thumbnail.jpg"
+	thumbnailUri, err := h.Storage.Upload(c.Request.Context(), thumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := h.Repo.CreateFile(fileUri, thumbnailUri)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.FileResponse{
+		FileID:           file.FileID,
+		FileUri:          file.FileUri,
+		FileThumbnailUri: file.FileThumbnailUri,
+	})
+}
+
+// newObjectKey returns a random storage key that keeps the lowercased
+// extension of the uploaded file name.
+func newObjectKey(filename string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf) + strings.ToLower(filepath.Ext(filename)), nil
+}
@@ -0,0 +1,188 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tutuplapak/dto"
+	"tutuplapak/repositories/memory"
+	"tutuplapak/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testFileBaseURL = "http://files.example.com/uploads"
+
+// newFileServer serves the upload route with a memory store and local
+// storage in a temporary directory, which it returns alongside.
+func newFileServer(t *testing.T, maxSize int64) (*gin.Engine, *memory.Store, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	local, err := storage.NewLocalStorage(dir, testFileBaseURL+"/")
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	store := memory.New()
+	fileHandler := &FileHandler{
+		Repo:                  store,
+		Storage:               local,
+		MaxSize:               maxSize,
+		ThumbnailMaxDimension: 32,
+		ThumbnailQuality:      70,
+	}
+
+	router := gin.New()
+	router.POST("/v1/file", fileHandler.UploadFile)
+	return router, store, dir
+}
+
+// uploadRequest builds a multipart upload of data under filename, leaving
+// the file part out when filename is empty.
+func uploadRequest(t *testing.T, filename string, data []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if filename != "" {
+		part, err := form.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatalf("CreateFormFile: %v", err)
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/file", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func encodeTestImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encoding %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+// storedPath maps a URI returned by local storage back to its file on disk.
+func storedPath(t *testing.T, dir, uri string) string {
+	t.Helper()
+
+	if !strings.HasPrefix(uri, testFileBaseURL+"/") {
+		t.Fatalf("URI %q is not under %s", uri, testFileBaseURL)
+	}
+	return filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(uri, testFileBaseURL+"/")))
+}
+
+func TestUploadFile(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		filename string
+	}{
+		{"png", "photo.png"},
+		{"jpeg", "photo.JPG"},
+		{"jpeg", "photo.jpeg"},
+	} {
+		t.Run(tc.filename, func(t *testing.T) {
+			router, store, dir := newFileServer(t, 100*1024)
+			data := encodeTestImage(t, tc.format, 64, 48)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, uploadRequest(t, tc.filename, data))
+			if w.Code != http.StatusOK {
+				t.Fatalf("upload: got status %d, want 200: %s", w.Code, w.Body)
+			}
+
+			var file dto.FileResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &file); err != nil {
+				t.Fatalf("decoding %q: %v", w.Body, err)
+			}
+			if exists, err := store.IsFileExists(file.FileID); err != nil || !exists {
+				t.Fatalf("files row for %q: got %v, %v, want it to exist", file.FileID, exists, err)
+			}
+
+			// The original is stored byte for byte under a lowercased extension
+			original := storedPath(t, dir, file.FileUri)
+			if ext := filepath.Ext(original); ext != strings.ToLower(filepath.Ext(tc.filename)) {
+				t.Fatalf("fileUri %q: got extension %q", file.FileUri, ext)
+			}
+			if stored, err := os.ReadFile(original); err != nil || !bytes.Equal(stored, data) {
+				t.Fatalf("stored original: got %d bytes, %v, want the %d uploaded", len(stored), err, len(data))
+			}
+
+			thumbnail := storedPath(t, dir, file.FileThumbnailUri)
+			if !strings.HasSuffix(thumbnail, "_thumbnail.jpg") {
+				t.Fatalf("fileThumbnailUri %q does not end in _thumbnail.jpg", file.FileThumbnailUri)
+			}
+			stored, err := os.ReadFile(thumbnail)
+			if err != nil {
+				t.Fatalf("reading thumbnail: %v", err)
+			}
+			config, err := jpeg.DecodeConfig(bytes.NewReader(stored))
+			if err != nil || config.Width != 32 || config.Height != 24 {
+				t.Fatalf("thumbnail: got %dx%d, %v, want a 32x24 JPEG", config.Width, config.Height, err)
+			}
+		})
+	}
+}
+
+func TestUploadFileRejected(t *testing.T) {
+	photo := encodeTestImage(t, "png", 64, 48)
+
+	for _, tc := range []struct {
+		name     string
+		filename string
+		data     []byte
+		maxSize  int64
+	}{
+		{"missing file", "", nil, 100 * 1024},
+		{"over the size limit", "photo.png", photo, int64(len(photo)) - 1},
+		{"unsupported extension", "photo.gif", photo, 100 * 1024},
+		{"text named .png", "notes.png", []byte("just some text, not an image"), 100 * 1024},
+		{"truncated png", "photo.png", photo[:len(photo)/2], 100 * 1024},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			router, _, dir := newFileServer(t, tc.maxSize)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, uploadRequest(t, tc.filename, tc.data))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("upload: got status %d, want 400: %s", w.Code, w.Body)
+			}
+
+			// Nothing reaches storage for a rejected upload
+			if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+				t.Fatalf("storage after a rejected upload: got %d entries, %v", len(entries), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"tutuplapak/dto"
+	"tutuplapak/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// An import runs in a single transaction, so its size is bounded; bigger
+// catalogues are imported as several files.
+const (
+	importMaxBytes = 10 << 20
+	importMaxRows  = 5000
+)
+
+var errImportTooLarge = fmt.Errorf("imports are limited to %d rows and %d bytes, split the file", importMaxRows, importMaxBytes)
+
+// importColumns sets a CreateProductRequest field from a csv cell, by
+// column name. fileIds holds the gallery separated by |.
+var importColumns = map[string]func(req *dto.CreateProductRequest, value string) error{
+	"name": func(req *dto.CreateProductRequest, value string) error {
+		req.Name = value
+		return nil
+	},
+	"category": func(req *dto.CreateProductRequest, value string) error {
+		req.Category = value
+		return nil
+	},
+	"qty": func(req *dto.CreateProductRequest, value string) error {
+		return parseImportInt(value, "qty", &req.Qty)
+	},
+	"price": func(req *dto.CreateProductRequest, value string) error {
+		return parseImportInt(value, "price", &req.Price)
+	},
+	"sku": func(req *dto.CreateProductRequest, value string) error {
+		req.SKU = value
+		return nil
+	},
+	"fileId": func(req *dto.CreateProductRequest, value string) error {
+		req.FileID = value
+		return nil
+	},
+	"fileIds": func(req *dto.CreateProductRequest, value string) error {
+		req.FileIDs = strings.Split(value, "|")
+		return nil
+	},
+	"allowNegativeStock": func(req *dto.CreateProductRequest, value string) error {
+		allow, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("allowNegativeStock must be true or false")
+		}
+		req.AllowNegativeStock = allow
+		return nil
+	},
+	"lowStockThreshold": func(req *dto.CreateProductRequest, value string) error {
+		return parseImportInt(value, "lowStockThreshold", &req.LowStockThreshold)
+	},
+}
+
+func parseImportInt(value, field string, target *int) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer", field)
+	}
+	*target = n
+	return nil
+}
+
+// importRow is one product of an import file and what is wrong with it.
+// provided holds the columns, or JSON keys, the row sets.
+type importRow struct {
+	line     int
+	req      dto.CreateProductRequest
+	provided map[string]bool
+	errors   []string
+}
+
+// update is what the row changes on an existing product: just the fields it
+// provides, so a missing column keeps the product's value. Like
+// UpdateProduct, fileId alone replaces the primary image and fileIds the
+// whole gallery.
+func (row importRow) update() dto.UpdateProductRequest {
+	req := row.req
+	var update dto.UpdateProductRequest
+	if row.provided["name"] {
+		update.Name = &req.Name
+	}
+	if row.provided["category"] {
+		update.Category = &req.Category
+	}
+	if row.provided["qty"] {
+		update.Qty = &req.Qty
+	}
+	if row.provided["price"] {
+		update.Price = &req.Price
+	}
+	if row.provided["fileId"] {
+		update.FileID = &req.FileID
+	}
+	if row.provided["fileIds"] {
+		update.FileIDs = &req.FileIDs
+	}
+	if row.provided["allowNegativeStock"] {
+		update.AllowNegativeStock = &req.AllowNegativeStock
+	}
+	if row.provided["lowStockThreshold"] {
+		update.LowStockThreshold = &req.LowStockThreshold
+	}
+	return update
+}
+
+// ImportProducts creates and updates the seller's products in bulk, matching
+// existing products by sku. The body is either csv with a header row naming
+// the columns, or newline delimited JSON with one CreateProductRequest per
+// line. Every row is validated like CreateProduct and the rows are written
+// all or nothing; with dryRun=true only the report is returned.
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dryRun must be true or false"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, importMaxBytes)
+
+	var rows []importRow
+	switch c.ContentType() {
+	case "text/csv":
+		rows, err = readImportCSV(c.Request.Body)
+	case "application/x-ndjson", "application/ndjson":
+		rows, err = readImportNDJSON(c.Request.Body)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be text/csv or application/x-ndjson"})
+		return
+	}
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, errImportTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errImportTooLarge.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the file has no products"})
+		return
+	}
+
+	if err := h.validateImportRows(rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Rows that failed validation are left out and the rest only dry run,
+	// so the report still covers what the database would reject
+	valid := make([]int, 0, len(rows))
+	imports := make([]repositories.ImportRow, 0, len(rows))
+	for i, row := range rows {
+		if len(row.errors) == 0 {
+			valid = append(valid, i)
+			imports = append(imports, repositories.ImportRow{Create: row.req, Update: row.update()})
+		}
+	}
+	write := !dryRun && len(valid) == len(rows)
+
+	results, err := h.Repo.ImportProducts(c.GetUint("userId"), imports, !write)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for j, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		if !isImportRowError(result.Err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Err.Error()})
+			return
+		}
+		rows[valid[j]].errors = append(rows[valid[j]].errors, result.Err.Error())
+	}
+
+	response := dto.ImportResponse{
+		DryRun: dryRun,
+		Rows:   make([]dto.ImportRowResponse, len(rows)),
+	}
+	for i, row := range rows {
+		response.Rows[i] = dto.ImportRowResponse{
+			Line:   row.line,
+			SKU:    row.req.SKU,
+			Errors: make([]string, 0, len(row.errors)),
+		}
+		response.Rows[i].Errors = append(response.Rows[i].Errors, row.errors...)
+		if len(row.errors) > 0 {
+			response.Failed++
+		}
+	}
+	response.Imported = write && response.Failed == 0
+
+	for j, result := range results {
+		row := &response.Rows[valid[j]]
+		if len(row.Errors) > 0 {
+			continue
+		}
+		if result.Created {
+			row.Action = "create"
+			response.Created++
+		} else {
+			row.Action = "update"
+			response.Updated++
+		}
+		// A product created by a rolled back import does not exist
+		if !result.Created || response.Imported {
+			row.ProductID = strconv.Itoa(result.ProductID)
+		}
+	}
+
+	status := http.StatusOK
+	if response.Failed > 0 && !dryRun {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, response)
+}
+
+// validateImportRows checks each row the way CreateProduct checks its
+// request, recording the problems on the row. Files are looked up once
+// however many rows share them. The error is only for failed lookups.
+func (h *ProductHandler) validateImportRows(rows []importRow) error {
+	categories, err := h.Categories.All()
+	if err != nil {
+		return errors.New("Failed to validate category")
+	}
+
+	validate := validator.New()
+	fileExists := make(map[string]bool)
+	skuLines := make(map[string]int)
+
+	for i := range rows {
+		row := &rows[i]
+		if len(row.errors) > 0 {
+			continue
+		}
+		req := &row.req
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				return err
+			}
+			for _, fieldErr := range validationErrors {
+				row.errors = append(row.errors, fieldErr.Error())
+			}
+		}
+
+		if req.Category != "" && !categories[req.Category].IsActive {
+			row.errors = append(row.errors, "Invalid category")
+		}
+
+		if req.SKU != "" {
+			if line, ok := skuLines[req.SKU]; ok {
+				row.errors = append(row.errors, fmt.Sprintf("sku is already used on line %d", line))
+			} else {
+				skuLines[req.SKU] = row.line
+			}
+		}
+
+		if err := normalizeGallery(req); err != nil {
+			row.errors = append(row.errors, err.Error())
+		}
+
+		fileIds := append([]string(nil), req.FileIDs...)
+		for _, variant := range req.Variants {
+			if variant.FileID != "" {
+				fileIds = append(fileIds, variant.FileID)
+			}
+		}
+		for _, fileId := range fileIds {
+			// Malformed ids were reported with the validation errors above
+			if validate.Var(fileId, "uuid") != nil {
+				continue
+			}
+			exists, ok := fileExists[fileId]
+			if !ok {
+				exists, err = h.Repo.IsFileExists(fileId)
+				if err != nil {
+					return errors.New("Failed to validate fileId")
+				}
+				fileExists[fileId] = exists
+			}
+			if !exists {
+				row.errors = append(row.errors, fmt.Sprintf("fileId %s does not exist", fileId))
+			}
+		}
+	}
+	return nil
+}
+
+// isImportRowError reports whether err is a problem with the row itself,
+// as opposed to the database failing.
+func isImportRowError(err error) bool {
+	for _, rowErr := range []error{
+		repositories.ErrImportVariants, repositories.ErrProductHasVariants, repositories.ErrProductHasNoOptions,
+		repositories.ErrVariantOptionsMismatch, repositories.ErrVariantAlreadyExists, repositories.ErrProductSKUExists,
+		repositories.ErrInsufficientStock,
+	} {
+		if errors.Is(err, rowErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// readImportCSV reads csv rows, using the header to know which field each
+// column sets. Empty cells leave the field unset.
+func readImportCSV(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(header))
+	seen := make(map[string]bool)
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		if _, ok := importColumns[column]; !ok {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("column %q appears twice", column)
+		}
+		seen[column] = true
+		columns[i] = column
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if len(rows) == importMaxRows {
+			return nil, errImportTooLarge
+		}
+
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line, provided: make(map[string]bool)}
+		if err != nil {
+			row.errors = append(row.errors, fmt.Sprintf("row has %d columns, the header has %d", len(record), len(header)))
+		} else {
+			for i, value := range record {
+				value = strings.TrimSpace(value)
+				if value == "" {
+					continue
+				}
+				if err := importColumns[columns[i]](&row.req, value); err != nil {
+					row.errors = append(row.errors, err.Error())
+				}
+				row.provided[columns[i]] = true
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readImportNDJSON reads one JSON object per line, skipping blank lines. A
+// key set to null counts as missing.
+func readImportNDJSON(body io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var rows []importRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows) == importMaxRows {
+			return nil, errImportTooLarge
+		}
+
+		row := importRow{line: line, provided: make(map[string]bool)}
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(data, &row.req); err != nil {
+			row.errors = append(row.errors, "invalid JSON: "+err.Error())
+		} else if err := json.Unmarshal(data, &keys); err == nil {
+			// Keys match fields case insensitively, like they do for req
+			for key, value := range keys {
+				for column := range importColumns {
+					if strings.EqualFold(key, column) && string(value) != "null" {
+						row.provided[column] = true
+					}
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return nil, errors.New("a line is longer than 1 MiB")
+	}
+	return rows, scanner.Err()
+}
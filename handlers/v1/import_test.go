@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"tutuplapak/dto"
+)
+
+func TestImportProductsCSV(t *testing.T) {
+	s := newTestServer(t)
+
+	csv := "name,category,qty,price,sku,fileId,lowStockThreshold\n" +
+		"Coffee Beans,Beverage,10,25000,SKU-1," + s.file.FileID + ",2\n" +
+		"Tea Leaves,Beverage,5,15000,SKU-2," + s.file.FileID + ",\n"
+
+	var report dto.ImportResponse
+	if code := s.do(http.MethodPost, "/v1/product/import?dryRun=true", "text/csv", csv, &report); code != http.StatusOK {
+		t.Fatalf("dry run: got status %d, want 200", code)
+	}
+	if !report.DryRun || report.Imported || report.Created != 2 || report.Failed != 0 {
+		t.Fatalf("dry run: got %+v", report)
+	}
+	if products, _ := s.store.FilterProducts(map[string]string{}); len(products) != 0 {
+		t.Fatalf("dry run wrote %d products", len(products))
+	}
+
+	report = dto.ImportResponse{}
+	if code := s.do(http.MethodPost, "/v1/product/import", "text/csv; charset=utf-8", csv, &report); code != http.StatusOK {
+		t.Fatalf("import: got status %d, want 200", code)
+	}
+	if !report.Imported || report.Created != 2 || report.Rows[0].Line != 2 || report.Rows[0].ProductID == "" {
+		t.Fatalf("import: got %+v", report)
+	}
+
+	// A failing row keeps the others from being written
+	invalid := "name,category,qty,price,sku,fileId\n" +
+		"Coffee Beans,Beverage,12,25000,SKU-1," + s.file.FileID + "\n" +
+		"Sugar Cubes,Food,abc,5000,SKU-3," + s.file.FileID + "\n"
+	report = dto.ImportResponse{}
+	if code := s.do(http.MethodPost, "/v1/product/import", "text/csv", invalid, &report); code != http.StatusBadRequest {
+		t.Fatalf("import with a failing row: got status %d, want 400", code)
+	}
+	if report.Imported || report.Updated != 1 || report.Failed != 1 || len(report.Rows[1].Errors) == 0 {
+		t.Fatalf("import with a failing row: got %+v", report)
+	}
+	beans, err := s.store.GetProductById(1)
+	if err != nil || beans.Qty != 10 {
+		t.Fatalf("import with a failing row changed the product: got %+v, %v", beans, err)
+	}
+
+	if code := s.do(http.MethodPost, "/v1/product/import", "application/json", csv, nil); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("import as application/json: got status %d, want 415", code)
+	}
+}
+
+func TestImportProductsNDJSON(t *testing.T) {
+	s := newTestServer(t)
+
+	ndjson := fmt.Sprintf(`{"name":"Coffee Beans","category":"Beverage","qty":10,"price":25000,"sku":"SKU-1","fileId":%q}`+"\n\n"+
+		`{"name":"Sugar Cubes","category":"Food","qty":3,"price":5000,"sku":"SKU-2","fileIds":[%q]}`+"\n", s.file.FileID, s.file.FileID)
+
+	var report dto.ImportResponse
+	if code := s.do(http.MethodPost, "/v1/product/import", "application/x-ndjson", ndjson, &report); code != http.StatusOK {
+		t.Fatalf("import: got status %d and %+v, want 200", code, report)
+	}
+	if !report.Imported || report.Created != 2 || report.Rows[1].Line != 3 {
+		t.Fatalf("import: got %+v", report)
+	}
+}
+
+func TestImportProductsUpdatesProvidedFields(t *testing.T) {
+	s := newTestServer(t)
+
+	back, err := s.store.CreateFile("https://example.com/b.jpg", "https://example.com/b_thumbnail.jpg")
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	front, err := s.store.CreateFile("https://example.com/c.jpg", "https://example.com/c_thumbnail.jpg")
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	body := fmt.Sprintf(`{"name":"Coffee Beans","category":"Beverage","qty":10,"price":25000,"sku":"SKU-1","fileIds":[%q,%q],"allowNegativeStock":true,"lowStockThreshold":3}`,
+		s.file.FileID, back.FileID)
+	if code := s.do(http.MethodPost, "/v1/product/", "application/json", body, nil); code != http.StatusCreated {
+		t.Fatalf("create: got status %d, want 201", code)
+	}
+
+	// Without the allowNegativeStock, lowStockThreshold and fileIds columns
+	// those keep their values, and fileId only replaces the primary image
+	csv := "name,category,qty,price,sku,fileId\n" +
+		"Dark Coffee Beans,Beverage,7,27000,SKU-1," + front.FileID + "\n"
+	var report dto.ImportResponse
+	if code := s.do(http.MethodPost, "/v1/product/import", "text/csv", csv, &report); code != http.StatusOK {
+		t.Fatalf("import: got status %d and %+v, want 200", code, report)
+	}
+	if !report.Imported || report.Updated != 1 {
+		t.Fatalf("import: got %+v", report)
+	}
+
+	beans, err := s.store.GetProductById(1)
+	if err != nil {
+		t.Fatalf("GetProductById: %v", err)
+	}
+	if beans.Name != "Dark Coffee Beans" || beans.Qty != 7 || !beans.AllowNegativeStock || beans.LowStockThreshold != 3 {
+		t.Fatalf("after import: got %+v", *beans)
+	}
+	if len(beans.Images) != 2 || beans.Images[0].FileID != front.FileID || beans.Images[1].FileID != back.FileID {
+		t.Fatalf("after import: got gallery %+v, want the new primary image and the old second one", beans.Images)
+	}
+
+	// The same holds for keys missing from, or null in, an NDJSON row
+	ndjson := `{"sku":"SKU-1","name":"Coffee Beans","category":"Beverage","qty":5,"price":25000,"fileId":"` + front.FileID + `","lowStockThreshold":null}` + "\n"
+	report = dto.ImportResponse{}
+	if code := s.do(http.MethodPost, "/v1/product/import", "application/x-ndjson", ndjson, &report); code != http.StatusOK {
+		t.Fatalf("import: got status %d and %+v, want 200", code, report)
+	}
+	beans, err = s.store.GetProductById(1)
+	if err != nil {
+		t.Fatalf("GetProductById: %v", err)
+	}
+	if beans.Qty != 5 || !beans.AllowNegativeStock || beans.LowStockThreshold != 3 || len(beans.Images) != 2 {
+		t.Fatalf("after NDJSON import: got %+v", *beans)
+	}
+}
+
+func TestImportProductsMalformedFileId(t *testing.T) {
+	s := newTestServer(t)
+
+	csv := "name,category,qty,price,sku,fileIds\n" +
+		"Coffee Beans,Beverage,10,25000,SKU-1," + s.file.FileID + "|not-a-uuid\n"
+	var report dto.ImportResponse
+	if code := s.do(http.MethodPost, "/v1/product/import", "text/csv", csv, &report); code != http.StatusBadRequest {
+		t.Fatalf("import: got status %d and %+v, want 400", code, report)
+	}
+	if report.Failed != 1 || len(report.Rows[0].Errors) != 1 {
+		t.Fatalf("import: got %+v, want the malformed fileId reported once", report)
+	}
+}
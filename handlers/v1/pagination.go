@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"encoding/base64"
+	"errors"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+	"tutuplapak/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeProductCursor returns the opaque keyset cursor pointing just past
+// product in (created_at, id) order.
+func encodeProductCursor(product models.Product) string {
+	raw := product.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + strconv.Itoa(product.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeProductCursor(cursor string) (createdAt string, id string, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", errInvalidCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), ",")
+	if !found {
+		return "", "", errInvalidCursor
+	}
+	if _, err := time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return "", "", errInvalidCursor
+	}
+	if _, err := strconv.Atoi(id); err != nil {
+		return "", "", errInvalidCursor
+	}
+
+	return createdAt, id, nil
+}
+
+// pageLink returns the current request URI with the given query parameters
+// replaced, or removed when their value is empty.
+func pageLink(c *gin.Context, params map[string]string) *string {
+	u := *c.Request.URL
+	query := u.Query()
+	for key, value := range params {
+		if value == "" {
+			query.Del(key)
+		} else {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	link := u.RequestURI()
+	return &link
+}
+
+// wantsBareList reports whether the client asked for the version 1 list
+// shape, a bare JSON array, with "Accept: application/json; version=1".
+func wantsBareList(c *gin.Context) bool {
+	for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if (mediaType == "application/json" || mediaType == "*/*") && params["version"] == "1" {
+			return true
+		}
+	}
+	return false
+}
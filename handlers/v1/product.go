@@ -2,10 +2,15 @@ package v1
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 	"tutuplapak/dto"
+	"tutuplapak/models"
 	"tutuplapak/repositories"
 
 	"github.com/gin-gonic/gin"
@@ -13,40 +18,71 @@ import (
 )
 
 type ProductHandler struct {
-	Repo *repositories.ProductRepository
+	Repo       repositories.ProductStore
+	Categories *repositories.CategoryCache
 }
 
-type UpdateProductRequest struct {
-	Name     *string  `json:"name,omitempty" validate:"omitempty,min=4,max=32"` // Optional, minLength: 4, maxLength: 32
-	Category *string  `json:"category,omitempty" validate:"omitempty"`          // Optional, should be an enum of product category types
-	Qty      *int     `json:"qty,omitempty" validate:"omitempty,min=1"`         // Optional, min: 1
-	Price    *float64 `json:"price,omitempty" validate:"omitempty,min=100"`     // Optional, min: 100
-	SKU      *string  `json:"sku,omitempty" validate:"omitempty,max=32"`        // Optional, maxLength: 32
-	FileID   *string  `json:"fileId,omitempty" validate:"omitempty"`            // Optional, should be a valid fileId
+func NewProductHandler(db *sql.DB, categories *repositories.CategoryCache) *ProductHandler {
+	return &ProductHandler{
+		Repo:       repositories.NewProductRepository(db),
+		Categories: categories,
+	}
 }
 
-var validCategories = map[string]bool{
-	"Food":      true,
-	"Beverage":  true,
-	"Clothes":   true,
-	"Furniture": true,
-	"Tools":     true,
+// validateFiles checks that every file exists, writing the error response
+// when one does not.
+func (h *ProductHandler) validateFiles(c *gin.Context, fileIds []string) bool {
+	for _, fileId := range fileIds {
+		// Validate fileId exists in the database
+		exists, err := h.Repo.IsFileExists(fileId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate fileId"})
+			return false
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fileId does not exist"})
+			return false
+		}
+	}
+	return true
 }
 
-func NewProductHandler(db *sql.DB) *ProductHandler {
-	return &ProductHandler{
-		Repo: repositories.NewProductRepository(db),
+// normalizeGallery defaults the gallery to just fileId, and fileId to the
+// first image, then checks that the two agree.
+func normalizeGallery(req *dto.CreateProductRequest) error {
+	if req.FileID == "" && len(req.FileIDs) == 0 {
+		return errors.New("fileId or fileIds is required")
+	}
+	if len(req.FileIDs) == 0 {
+		req.FileIDs = []string{req.FileID}
 	}
+	if req.FileID == "" {
+		req.FileID = req.FileIDs[0]
+	}
+	if req.FileID != req.FileIDs[0] {
+		return errors.New("fileId must be the first of fileIds")
+	}
+	return nil
 }
 
-func validateCategory(category string) bool {
-	_, exists := validCategories[category]
-	return exists
+// validateCategory checks that category exists and is active, writing the
+// error response when it is not.
+func (h *ProductHandler) validateCategory(c *gin.Context, category string) bool {
+	categories, err := h.Categories.All()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate category"})
+		return false
+	}
+	if !categories[category].IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+		return false
+	}
+	return true
 }
 
 func isValidSortBy(sortBy string) bool {
 	// Valid fixed values
-	if sortBy == "newest" || sortBy == "cheapest" {
+	if sortBy == "newest" || sortBy == "cheapest" || sortBy == "relevance" {
 		return true
 	}
 
@@ -65,6 +101,79 @@ func isValidSortBy(sortBy string) bool {
 	return false
 }
 
+func toProductResponse(product models.Product) dto.ProductResponse {
+	// Products loaded without their gallery, e.g. inside a purchase, show
+	// the primary image alone
+	images := product.Images
+	if len(images) == 0 && product.File.FileID != "" {
+		images = []models.File{product.File}
+	}
+
+	response := dto.ProductResponse{
+		ProductID:          strconv.Itoa(product.ID),
+		Name:               product.Name,
+		Category:           product.Category,
+		Qty:                product.Qty,
+		AvailableQty:       product.Qty - product.ReservedQty,
+		Price:              product.Price,
+		SKU:                product.SKU,
+		FileID:             product.File.FileID,
+		FileUri:            product.File.FileUri,
+		FileThumbnailUri:   product.File.FileThumbnailUri,
+		Images:             make([]dto.FileResponse, 0, len(images)),
+		MinPrice:           product.Price,
+		MaxPrice:           product.Price,
+		Options:            append([]string{}, product.Options...),
+		Variants:           make([]dto.VariantResponse, 0, len(product.Variants)),
+		Archived:           product.Archived,
+		AllowNegativeStock: product.AllowNegativeStock,
+		LowStockThreshold:  product.LowStockThreshold,
+		UserID:             strconv.FormatUint(uint64(product.UserID), 10),
+		CreatedAt:          product.CreatedAt,
+		UpdatedAt:          product.UpdatedAt,
+	}
+	for _, image := range images {
+		response.Images = append(response.Images, dto.FileResponse{
+			FileID:           image.FileID,
+			FileUri:          image.FileUri,
+			FileThumbnailUri: image.FileThumbnailUri,
+		})
+	}
+	for i, variant := range product.Variants {
+		if i == 0 || variant.Price < response.MinPrice {
+			response.MinPrice = variant.Price
+		}
+		if i == 0 || variant.Price > response.MaxPrice {
+			response.MaxPrice = variant.Price
+		}
+		response.Variants = append(response.Variants, toVariantResponse(product.Options, variant))
+	}
+	return response
+}
+
+// toVariantResponse pairs the variant's option values with the product's
+// option names.
+func toVariantResponse(optionNames []string, variant models.ProductVariant) dto.VariantResponse {
+	options := make(map[string]string, len(optionNames))
+	for i, name := range optionNames {
+		if i < len(variant.OptionValues) {
+			options[name] = variant.OptionValues[i]
+		}
+	}
+
+	return dto.VariantResponse{
+		VariantID:        strconv.Itoa(variant.ID),
+		Options:          options,
+		SKU:              variant.SKU,
+		Price:            variant.Price,
+		Qty:              variant.Qty,
+		AvailableQty:     variant.Qty - variant.ReservedQty,
+		FileID:           variant.File.FileID,
+		FileUri:          variant.File.FileUri,
+		FileThumbnailUri: variant.File.FileThumbnailUri,
+	}
+}
+
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var req dto.CreateProductRequest
 
@@ -82,41 +191,31 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	if !validateCategory(req.Category) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+	if !h.validateCategory(c, req.Category) {
 		return
 	}
 
-	// Validate fileId exists in the database
-	exists, err := h.Repo.IsFileExists(req.FileID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate fileId"})
+	if err := normalizeGallery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "fileId does not exist"})
+
+	if !h.validateFiles(c, req.FileIDs) {
 		return
 	}
+	for _, variant := range req.Variants {
+		if variant.FileID != "" && !h.validateFiles(c, []string{variant.FileID}) {
+			return
+		}
+	}
 
-	product, err := h.Repo.CreateProduct(req)
+	product, err := h.Repo.CreateProduct(c.GetUint("userId"), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondProductMutationError(c, err)
 		return
 	}
 
-	response := dto.ProductResponse{
-		ProductID:        strconv.Itoa(product.ID),
-		Name:             product.Name,
-		Category:         product.Category,
-		Qty:              product.Qty,
-		Price:            product.Price,
-		SKU:              product.SKU,
-		FileID:           product.File.FileID,
-		FileUri:          product.File.FileUri,
-		FileThumbnailUri: product.File.FileThumbnailUri,
-		CreatedAt:        product.CreatedAt,
-		UpdatedAt:        product.UpdatedAt,
-	}
+	response := toProductResponse(product)
 
 	c.JSON(http.StatusCreated, response)
 }
@@ -129,54 +228,251 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		return
 	}
 
+	categories, err := h.Categories.All()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate category"})
+		return
+	}
+
+	filters, fieldErrors := buildProductFilters(filter, categories)
+	if _, ok := c.GetQuery("cursor"); ok {
+		addCursorFilters(c, filter, filters, fieldErrors)
+	}
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filters", "fields": fieldErrors})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	filters["limit"] = strconv.Itoa(limit)
+	filters["offset"] = strconv.Itoa(offset)
+	filters["visible_to"] = strconv.FormatUint(uint64(c.GetUint("userId")), 10)
+
+	c.Header("Vary", "Accept")
+	if wantsBareList(c) {
+		products, err := h.Repo.FilterProducts(filters)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		response := make([]dto.ProductResponse, 0)
+		for _, product := range products {
+			response = append(response, toProductResponse(product))
+		}
+
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	total, err := h.Repo.CountProducts(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Fetch one extra product to learn whether there is a next page
+	if limit > 0 {
+		filters["limit"] = strconv.Itoa(limit + 1)
+	}
+	products, err := h.Repo.FilterProducts(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hasMore := limit > 0 && len(products) > limit
+	if hasMore {
+		products = products[:limit]
+	}
+
+	response := dto.ProductListResponse{
+		Data: make([]dto.ProductResponse, 0, len(products)),
+		Meta: dto.ListMeta{Total: total, Limit: limit, Offset: offset},
+	}
+	for _, product := range products {
+		response.Data = append(response.Data, toProductResponse(product))
+	}
+
+	if filters["keyset"] == "true" {
+		// Keyset pages only link forward
+		response.Meta.Offset = 0
+		if hasMore {
+			response.Links.Next = pageLink(c, map[string]string{"cursor": encodeProductCursor(products[len(products)-1])})
+		}
+	} else {
+		if hasMore {
+			response.Links.Next = pageLink(c, map[string]string{"offset": strconv.Itoa(offset + limit)})
+		}
+		if offset > 0 {
+			prev := offset - limit
+			if prev < 0 || limit <= 0 {
+				prev = 0
+			}
+			response.Links.Prev = pageLink(c, map[string]string{"offset": strconv.Itoa(prev)})
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// buildProductFilters translates the query parameters into the filter map
+// understood by ProductStore.FilterProducts. Problems are reported per query
+// parameter so clients can point at the offending field. Deactivated
+// categories can still be filtered on, since products keep them.
+func buildProductFilters(filter dto.FilterProductRequest, categories map[string]models.ProductCategory) (map[string]string, map[string]string) {
 	filters := make(map[string]string)
+	fieldErrors := make(map[string]string)
 
 	if filter.ProductId != "" {
 		filters["product_id"] = filter.ProductId
 	}
 
-	if filter.Category != "" {
-		filters["category"] = filter.Category
+	if len(filter.Category) > 0 {
+		for _, category := range filter.Category {
+			if _, ok := categories[category]; !ok {
+				fieldErrors["category"] = fmt.Sprintf("%q is not a valid category", category)
+			}
+		}
+		filters["category"] = strings.Join(filter.Category, ",")
 	}
 
 	if filter.SKU != "" {
 		filters["sku"] = filter.SKU
 	}
 
+	if filter.UserID != "" {
+		filters["user_id"] = filter.UserID
+	}
+
+	if filter.Q != "" {
+		filters["q"] = filter.Q
+	}
+
+	minPrice, minOk := parseNonNegativeInt(filter.MinPrice, "minPrice", fieldErrors)
+	maxPrice, maxOk := parseNonNegativeInt(filter.MaxPrice, "maxPrice", fieldErrors)
+	if minOk {
+		filters["min_price"] = strconv.Itoa(minPrice)
+	}
+	if maxOk {
+		filters["max_price"] = strconv.Itoa(maxPrice)
+	}
+	if minOk && maxOk && minPrice > maxPrice {
+		fieldErrors["minPrice"] = "must not be greater than maxPrice"
+	}
+
+	if filter.InStock != "" {
+		inStock, err := strconv.ParseBool(filter.InStock)
+		if err != nil {
+			fieldErrors["inStock"] = "must be true or false"
+		} else {
+			filters["in_stock"] = strconv.FormatBool(inStock)
+		}
+	}
+
+	after, afterOk := parseTimestamp(filter.CreatedAfter, "createdAfter", fieldErrors)
+	before, beforeOk := parseTimestamp(filter.CreatedBefore, "createdBefore", fieldErrors)
+	if afterOk {
+		filters["created_after"] = after.UTC().Format(time.RFC3339Nano)
+	}
+	if beforeOk {
+		filters["created_before"] = before.UTC().Format(time.RFC3339Nano)
+	}
+	if afterOk && beforeOk && !after.Before(before) {
+		fieldErrors["createdAfter"] = "must be earlier than createdBefore"
+	}
+
 	if filter.SortBy != "" {
+		if !isValidSortBy(filter.SortBy) {
+			fieldErrors["sortBy"] = "must be newest, cheapest, relevance or sold-<seconds>"
+		}
 		filters["sort_by"] = filter.SortBy
 	}
 
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	return filters, fieldErrors
+}
 
-	filters["limit"] = strconv.Itoa(limit)
-	filters["offset"] = strconv.Itoa(offset)
+// addCursorFilters switches the listing to keyset pagination, which walks
+// products newest first and so cannot honour sortBy, q or offset.
+func addCursorFilters(c *gin.Context, filter dto.FilterProductRequest, filters, fieldErrors map[string]string) {
+	if filter.SortBy != "" || filter.Q != "" {
+		fieldErrors["cursor"] = "cannot be combined with sortBy or q"
+	}
+	if _, ok := c.GetQuery("offset"); ok {
+		fieldErrors["offset"] = "cannot be combined with cursor"
+	}
 
-	products, err := h.Repo.FilterProducts(filters)
+	filters["keyset"] = "true"
+	if filter.Cursor == "" {
+		return
+	}
+
+	createdAt, id, err := decodeProductCursor(filter.Cursor)
+	if err != nil {
+		fieldErrors["cursor"] = "is not a valid cursor"
+		return
+	}
+	filters["cursor_created_at"] = createdAt
+	filters["cursor_id"] = id
+}
+
+func parseNonNegativeInt(value, field string, fieldErrors map[string]string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		fieldErrors[field] = "must be a non-negative integer"
+		return 0, false
+	}
+	return parsed, true
+}
+
+func parseTimestamp(value, field string, fieldErrors map[string]string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		fieldErrors[field] = "must be an RFC 3339 timestamp, e.g. 2024-01-31T15:04:05Z"
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+func (h *ProductHandler) GetProduct(c *gin.Context) {
+	parsedProductId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	product, err := h.Repo.GetProductById(parsedProductId)
+	if errors.Is(err, repositories.ErrProductNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if product.Archived && product.UserID != c.GetUint("userId") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
 
-	response := make([]dto.ProductResponse, 0)
-	for _, product := range products {
-		response = append(response, dto.ProductResponse{
-			ProductID:        strconv.Itoa(product.ID),
-			Name:             product.Name,
-			Category:         product.Category,
-			Qty:              product.Qty,
-			Price:            product.Price,
-			SKU:              product.SKU,
-			FileID:           product.File.FileID,
-			FileUri:          product.File.FileUri,
-			FileThumbnailUri: product.File.FileThumbnailUri,
-			CreatedAt:        product.CreatedAt,
-			UpdatedAt:        product.UpdatedAt,
-		})
+	etag := productETag(product.Version)
+	c.Header("ETag", etag)
+
+	if match := c.GetHeader("If-None-Match"); match != "" && (match == "*" || etagMatches(match, etag)) {
+		c.Status(http.StatusNotModified)
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, toProductResponse(*product))
 }
 
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
@@ -192,8 +488,8 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	var req dto.UpdateProductRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	req, err := bindPatch(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -207,24 +503,24 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	if !validateCategory(req.Category) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+	if req.Category != nil && !h.validateCategory(c, *req.Category) {
 		return
 	}
 
-	// Validate fileId exists in the database
-	exists, err := h.Repo.IsFileExists(req.FileID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate fileId"})
-		return
-	}
-	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "fileId does not exist"})
+	if req.FileIDs != nil {
+		if req.FileID != nil && *req.FileID != (*req.FileIDs)[0] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fileId must be the first of fileIds"})
+			return
+		}
+		if !h.validateFiles(c, *req.FileIDs) {
+			return
+		}
+	} else if req.FileID != nil && !h.validateFiles(c, []string{*req.FileID}) {
 		return
 	}
 
-	if err := h.Repo.UpdateProduct(parsedProductId, req); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	if err := h.Repo.UpdateProduct(parsedProductId, c.GetUint("userId"), ifMatchVersion(c), req); err != nil {
+		respondProductMutationError(c, err)
 		return
 	}
 
@@ -234,20 +530,9 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	response := dto.ProductResponse{
-		ProductID:        strconv.Itoa(updatedProduct.ID),
-		Name:             updatedProduct.Name,
-		Category:         updatedProduct.Category,
-		Qty:              updatedProduct.Qty,
-		Price:            updatedProduct.Price,
-		SKU:              updatedProduct.SKU,
-		FileID:           updatedProduct.File.FileID,
-		FileUri:          updatedProduct.File.FileUri,
-		FileThumbnailUri: updatedProduct.File.FileThumbnailUri,
-		CreatedAt:        updatedProduct.CreatedAt,
-		UpdatedAt:        updatedProduct.UpdatedAt,
-	}
+	response := toProductResponse(*updatedProduct)
 
+	c.Header("ETag", productETag(updatedProduct.Version))
 	c.JSON(http.StatusOK, response)
 }
 
@@ -264,11 +549,124 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	err = h.Repo.DeleteProduct(parsedProductId)
+	err = h.Repo.DeleteProduct(parsedProductId, c.GetUint("userId"), ifMatchVersion(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondProductMutationError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, "Product deleted")
 }
+
+// RestoreProduct brings back a deleted product that has not been purged yet.
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	parsedProductId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.Repo.RestoreProduct(parsedProductId, c.GetUint("userId")); err != nil {
+		respondProductMutationError(c, err)
+		return
+	}
+
+	product, err := h.Repo.GetProductById(parsedProductId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found after restore"})
+		return
+	}
+
+	c.Header("ETag", productETag(product.Version))
+	c.JSON(http.StatusOK, toProductResponse(*product))
+}
+
+func productETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// etagMatches reports whether any entity tag in a comma separated
+// If-None-Match list equals etag, using the weak comparison RFC 9110
+// prescribes for that header.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion turns the If-Match header into the product version the
+// repository should require: 0 when the header is absent or "*", and -1,
+// which never matches, when it is not one of our ETags.
+func ifMatchVersion(c *gin.Context) int {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0
+	}
+
+	version, err := strconv.Atoi(strings.Trim(header, "\""))
+	if err != nil || version < 1 || header != productETag(version) {
+		return -1
+	}
+	return version
+}
+
+// bindPatch decodes a JSON or JSON merge patch (RFC 7396) body. Members that
+// are absent stay nil and are left untouched; an explicit null would mean
+// "remove the field", which no product field allows, so it is rejected.
+func bindPatch(c *gin.Context) (dto.UpdateProductRequest, error) {
+	var req dto.UpdateProductRequest
+
+	body, err := c.GetRawData()
+	if err != nil {
+		return req, err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return req, fmt.Errorf("request body must be a JSON object: %v", err)
+	}
+
+	for key, value := range members {
+		if string(value) == "null" {
+			return req, fmt.Errorf("%s cannot be null", key)
+		}
+	}
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return req, err
+	}
+
+	if req.Name == nil && req.Category == nil && req.Qty == nil && req.Price == nil && req.SKU == nil &&
+		req.FileID == nil && req.FileIDs == nil && req.Archived == nil && req.AllowNegativeStock == nil &&
+		req.LowStockThreshold == nil {
+		return req, errors.New("request body must contain at least one field to update")
+	}
+
+	return req, nil
+}
+
+func respondProductMutationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, repositories.ErrProductForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not own this product"})
+	case errors.Is(err, repositories.ErrProductVersionMismatch):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Product has been modified, fetch it again and retry"})
+	case errors.Is(err, repositories.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+	case errors.Is(err, repositories.ErrProductHasVariants), errors.Is(err, repositories.ErrProductHasNoOptions),
+		errors.Is(err, repositories.ErrVariantOptionsMismatch), errors.Is(err, repositories.ErrVariantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrProductSKUExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "duplicate_sku"})
+	case errors.Is(err, repositories.ErrVariantAlreadyExists), errors.Is(err, repositories.ErrVariantInUse),
+		errors.Is(err, repositories.ErrInsufficientStock), errors.Is(err, repositories.ErrProductHasStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,569 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/middleware"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+	"tutuplapak/repositories/memory"
+	"tutuplapak/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testServer serves the product routes, behind the same JWT middleware as
+// the real router, and the purchase routes from an in-memory store.
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	store  *memory.Store
+	user   models.User
+	file   models.File
+	token  string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	utils.JWTSecret = []byte("test-secret")
+
+	store := memory.New()
+	user, err := store.CreateUser("seller@example.com", "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	file, err := store.CreateFile("https://example.com/a.jpg", "https://example.com/a_thumbnail.jpg")
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	token, err := utils.GenerateJWT(user.ID, user.Email)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	productHandler := &ProductHandler{
+		Repo:       store,
+		Categories: repositories.NewCategoryCache(store, time.Minute),
+	}
+
+	router := gin.New()
+	productRouter := router.Group("/v1/product")
+	productRouter.Use(middleware.JWTAuth())
+	productRouter.POST("/", productHandler.CreateProduct)
+	productRouter.GET("/", productHandler.GetProducts)
+	productRouter.GET("/low-stock", productHandler.GetLowStockProducts)
+	productRouter.POST("/import", productHandler.ImportProducts)
+	productRouter.GET("/:productId", productHandler.GetProduct)
+	productRouter.PATCH("/:productId", productHandler.UpdateProduct)
+	productRouter.DELETE("/:productId", productHandler.DeleteProduct)
+	productRouter.POST("/:productId/restore", productHandler.RestoreProduct)
+	productRouter.POST("/:productId/stock", productHandler.AdjustStock)
+	productRouter.GET("/:productId/stock/history", productHandler.GetStockHistory)
+	productRouter.POST("/:productId/variant", productHandler.CreateVariant)
+	productRouter.PATCH("/:productId/variant/:variantId", productHandler.UpdateVariant)
+
+	purchaseHandler := &PurchaseHandler{Repo: store, ReservationTTL: time.Hour}
+	router.POST("/v1/purchase", purchaseHandler.CreatePurchase)
+	router.POST("/v1/purchase/:purchaseId", purchaseHandler.ConfirmPurchase)
+
+	return &testServer{t: t, router: router, store: store, user: user, file: file, token: token}
+}
+
+// request builds a request from the seller, setting Content-Type when
+// contentType is not empty.
+func (s *testServer) request(method, path, contentType, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// do sends the request as the seller and decodes a JSON response into out
+// when it is not nil.
+func (s *testServer) do(method, path, contentType, body string, out interface{}) int {
+	s.t.Helper()
+
+	w := s.serve(s.request(method, path, contentType, body))
+
+	if out != nil {
+		data, _ := io.ReadAll(w.Body)
+		if err := json.Unmarshal(data, out); err != nil {
+			s.t.Fatalf("%s %s: decoding %q: %v", method, path, data, err)
+		}
+	}
+	return w.Code
+}
+
+// createProduct creates a product of the seller's straight in the store.
+func (s *testServer) createProduct(name, category, sku string, qty, price int) models.Product {
+	s.t.Helper()
+
+	product, err := s.store.CreateProduct(s.user.ID, dto.CreateProductRequest{
+		Name:     name,
+		Category: category,
+		Qty:      qty,
+		Price:    price,
+		SKU:      sku,
+		FileID:   s.file.FileID,
+		FileIDs:  []string{s.file.FileID},
+	})
+	if err != nil {
+		s.t.Fatalf("CreateProduct: %v", err)
+	}
+	return product
+}
+
+func TestCreateProduct(t *testing.T) {
+	s := newTestServer(t)
+
+	body := `{"name":"Coffee Beans","category":"Beverage","qty":10,"price":25000,"sku":"SKU-1","fileId":"` + s.file.FileID + `"}`
+	var created struct {
+		ProductID    string `json:"productId"`
+		SKU          string `json:"sku"`
+		AvailableQty int    `json:"availableQty"`
+		FileURI      string `json:"fileUri"`
+	}
+	if code := s.do(http.MethodPost, "/v1/product/", "application/json", body, &created); code != http.StatusCreated {
+		t.Fatalf("create: got status %d, want 201", code)
+	}
+	if created.SKU != "SKU-1" || created.AvailableQty != 10 || created.FileURI != s.file.FileUri {
+		t.Fatalf("create: got %+v", created)
+	}
+
+	var fetched struct {
+		Name string `json:"name"`
+	}
+	if code := s.do(http.MethodGet, "/v1/product/"+created.ProductID, "", "", &fetched); code != http.StatusOK || fetched.Name != "Coffee Beans" {
+		t.Fatalf("get: got status %d and %+v", code, fetched)
+	}
+
+	var conflict struct {
+		Code string `json:"code"`
+	}
+	if code := s.do(http.MethodPost, "/v1/product/", "application/json", body, &conflict); code != http.StatusConflict || conflict.Code != "duplicate_sku" {
+		t.Fatalf("create with a taken sku: got status %d and %+v, want 409 duplicate_sku", code, conflict)
+	}
+
+	invalid := strings.Replace(body, `"Beverage"`, `"Spaceships"`, 1)
+	if code := s.do(http.MethodPost, "/v1/product/", "application/json", invalid, nil); code != http.StatusBadRequest {
+		t.Fatalf("create with an unknown category: got status %d, want 400", code)
+	}
+	if code := s.do(http.MethodPost, "/v1/product/", "text/plain", body, nil); code != http.StatusBadRequest {
+		t.Fatalf("create with a text/plain body: got status %d, want 400", code)
+	}
+}
+
+func TestProductETag(t *testing.T) {
+	s := newTestServer(t)
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 10, 25000)
+	path := fmt.Sprintf("/v1/product/%d", product.ID)
+
+	w := s.serve(s.request(http.MethodGet, path, "", ""))
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"1"` {
+		t.Fatalf("get: got status %d and ETag %q, want 200 and \"1\"", w.Code, w.Header().Get("ETag"))
+	}
+
+	for match, want := range map[string]int{
+		`"1"`:        http.StatusNotModified,
+		`W/"1"`:      http.StatusNotModified,
+		`"7", W/"1"`: http.StatusNotModified,
+		`*`:          http.StatusNotModified,
+		`"2"`:        http.StatusOK,
+		`"2", W/"3"`: http.StatusOK,
+	} {
+		req := s.request(http.MethodGet, path, "", "")
+		req.Header.Set("If-None-Match", match)
+		if w := s.serve(req); w.Code != want || w.Header().Get("ETag") != `"1"` {
+			t.Fatalf("get with If-None-Match %s: got status %d and ETag %q, want %d", match, w.Code, w.Header().Get("ETag"), want)
+		}
+	}
+
+	req := s.request(http.MethodPatch, path, "application/merge-patch+json", `{"price":27000}`)
+	req.Header.Set("If-Match", `"1"`)
+	if w := s.serve(req); w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("patch: got status %d and ETag %q, want 200 and \"2\"", w.Code, w.Header().Get("ETag"))
+	}
+	if w := s.serve(s.request(http.MethodGet, path, "", "")); w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("get after patch: got ETag %q, want \"2\"", w.Header().Get("ETag"))
+	}
+
+	// A stale or malformed If-Match never matches
+	for _, match := range []string{`"1"`, `W/"2"`, `2`, `"two"`} {
+		req := s.request(http.MethodPatch, path, "application/merge-patch+json", `{"price":30000}`)
+		req.Header.Set("If-Match", match)
+		if w := s.serve(req); w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("patch with If-Match %s: got status %d, want 412", match, w.Code)
+		}
+
+		req = s.request(http.MethodDelete, path, "", "")
+		req.Header.Set("If-Match", match)
+		if w := s.serve(req); w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("delete with If-Match %s: got status %d, want 412", match, w.Code)
+		}
+	}
+	if got, err := s.store.GetProductById(product.ID); err != nil || got.Price != 27000 || got.DeletedAt != nil {
+		t.Fatalf("after failed preconditions: got %+v, %v", got, err)
+	}
+
+	req = s.request(http.MethodDelete, path, "", "")
+	req.Header.Set("If-Match", `"2"`)
+	if w := s.serve(req); w.Code != http.StatusOK {
+		t.Fatalf("delete with a current If-Match: got status %d, want 200", w.Code)
+	}
+}
+
+// productPage is the part of a product list response the tests look at.
+type productPage struct {
+	Data []struct {
+		ProductID string `json:"productId"`
+	} `json:"data"`
+	Meta  dto.ListMeta  `json:"meta"`
+	Links dto.ListLinks `json:"links"`
+}
+
+// walkProducts follows links.next from path to the last page and returns
+// the product ids in the order they were listed.
+func (s *testServer) walkProducts(path string) ([]string, []productPage) {
+	s.t.Helper()
+
+	var ids []string
+	var pages []productPage
+	for next := &path; next != nil; {
+		var page productPage
+		if code := s.do(http.MethodGet, *next, "", "", &page); code != http.StatusOK {
+			s.t.Fatalf("list %s: got status %d, want 200", *next, code)
+		}
+		if len(pages) > 10 {
+			s.t.Fatalf("list %s: links.next never ends", path)
+		}
+		for _, product := range page.Data {
+			ids = append(ids, product.ProductID)
+		}
+		pages = append(pages, page)
+		next = page.Links.Next
+	}
+	return ids, pages
+}
+
+// assertEveryProductOnce checks that ids lists each of products exactly once.
+func assertEveryProductOnce(t *testing.T, ids []string, products []models.Product) {
+	t.Helper()
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Fatalf("product %s is listed twice in %v", id, ids)
+		}
+		seen[id] = true
+	}
+	for _, product := range products {
+		if !seen[strconv.Itoa(product.ID)] {
+			t.Fatalf("product %d is missing from %v", product.ID, ids)
+		}
+	}
+	if len(ids) != len(products) {
+		t.Fatalf("listed %v, want %d products", ids, len(products))
+	}
+}
+
+func TestGetProductsOffsetPages(t *testing.T) {
+	s := newTestServer(t)
+	var products []models.Product
+	for i := 1; i <= 7; i++ {
+		products = append(products, s.createProduct(fmt.Sprintf("Product %d", i), "Beverage", fmt.Sprintf("SKU-%d", i), i, 1000*i))
+	}
+
+	ids, pages := s.walkProducts("/v1/product/?limit=3")
+	assertEveryProductOnce(t, ids, products)
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+
+	first, second, last := pages[0], pages[1], pages[2]
+	if first.Meta != (dto.ListMeta{Total: 7, Limit: 3, Offset: 0}) || first.Links.Prev != nil ||
+		first.Links.Next == nil || *first.Links.Next != "/v1/product/?limit=3&offset=3" {
+		t.Fatalf("first page: got %+v", first)
+	}
+	if second.Meta != (dto.ListMeta{Total: 7, Limit: 3, Offset: 3}) ||
+		second.Links.Prev == nil || *second.Links.Prev != "/v1/product/?limit=3&offset=0" {
+		t.Fatalf("second page: got %+v", second)
+	}
+	if last.Meta.Offset != 6 || len(last.Data) != 1 || last.Links.Next != nil || last.Links.Prev == nil {
+		t.Fatalf("last page: got %+v", last)
+	}
+
+	// The other filters are kept in the links
+	var filtered productPage
+	if code := s.do(http.MethodGet, "/v1/product/?limit=1&minPrice=5000", "", "", &filtered); code != http.StatusOK {
+		t.Fatalf("filtered list: got status %d, want 200", code)
+	}
+	if filtered.Meta.Total != 3 || filtered.Links.Next == nil || *filtered.Links.Next != "/v1/product/?limit=1&minPrice=5000&offset=1" {
+		t.Fatalf("filtered list: got %+v", filtered)
+	}
+}
+
+func TestGetProductsCursorPages(t *testing.T) {
+	s := newTestServer(t)
+	var products []models.Product
+	for i := 1; i <= 7; i++ {
+		products = append(products, s.createProduct(fmt.Sprintf("Product %d", i), "Beverage", fmt.Sprintf("SKU-%d", i), i, 1000*i))
+	}
+
+	ids, pages := s.walkProducts("/v1/product/?limit=3&cursor=")
+	assertEveryProductOnce(t, ids, products)
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	for i, page := range pages {
+		if page.Meta.Total != 7 || page.Meta.Offset != 0 || page.Links.Prev != nil {
+			t.Fatalf("page %d: got %+v", i, page)
+		}
+	}
+	// Newest first
+	if ids[0] != strconv.Itoa(products[6].ID) || ids[6] != strconv.Itoa(products[0].ID) {
+		t.Fatalf("cursor pages: got %v, want newest first", ids)
+	}
+
+	// Unlike offsets, a product created while paging shifts nothing: being
+	// newest, it sorts before the cursor and later pages are unchanged
+	next := *pages[0].Links.Next
+	s.createProduct("Product 8", "Beverage", "SKU-8", 8, 8000)
+	rest, _ := s.walkProducts(next)
+	assertEveryProductOnce(t, append(ids[:3:3], rest...), products)
+
+	for query, field := range map[string]string{
+		"cursor=&offset=3":        "offset",
+		"cursor=&sortBy=cheapest": "cursor",
+		"cursor=&q=coffee":        "cursor",
+		"cursor=not-a-cursor":     "cursor",
+		"cursor=bm90LWEtdGltZSw1": "cursor", // "not-a-time,5"
+	} {
+		var response struct {
+			Fields map[string]string `json:"fields"`
+		}
+		if code := s.do(http.MethodGet, "/v1/product/?"+query, "", "", &response); code != http.StatusBadRequest || response.Fields[field] == "" {
+			t.Fatalf("list with %s: got status %d and %+v, want 400 for %s", query, code, response, field)
+		}
+	}
+}
+
+func TestGetProductsBareList(t *testing.T) {
+	s := newTestServer(t)
+	for i := 1; i <= 4; i++ {
+		s.createProduct(fmt.Sprintf("Product %d", i), "Beverage", fmt.Sprintf("SKU-%d", i), i, 1000*i)
+	}
+
+	for _, accept := range []string{"application/json; version=1", "text/html, application/json;version=1", "*/*; version=1"} {
+		req := s.request(http.MethodGet, "/v1/product/?limit=3&offset=2", "", "")
+		req.Header.Set("Accept", accept)
+		w := s.serve(req)
+
+		var products []dto.ProductResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &products); err != nil {
+			t.Fatalf("Accept %q: decoding %q as a bare array: %v", accept, w.Body.String(), err)
+		}
+		if w.Code != http.StatusOK || len(products) != 2 || w.Header().Get("Vary") != "Accept" {
+			t.Fatalf("Accept %q: got status %d and %d products", accept, w.Code, len(products))
+		}
+	}
+
+	// Any other Accept gets the envelope
+	for _, accept := range []string{"", "application/json", "application/json; version=2"} {
+		req := s.request(http.MethodGet, "/v1/product/?limit=3", "", "")
+		req.Header.Set("Accept", accept)
+		w := s.serve(req)
+
+		var page productPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || page.Meta.Total != 4 || len(page.Data) != 3 {
+			t.Fatalf("Accept %q: got %q, %v", accept, w.Body.String(), err)
+		}
+	}
+}
+
+func TestGetProductsInvalidFilters(t *testing.T) {
+	s := newTestServer(t)
+
+	for query, field := range map[string]string{
+		"minPrice=5000&maxPrice=1000": "minPrice",
+		"minPrice=-1":                 "minPrice",
+		"maxPrice=cheap":              "maxPrice",
+		"inStock=maybe":               "inStock",
+		"createdAfter=2024-01-31":     "createdAfter",
+		"createdBefore=yesterday":     "createdBefore",
+		"createdAfter=2024-02-01T00:00:00Z&createdBefore=2024-01-01T00:00:00Z": "createdAfter",
+		"createdAfter=2024-01-01T00:00:00Z&createdBefore=2024-01-01T00:00:00Z": "createdAfter",
+		"category=Spaceships":               "category",
+		"category=Food&category=Spaceships": "category",
+		"sortBy=priciest":                   "sortBy",
+		"sortBy=sold-abc":                   "sortBy",
+	} {
+		var response struct {
+			Fields map[string]string `json:"fields"`
+		}
+		if code := s.do(http.MethodGet, "/v1/product/?"+query, "", "", &response); code != http.StatusBadRequest || response.Fields[field] == "" {
+			t.Fatalf("list with %s: got status %d and %+v, want 400 for %s", query, code, response, field)
+		}
+	}
+
+	var page productPage
+	query := "minPrice=1000&maxPrice=1000&createdAfter=2024-01-01T00:00:00Z&createdBefore=2024-01-01T00:00:01Z&category=Food&sortBy=cheapest&inStock=false"
+	if code := s.do(http.MethodGet, "/v1/product/?"+query, "", "", &page); code != http.StatusOK {
+		t.Fatalf("list with valid filters: got status %d, want 200", code)
+	}
+}
+
+func TestRestoreProduct(t *testing.T) {
+	s := newTestServer(t)
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 10, 25000)
+	path := fmt.Sprintf("/v1/product/%d", product.ID)
+
+	if code := s.do(http.MethodDelete, path, "", "", nil); code != http.StatusOK {
+		t.Fatalf("delete: got status %d, want 200", code)
+	}
+
+	// A bare POST without a body or Content-Type restores the product
+	var restored struct {
+		ProductID string `json:"productId"`
+	}
+	if code := s.do(http.MethodPost, path+"/restore", "", "", &restored); code != http.StatusOK || restored.ProductID != strconv.Itoa(product.ID) {
+		t.Fatalf("restore: got status %d and %+v, want 200", code, restored)
+	}
+	if code := s.do(http.MethodGet, path, "", "", nil); code != http.StatusOK {
+		t.Fatalf("get after restore: got status %d, want 200", code)
+	}
+
+	// Requests with a body still have to say it is JSON
+	if code := s.do(http.MethodPost, path+"/restore", "text/plain", "{}", nil); code != http.StatusBadRequest {
+		t.Fatalf("restore with a text/plain body: got status %d, want 400", code)
+	}
+}
+
+func TestFirstVariantAfterEmptyingStock(t *testing.T) {
+	s := newTestServer(t)
+	product, err := s.store.CreateProduct(s.user.ID, dto.CreateProductRequest{
+		Name:     "Plain T-Shirt",
+		Category: "Clothes",
+		Qty:      5,
+		Price:    50000,
+		SKU:      "TEE",
+		FileID:   s.file.FileID,
+		FileIDs:  []string{s.file.FileID},
+		Options:  []string{"size"},
+	})
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	path := fmt.Sprintf("/v1/product/%d", product.ID)
+	variant := `{"options":{"size":"M"},"sku":"TEE-M","price":50000,"qty":5}`
+
+	if code := s.do(http.MethodPost, path+"/variant", "application/json", variant, nil); code != http.StatusConflict {
+		t.Fatalf("first variant of a product with stock: got status %d, want 409", code)
+	}
+
+	// Taking qty to 0 is an ordinary update
+	if code := s.do(http.MethodPatch, path, "application/json", `{"qty":-1}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("patch qty -1: got status %d, want 400", code)
+	}
+	if code := s.do(http.MethodPatch, path, "application/json", `{"qty":0}`, nil); code != http.StatusOK {
+		t.Fatalf("patch qty 0: got status %d, want 200", code)
+	}
+	if code := s.do(http.MethodPost, path+"/variant", "application/json", variant, nil); code != http.StatusCreated {
+		t.Fatalf("first variant after emptying stock: got status %d, want 201", code)
+	}
+}
+
+func TestMalformedFileIds(t *testing.T) {
+	s := newTestServer(t)
+	product, err := s.store.CreateProduct(s.user.ID, dto.CreateProductRequest{
+		Name:     "Plain T-Shirt",
+		Category: "Clothes",
+		SKU:      "TEE",
+		FileID:   s.file.FileID,
+		FileIDs:  []string{s.file.FileID},
+		Options:  []string{"size"},
+		Variants: []dto.CreateVariantRequest{{Options: map[string]string{"size": "M"}, SKU: "TEE-M", Price: 50000, FileID: s.file.FileID}},
+	})
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	path := fmt.Sprintf("/v1/product/%d", product.ID)
+	variantPath := fmt.Sprintf("%s/variant/%d", path, product.Variants[0].ID)
+
+	for _, request := range []struct{ method, path, body string }{
+		{http.MethodPost, "/v1/product/", `{"name":"Coffee Beans","category":"Beverage","qty":1,"price":25000,"sku":"SKU-1","fileId":"not-a-uuid"}`},
+		{http.MethodPost, "/v1/product/", `{"name":"Coffee Beans","category":"Beverage","qty":1,"price":25000,"sku":"SKU-1","fileIds":["not-a-uuid"]}`},
+		{http.MethodPatch, path, `{"fileId":"not-a-uuid"}`},
+		{http.MethodPatch, path, `{"fileId":""}`},
+		{http.MethodPatch, path, `{"fileIds":["` + s.file.FileID + `","not-a-uuid"]}`},
+		{http.MethodPost, path + "/variant", `{"options":{"size":"L"},"sku":"TEE-L","price":50000,"fileId":"not-a-uuid"}`},
+		{http.MethodPatch, variantPath, `{"fileId":"not-a-uuid"}`},
+	} {
+		if code := s.do(request.method, request.path, "application/json", request.body, nil); code != http.StatusBadRequest {
+			t.Fatalf("%s %s %s: got status %d, want 400", request.method, request.path, request.body, code)
+		}
+	}
+
+	// An empty fileId still takes a variant's image off
+	var variant struct {
+		FileID string `json:"fileId"`
+	}
+	if code := s.do(http.MethodPatch, variantPath, "application/json", `{"fileId":""}`, &variant); code != http.StatusOK || variant.FileID != "" {
+		t.Fatalf("patch variant fileId to empty: got status %d and %+v, want 200", code, variant)
+	}
+}
+
+func TestPatchEmptySKU(t *testing.T) {
+	s := newTestServer(t)
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 10, 25000)
+	path := fmt.Sprintf("/v1/product/%d", product.ID)
+
+	for _, contentType := range []string{"application/json", "application/merge-patch+json"} {
+		if code := s.do(http.MethodPatch, path, contentType, `{"sku":""}`, nil); code != http.StatusBadRequest {
+			t.Fatalf("patch %s with an empty sku: got status %d, want 400", contentType, code)
+		}
+	}
+	if got, err := s.store.GetProductById(product.ID); err != nil || got.SKU != "SKU-1" {
+		t.Fatalf("after rejected patches: got %+v, %v, want sku SKU-1", got, err)
+	}
+}
+
+func TestPatchVariantEmptySKU(t *testing.T) {
+	s := newTestServer(t)
+	product, err := s.store.CreateProduct(s.user.ID, dto.CreateProductRequest{
+		Name:     "Plain T-Shirt",
+		Category: "Clothes",
+		SKU:      "TEE",
+		FileID:   s.file.FileID,
+		FileIDs:  []string{s.file.FileID},
+		Options:  []string{"size"},
+		Variants: []dto.CreateVariantRequest{{Options: map[string]string{"size": "M"}, SKU: "TEE-M", Price: 50000}},
+	})
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	variantPath := fmt.Sprintf("/v1/product/%d/variant/%d", product.ID, product.Variants[0].ID)
+
+	if code := s.do(http.MethodPatch, variantPath, "application/json", `{"sku":""}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("patch variant with an empty sku: got status %d, want 400", code)
+	}
+	if got, err := s.store.GetProductById(product.ID); err != nil || got.Variants[0].SKU != "TEE-M" {
+		t.Fatalf("after rejected patch: got %+v, %v, want variant sku TEE-M", got, err)
+	}
+}
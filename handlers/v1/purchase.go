@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"tutuplapak/config"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type PurchaseHandler struct {
+	Repo           repositories.PurchaseStore
+	ReservationTTL time.Duration // how long a new purchase holds its stock
+}
+
+func NewPurchaseHandler(db *sql.DB, cfg *config.Config) *PurchaseHandler {
+	return &PurchaseHandler{
+		Repo:           repositories.NewPurchaseRepository(db),
+		ReservationTTL: cfg.ReservationTTL,
+	}
+}
+
+func (h *PurchaseHandler) CreatePurchase(c *gin.Context) {
+	var req dto.CreatePurchaseRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	contactTag := "email"
+	if req.SenderContactType == "phone" {
+		contactTag = "e164"
+	}
+	if err := validator.New().Var(req.SenderContactDetail, contactTag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "senderContactDetail must be a valid " + req.SenderContactType})
+		return
+	}
+
+	purchase, err := h.Repo.CreatePurchase(req, time.Now().Add(h.ReservationTTL))
+	switch {
+	case errors.Is(err, repositories.ErrProductNotFound), errors.Is(err, repositories.ErrInsufficientStock),
+		errors.Is(err, repositories.ErrVariantNotFound), errors.Is(err, repositories.ErrVariantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toPurchaseResponse(purchase))
+}
+
+func (h *PurchaseHandler) ConfirmPurchase(c *gin.Context) {
+	purchaseId := c.Param("purchaseId")
+	if err := validator.New().Var(purchaseId, "uuid"); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase not found"})
+		return
+	}
+
+	var req dto.ConfirmPurchaseRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	purchase, err := h.Repo.ConfirmPayment(purchaseId, req.FileIDs)
+	switch {
+	case errors.Is(err, repositories.ErrPurchaseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase not found"})
+		return
+	case errors.Is(err, repositories.ErrPurchaseAlreadyPaid), errors.Is(err, repositories.ErrPurchaseExpired),
+		errors.Is(err, repositories.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repositories.ErrPaymentProofMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repositories.ErrFileNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fileId does not exist"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, toPurchaseResponse(purchase))
+}
+
+// toPurchaseResponse lists the purchased items and groups their totals by
+// seller, in the order each seller first appears, so the buyer knows how much
+// to transfer to which bank account.
+func toPurchaseResponse(purchase models.Purchase) dto.PurchaseResponse {
+	response := dto.PurchaseResponse{
+		PurchaseID:     purchase.PublicID,
+		Status:         purchase.Status,
+		PurchasedItems: make([]dto.PurchasedItemResponse, 0, len(purchase.Items)),
+		TotalPrice:     purchase.TotalPrice,
+		PaymentDetails: make([]dto.PaymentDetailResponse, 0),
+		ReservedUntil:  purchase.ReservedUntil,
+	}
+
+	sellerIndex := make(map[uint]int)
+	for _, item := range purchase.Items {
+		purchased := dto.PurchasedItemResponse{
+			ProductResponse: toProductResponse(item.Product),
+			PurchasedQty:    item.Qty,
+		}
+		if item.VariantID != 0 {
+			variant := toVariantResponse(item.Product.Options, item.Variant)
+			purchased.Variant = &variant
+		}
+		response.PurchasedItems = append(response.PurchasedItems, purchased)
+
+		i, ok := sellerIndex[item.SellerID]
+		if !ok {
+			i = len(response.PaymentDetails)
+			sellerIndex[item.SellerID] = i
+			response.PaymentDetails = append(response.PaymentDetails, dto.PaymentDetailResponse{
+				SellerID:          strconv.FormatUint(uint64(item.SellerID), 10),
+				BankAccountName:   item.Seller.BankAccountName,
+				BankAccountHolder: item.Seller.BankAccountHolder,
+				BankAccountNumber: item.Seller.BankAccountNumber,
+			})
+		}
+		response.PaymentDetails[i].TotalPrice += item.Price * float64(item.Qty)
+	}
+
+	return response
+}
@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"tutuplapak/dto"
+)
+
+func TestPurchase(t *testing.T) {
+	s := newTestServer(t)
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 5, 25000)
+
+	body := fmt.Sprintf(`{"purchasedItems":[{"productId":"%d","qty":2}],"senderName":"Buyer","senderContactType":"email","senderContactDetail":"buyer@example.com"}`, product.ID)
+	var purchase dto.PurchaseResponse
+	if code := s.do(http.MethodPost, "/v1/purchase", "application/json", body, &purchase); code != http.StatusCreated {
+		t.Fatalf("create: got status %d, want 201", code)
+	}
+	if purchase.TotalPrice != 50000 || len(purchase.PaymentDetails) != 1 {
+		t.Fatalf("create: got %+v", purchase)
+	}
+
+	// Purchases are only reachable by the unguessable id checkout returned
+	proof := `{"fileIds":["` + s.file.FileID + `"]}`
+	for _, guess := range []string{"1", "00000000-0000-4000-8000-000000000000"} {
+		if code := s.do(http.MethodPost, "/v1/purchase/"+guess, "application/json", proof, nil); code != http.StatusNotFound {
+			t.Fatalf("confirm purchase %s: got status %d, want 404", guess, code)
+		}
+	}
+
+	// Confirming payment changes the purchase, it creates nothing
+	path := "/v1/purchase/" + purchase.PurchaseID
+	var paid dto.PurchaseResponse
+	if code := s.do(http.MethodPost, path, "application/json", proof, &paid); code != http.StatusOK {
+		t.Fatalf("confirm: got status %d, want 200", code)
+	}
+	if paid.PurchaseID != purchase.PurchaseID || paid.Status == purchase.Status {
+		t.Fatalf("confirm: got %+v after %+v", paid, purchase)
+	}
+	if got, err := s.store.GetProductById(product.ID); err != nil || got.Qty != 3 || got.ReservedQty != 0 {
+		t.Fatalf("after confirm: got %+v, %v", got, err)
+	}
+
+	if code := s.do(http.MethodPost, path, "application/json", proof, nil); code != http.StatusConflict {
+		t.Fatalf("confirm twice: got status %d, want 409", code)
+	}
+}
+
+func TestPurchaseKeepsProductETag(t *testing.T) {
+	s := newTestServer(t)
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 5, 25000)
+	path := fmt.Sprintf("/v1/product/%d", product.ID)
+	etag := s.serve(s.request(http.MethodGet, path, "", "")).Header().Get("ETag")
+
+	body := fmt.Sprintf(`{"purchasedItems":[{"productId":"%d","qty":2}],"senderName":"Buyer","senderContactType":"email","senderContactDetail":"buyer@example.com"}`, product.ID)
+	if code := s.do(http.MethodPost, "/v1/purchase", "application/json", body, nil); code != http.StatusCreated {
+		t.Fatalf("create: got status %d, want 201", code)
+	}
+
+	// A checkout only reserves stock, so the seller's edit still applies
+	req := s.request(http.MethodPatch, path, "application/merge-patch+json", `{"price":27000}`)
+	req.Header.Set("If-Match", etag)
+	if w := s.serve(req); w.Code != http.StatusOK {
+		t.Fatalf("patch with the ETag from before the checkout: got status %d, want 200", w.Code)
+	}
+}
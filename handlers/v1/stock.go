@@ -0,0 +1,140 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func toStockMovementResponse(movement models.StockMovement) dto.StockMovementResponse {
+	response := dto.StockMovementResponse{
+		MovementID: strconv.Itoa(movement.ID),
+		ProductID:  strconv.Itoa(movement.ProductID),
+		Reason:     movement.Reason,
+		Delta:      movement.Delta,
+		QtyAfter:   movement.QtyAfter,
+		Note:       movement.Note,
+		CreatedAt:  movement.CreatedAt,
+	}
+	if movement.VariantID != 0 {
+		response.VariantID = strconv.Itoa(movement.VariantID)
+	}
+	if movement.PurchaseID != 0 {
+		response.PurchaseID = movement.PurchasePublicID
+	}
+	if movement.UserID != 0 {
+		response.UserID = strconv.FormatUint(uint64(movement.UserID), 10)
+	}
+	return response
+}
+
+// AdjustStock records a manual stock movement, e.g. a delivery coming in
+// or a stocktake correction. Sales are only recorded by purchases.
+func (h *ProductHandler) AdjustStock(c *gin.Context) {
+	productId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	var req dto.StockAdjustmentRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	if (req.Reason == models.StockMovementRestock || req.Reason == models.StockMovementReturn) && req.Delta < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a " + req.Reason + " must add stock"})
+		return
+	}
+
+	movement, err := h.Repo.AdjustStock(productId, c.GetUint("userId"), req)
+	if err != nil {
+		respondProductMutationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toStockMovementResponse(movement))
+}
+
+// GetStockHistory lists the product's stock movements, newest first, to
+// its owner.
+func (h *ProductHandler) GetStockHistory(c *gin.Context) {
+	productId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	movements, total, err := h.Repo.StockHistory(productId, c.GetUint("userId"), limit, offset)
+	if err != nil {
+		respondProductMutationError(c, err)
+		return
+	}
+
+	response := dto.StockHistoryResponse{
+		Data: make([]dto.StockMovementResponse, 0, len(movements)),
+		Meta: dto.ListMeta{Total: total, Limit: limit, Offset: offset},
+	}
+	for _, movement := range movements {
+		response.Data = append(response.Data, toStockMovementResponse(movement))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLowStockProducts lists the seller's own products whose stock has
+// fallen to their low stock threshold, the least stocked first.
+func (h *ProductHandler) GetLowStockProducts(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	filters := map[string]string{
+		"user_id":   strconv.FormatUint(uint64(c.GetUint("userId")), 10),
+		"low_stock": "true",
+	}
+	total, err := h.Repo.CountProducts(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters["sort_by"] = "lowest_stock"
+	filters["limit"] = strconv.Itoa(limit)
+	filters["offset"] = strconv.Itoa(offset)
+	products, err := h.Repo.FilterProducts(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := dto.LowStockResponse{
+		Data: make([]dto.ProductResponse, 0, len(products)),
+		Meta: dto.ListMeta{Total: total, Limit: limit, Offset: offset},
+	}
+	for _, product := range products {
+		response.Data = append(response.Data, toProductResponse(product))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
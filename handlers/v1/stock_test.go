@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"tutuplapak/dto"
+	"tutuplapak/utils"
+)
+
+func TestAdjustStock(t *testing.T) {
+	s := newTestServer(t)
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 5, 25000)
+	path := fmt.Sprintf("/v1/product/%d/stock", product.ID)
+
+	var movement dto.StockMovementResponse
+	body := `{"delta":10,"reason":"restock","note":"delivery"}`
+	if code := s.do(http.MethodPost, path, "application/json", body, &movement); code != http.StatusCreated {
+		t.Fatalf("restock: got status %d, want 201", code)
+	}
+	if movement.Delta != 10 || movement.QtyAfter != 15 || movement.Reason != "restock" || movement.Note != "delivery" || movement.UserID == "" {
+		t.Fatalf("restock: got %+v", movement)
+	}
+
+	movement = dto.StockMovementResponse{}
+	if code := s.do(http.MethodPost, path, "application/json", `{"delta":-3,"reason":"correction"}`, &movement); code != http.StatusCreated || movement.QtyAfter != 12 {
+		t.Fatalf("correction: got status %d and %+v, want 201 with 12 left", code, movement)
+	}
+
+	for _, body := range []string{
+		`{"delta":0,"reason":"adjustment"}`,
+		`{"delta":1,"reason":"sale"}`,
+		`{"delta":-1,"reason":"restock"}`,
+		`{"delta":-1,"reason":"return"}`,
+		`{"delta":1}`,
+	} {
+		if code := s.do(http.MethodPost, path, "application/json", body, nil); code != http.StatusBadRequest {
+			t.Fatalf("adjust with %s: got status %d, want 400", body, code)
+		}
+	}
+
+	// Stock can't go below zero unless the product allows it
+	if code := s.do(http.MethodPost, path, "application/json", `{"delta":-13,"reason":"adjustment"}`, nil); code != http.StatusConflict {
+		t.Fatalf("adjust below zero: got status %d, want 409", code)
+	}
+	if code := s.do(http.MethodPatch, fmt.Sprintf("/v1/product/%d", product.ID), "application/json", `{"allowNegativeStock":true}`, nil); code != http.StatusOK {
+		t.Fatalf("allow negative stock: got status %d, want 200", code)
+	}
+	movement = dto.StockMovementResponse{}
+	if code := s.do(http.MethodPost, path, "application/json", `{"delta":-13,"reason":"adjustment"}`, &movement); code != http.StatusCreated || movement.QtyAfter != -1 {
+		t.Fatalf("adjust below zero when allowed: got status %d and %+v, want 201 with -1 left", code, movement)
+	}
+
+	if got, err := s.store.GetProductById(product.ID); err != nil || got.Qty != -1 {
+		t.Fatalf("after adjustments: got %+v, %v, want qty -1", got, err)
+	}
+}
+
+func TestStockHistory(t *testing.T) {
+	s := newTestServer(t)
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 5, 25000)
+	path := fmt.Sprintf("/v1/product/%d/stock", product.ID)
+
+	for _, body := range []string{`{"delta":10,"reason":"restock"}`, `{"delta":-2,"reason":"adjustment"}`} {
+		if code := s.do(http.MethodPost, path, "application/json", body, nil); code != http.StatusCreated {
+			t.Fatalf("adjust with %s: got status %d, want 201", body, code)
+		}
+	}
+
+	var history dto.StockHistoryResponse
+	if code := s.do(http.MethodGet, path+"/history", "", "", &history); code != http.StatusOK {
+		t.Fatalf("history: got status %d, want 200", code)
+	}
+	if history.Meta.Total != 3 || len(history.Data) != 3 {
+		t.Fatalf("history: got %d of %d movements, want 3", len(history.Data), history.Meta.Total)
+	}
+	for i, want := range []struct {
+		reason string
+		after  int
+	}{{"adjustment", 13}, {"restock", 15}, {"restock", 5}} {
+		if got := history.Data[i]; got.Reason != want.reason || got.QtyAfter != want.after {
+			t.Fatalf("movement %d: got %+v, want %s leaving %d", i, got, want.reason, want.after)
+		}
+	}
+
+	history = dto.StockHistoryResponse{}
+	if code := s.do(http.MethodGet, path+"/history?limit=1&offset=1", "", "", &history); code != http.StatusOK || len(history.Data) != 1 || history.Data[0].QtyAfter != 15 {
+		t.Fatalf("history page: got status %d and %+v", code, history)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=101", "?offset=-1", "?limit=x"} {
+		if code := s.do(http.MethodGet, path+"/history"+query, "", "", nil); code != http.StatusBadRequest {
+			t.Fatalf("history%s: got status %d, want 400", query, code)
+		}
+	}
+}
+
+func TestStockOwnership(t *testing.T) {
+	s := newTestServer(t)
+	product := s.createProduct("Coffee Beans", "Beverage", "SKU-1", 5, 25000)
+
+	other, err := s.store.CreateUser("other@example.com", "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	otherToken, err := utils.GenerateJWT(other.ID, other.Email)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	for _, request := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, fmt.Sprintf("/v1/product/%d/stock", product.ID), `{"delta":1,"reason":"restock"}`, http.StatusForbidden},
+		{http.MethodGet, fmt.Sprintf("/v1/product/%d/stock/history", product.ID), "", http.StatusForbidden},
+		{http.MethodPost, fmt.Sprintf("/v1/product/%d/stock", product.ID+1000), `{"delta":1,"reason":"restock"}`, http.StatusNotFound},
+		{http.MethodGet, fmt.Sprintf("/v1/product/%d/stock/history", product.ID+1000), "", http.StatusNotFound},
+		{http.MethodPost, "/v1/product/abc/stock", `{"delta":1,"reason":"restock"}`, http.StatusNotFound},
+		{http.MethodGet, "/v1/product/abc/stock/history", "", http.StatusNotFound},
+	} {
+		req := s.request(request.method, request.path, "application/json", request.body)
+		req.Header.Set("Authorization", "Bearer "+otherToken)
+		if w := s.serve(req); w.Code != request.want {
+			t.Fatalf("%s %s as another seller: got status %d, want %d", request.method, request.path, w.Code, request.want)
+		}
+	}
+
+	if got, err := s.store.GetProductById(product.ID); err != nil || got.Qty != 5 {
+		t.Fatalf("after refused adjustments: got %+v, %v, want qty 5", got, err)
+	}
+}
+
+func TestLowStockProducts(t *testing.T) {
+	s := newTestServer(t)
+	threshold := func(product int, value int) {
+		t.Helper()
+		body := fmt.Sprintf(`{"lowStockThreshold":%d}`, value)
+		if code := s.do(http.MethodPatch, fmt.Sprintf("/v1/product/%d", product), "application/json", body, nil); code != http.StatusOK {
+			t.Fatalf("set threshold: got status %d, want 200", code)
+		}
+	}
+
+	tea := s.createProduct("Tea", "Beverage", "SKU-1", 4, 10000)
+	coffee := s.createProduct("Coffee Beans", "Beverage", "SKU-2", 2, 25000)
+	milk := s.createProduct("Milk", "Beverage", "SKU-3", 9, 15000)
+	s.createProduct("Sugar", "Food", "SKU-4", 1, 5000)
+	threshold(tea.ID, 5)
+	threshold(coffee.ID, 5)
+	threshold(milk.ID, 5)
+
+	// Another seller's low stock never shows up
+	other, err := s.store.CreateUser("other@example.com", "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.store.CreateProduct(other.ID, dto.CreateProductRequest{
+		Name: "Cocoa", Category: "Beverage", Qty: 1, Price: 1000, SKU: "SKU-9", LowStockThreshold: 5,
+	}); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	var response dto.LowStockResponse
+	if code := s.do(http.MethodGet, "/v1/product/low-stock", "", "", &response); code != http.StatusOK {
+		t.Fatalf("low stock: got status %d, want 200", code)
+	}
+	if response.Meta != (dto.ListMeta{Total: 2, Limit: 20, Offset: 0}) {
+		t.Fatalf("low stock meta: got %+v", response.Meta)
+	}
+	if len(response.Data) != 2 || response.Data[0].SKU != "SKU-2" || response.Data[1].SKU != "SKU-1" {
+		t.Fatalf("low stock: got %+v, want SKU-2 then SKU-1", response.Data)
+	}
+
+	response = dto.LowStockResponse{}
+	if code := s.do(http.MethodGet, "/v1/product/low-stock?limit=1&offset=1", "", "", &response); code != http.StatusOK {
+		t.Fatalf("low stock page: got status %d, want 200", code)
+	}
+	if response.Meta.Total != 2 || len(response.Data) != 1 || response.Data[0].SKU != "SKU-1" {
+		t.Fatalf("low stock page: got %+v", response)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=101", "?limit=abc", "?offset=-1"} {
+		if code := s.do(http.MethodGet, "/v1/product/low-stock"+query, "", "", nil); code != http.StatusBadRequest {
+			t.Fatalf("low stock%s: got status %d, want 400", query, code)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler struct {
+	Repo  repositories.UserStore
+	Files repositories.FileStore
+}
+
+func NewUserHandler(db *sql.DB) *UserHandler {
+	return &UserHandler{
+		Repo:  repositories.NewUserRepository(db),
+		Files: repositories.NewFileRepository(db),
+	}
+}
+
+func toUserResponse(user models.User) dto.UserResponse {
+	return dto.UserResponse{
+		Email:             user.Email,
+		Phone:             user.Phone,
+		FileID:            user.File.FileID,
+		FileUri:           user.File.FileUri,
+		FileThumbnailUri:  user.File.FileThumbnailUri,
+		BankAccountName:   user.BankAccountName,
+		BankAccountHolder: user.BankAccountHolder,
+		BankAccountNumber: user.BankAccountNumber,
+	}
+}
+
+func (h *UserHandler) GetUser(c *gin.Context) {
+	user, err := h.Repo.GetUserById(c.GetUint("userId"))
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	var req dto.UpdateUserRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if req.FileID != "" {
+		// Validate fileId exists in the database
+		exists, err := h.Files.IsFileExists(req.FileID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate fileId"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fileId does not exist"})
+			return
+		}
+	}
+
+	user, err := h.Repo.UpdateProfile(c.GetUint("userId"), req)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func (h *UserHandler) LinkEmail(c *gin.Context) {
+	var req dto.LinkEmailRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	user, err := h.Repo.LinkEmail(c.GetUint("userId"), req.Email)
+	h.respondLinked(c, user, err, "email")
+}
+
+func (h *UserHandler) LinkPhone(c *gin.Context) {
+	var req dto.LinkPhoneRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	user, err := h.Repo.LinkPhone(c.GetUint("userId"), req.Phone)
+	h.respondLinked(c, user, err, "phone")
+}
+
+func (h *UserHandler) respondLinked(c *gin.Context, user models.User, err error, identifier string) {
+	switch {
+	case errors.Is(err, repositories.ErrIdentifierLinked):
+		c.JSON(http.StatusConflict, gin.H{"error": "the account already has a " + identifier})
+	case errors.Is(err, repositories.ErrUserAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": identifier + " is already used by another account"})
+	case errors.Is(err, repositories.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, toUserResponse(user))
+	}
+}
@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"tutuplapak/middleware"
+	"tutuplapak/repositories/memory"
+	"tutuplapak/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLinkIdentifier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utils.JWTSecret = []byte("test-secret")
+
+	store := memory.New()
+	user, err := store.CreateUser("seller@example.com", "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	token, err := utils.GenerateJWT(user.ID, user.Email)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	userHandler := &UserHandler{Repo: store, Files: store}
+	router := gin.New()
+	userRouter := router.Group("/v1/user")
+	userRouter.Use(middleware.JWTAuth())
+	userRouter.POST("/link/email", userHandler.LinkEmail)
+	userRouter.POST("/link/phone", userHandler.LinkPhone)
+
+	link := func(path, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A missing identifier is attached
+	if code := link("/v1/user/link/phone", `{"phone":"+6281234567890"}`); code != http.StatusOK {
+		t.Fatalf("link phone: got status %d, want 200", code)
+	}
+
+	// An identifier the account already has is never replaced
+	if code := link("/v1/user/link/email", `{"email":"thief@example.com"}`); code != http.StatusConflict {
+		t.Fatalf("link email to an account with one: got status %d, want 409", code)
+	}
+	if code := link("/v1/user/link/phone", `{"phone":"+6289999999999"}`); code != http.StatusConflict {
+		t.Fatalf("link phone to an account with one: got status %d, want 409", code)
+	}
+
+	got, err := store.GetUserById(user.ID)
+	if err != nil || got.Email != "seller@example.com" || got.Phone != "+6281234567890" {
+		t.Fatalf("after linking: got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateVariant adds a variant to a product created with options. The
+// product's qty and price follow its variants from then on.
+func (h *ProductHandler) CreateVariant(c *gin.Context) {
+	productId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	var req dto.CreateVariantRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	if req.FileID != "" && !h.validateFiles(c, []string{req.FileID}) {
+		return
+	}
+
+	variant, err := h.Repo.CreateVariant(productId, c.GetUint("userId"), req)
+	if err != nil {
+		respondProductMutationError(c, err)
+		return
+	}
+
+	h.respondVariant(c, http.StatusCreated, productId, variant)
+}
+
+func (h *ProductHandler) UpdateVariant(c *gin.Context) {
+	productId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	variantId, err := strconv.Atoi(c.Param("variantId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	}
+
+	var req dto.UpdateVariantRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	if req.SKU == nil && req.Price == nil && req.Qty == nil && req.FileID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request body must contain at least one field to update"})
+		return
+	}
+	if req.FileID != nil && *req.FileID != "" && !h.validateFiles(c, []string{*req.FileID}) {
+		return
+	}
+
+	variant, err := h.Repo.UpdateVariant(productId, variantId, c.GetUint("userId"), req)
+	if err != nil {
+		respondProductMutationError(c, err)
+		return
+	}
+
+	h.respondVariant(c, http.StatusOK, productId, variant)
+}
+
+// DeleteVariant removes a variant nobody has bought yet; sold out variants
+// can be kept at qty 0 instead.
+func (h *ProductHandler) DeleteVariant(c *gin.Context) {
+	productId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	variantId, err := strconv.Atoi(c.Param("variantId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	}
+
+	if err := h.Repo.DeleteVariant(productId, variantId, c.GetUint("userId")); err != nil {
+		respondProductMutationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, "Variant deleted")
+}
+
+// respondVariant writes the variant with its options named after the
+// product's, along with the product's new ETag since its totals changed.
+func (h *ProductHandler) respondVariant(c *gin.Context, status, productId int, variant models.ProductVariant) {
+	product, err := h.Repo.GetProductById(productId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", productETag(product.Version))
+	c.JSON(status, toVariantResponse(product.Options, variant))
+}
@@ -0,0 +1,33 @@
+// Package jobs holds the background work the server runs alongside the
+// API.
+package jobs
+
+import (
+	"log"
+	"time"
+	"tutuplapak/repositories"
+)
+
+// PurgeDeletedProducts permanently removes products that were soft deleted
+// more than retention ago, checking every interval. It blocks, so run it in
+// its own goroutine. A retention of zero or less keeps deleted products
+// forever.
+func PurgeDeletedProducts(products repositories.ProductStore, retention, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		log.Println("Purging deleted products is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := products.PurgeDeletedProducts(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Failed to purge deleted products: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted products", purged)
+		}
+		<-ticker.C
+	}
+}
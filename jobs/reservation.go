@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"log"
+	"time"
+	"tutuplapak/repositories"
+)
+
+// ExpireReservations gives the stock held by unpaid purchases back once their
+// reservation runs out, checking every interval. It blocks, so run it in its
+// own goroutine.
+func ExpireReservations(purchases repositories.PurchaseStore, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Expiring stock reservations is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := purchases.ExpireReservations(time.Now())
+		if err != nil {
+			log.Printf("Failed to expire stock reservations: %v", err)
+		} else if expired > 0 {
+			log.Printf("Expired %d unpaid purchases", expired)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+	"tutuplapak/notify"
+	"tutuplapak/repositories"
+)
+
+// stockAlertBatch caps how many alerts one round delivers.
+const stockAlertBatch = 100
+
+// DeliverStockAlerts sends pending low stock alerts through notifier every
+// interval. It blocks, so run it in its own goroutine.
+func DeliverStockAlerts(alerts repositories.StockAlertStore, notifier notify.Notifier, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Delivering stock alerts is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if delivered, err := DeliverPendingStockAlerts(alerts, notifier); err != nil {
+			log.Printf("Failed to deliver stock alerts: %v", err)
+		} else if delivered > 0 {
+			log.Printf("Delivered %d stock alerts", delivered)
+		}
+		<-ticker.C
+	}
+}
+
+// DeliverPendingStockAlerts makes one delivery round, returning how many
+// alerts were sent. An alert the notifier fails on stays pending for the
+// next round.
+func DeliverPendingStockAlerts(alerts repositories.StockAlertStore, notifier notify.Notifier) (int, error) {
+	pending, err := alerts.PendingStockAlerts(stockAlertBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, alert := range pending {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := notifier.NotifyLowStock(ctx, alert)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to deliver stock alert %d: %v", alert.ID, err)
+			continue
+		}
+
+		if err := alerts.MarkStockAlertDelivered(alert.ID); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"tutuplapak/dto"
+	"tutuplapak/notify"
+	"tutuplapak/repositories/memory"
+)
+
+func TestDeliverPendingStockAlerts(t *testing.T) {
+	store := memory.New()
+	user, err := store.CreateUser("seller@example.com", "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	product, err := store.CreateProduct(user.ID, dto.CreateProductRequest{
+		Name: "Coffee Beans", Category: "Beverage", Qty: 10, Price: 25000, SKU: "SKU-1", LowStockThreshold: 5,
+	})
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	if _, err := store.AdjustStock(product.ID, user.ID, dto.StockAdjustmentRequest{Delta: -6, Reason: "adjustment"}); err != nil {
+		t.Fatalf("AdjustStock: %v", err)
+	}
+
+	// A failing notifier leaves the alert pending for the next round
+	notifier := &notify.Recorder{Err: errors.New("webhook down")}
+	if delivered, err := DeliverPendingStockAlerts(store, notifier); err != nil || delivered != 0 {
+		t.Fatalf("failing round: got %d, %v, want 0 delivered", delivered, err)
+	}
+	if pending, err := store.PendingStockAlerts(10); err != nil || len(pending) != 1 {
+		t.Fatalf("after failing round: got %d pending, %v, want 1", len(pending), err)
+	}
+
+	notifier.Err = nil
+	if delivered, err := DeliverPendingStockAlerts(store, notifier); err != nil || delivered != 1 {
+		t.Fatalf("round: got %d, %v, want 1 delivered", delivered, err)
+	}
+	alerts := notifier.Alerts()
+	if len(alerts) != 1 || alerts[0].ProductID != product.ID || alerts[0].Qty != 4 || alerts[0].Threshold != 5 || alerts[0].Product.SKU != "SKU-1" {
+		t.Fatalf("notified: got %+v", alerts)
+	}
+	if pending, err := store.PendingStockAlerts(10); err != nil || len(pending) != 0 {
+		t.Fatalf("after round: got %d pending, %v, want 0", len(pending), err)
+	}
+
+	// Delivered alerts aren't sent again
+	if delivered, err := DeliverPendingStockAlerts(store, notifier); err != nil || delivered != 0 {
+		t.Fatalf("repeat round: got %d, %v, want 0 delivered", delivered, err)
+	}
+	if len(notifier.Alerts()) != 1 {
+		t.Fatalf("repeat round: notified %d times, want once", len(notifier.Alerts()))
+	}
+}
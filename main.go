@@ -3,8 +3,12 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"tutuplapak/config"
 	"tutuplapak/db"
+	"tutuplapak/jobs"
+	"tutuplapak/notify"
+	"tutuplapak/repositories"
 	"tutuplapak/routes"
 )
 
@@ -19,8 +23,22 @@ func main() {
 		log.Println("Database connection closed.")
 	}()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	r := routes.SetupRouter(cfg, db.DB)
 
+	go jobs.PurgeDeletedProducts(repositories.NewProductRepository(db.DB), cfg.DeletedProductRetention, cfg.ProductPurgeInterval)
+	go jobs.ExpireReservations(repositories.NewPurchaseRepository(db.DB), cfg.ReservationSweepInterval)
+
+	notifier, err := notify.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize notifier: %v", err)
+	}
+	go jobs.DeliverStockAlerts(repositories.NewStockAlertRepository(db.DB), notifier, cfg.StockAlertInterval)
+
 	fmt.Printf("Starting server on port %s...\n", cfg.AppPort)
 	r.Run(":" + cfg.AppPort)
 }
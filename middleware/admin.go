@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"tutuplapak/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin rejects users without admin rights. It must run after
+// JWTAuth, and looks the user up on every request so that revoking rights
+// takes effect without waiting for the token to expire.
+func RequireAdmin(users repositories.UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := users.GetUserById(c.GetUint("userId"))
+		if err != nil && !errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if !user.IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin rights are required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"mime"
 	"net/http"
 	"strings"
 	"tutuplapak/utils"
@@ -41,8 +42,21 @@ func JWTAuth() gin.HandlerFunc {
 					c.Abort()
 					return
 				}
+			case "/v1/product/import":
+				mediaType, _, _ := mime.ParseMediaType(contentType)
+				if mediaType != "text/csv" && mediaType != "application/x-ndjson" && mediaType != "application/ndjson" {
+					c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be text/csv or application/x-ndjson"})
+					c.Abort()
+					return
+				}
 			default:
-				if contentType != "application/json" {
+				// Bodyless requests such as a restore have nothing to describe
+				if c.Request.ContentLength == 0 {
+					break
+				}
+				mediaType, _, _ := mime.ParseMediaType(contentType)
+				isMergePatch := c.Request.Method == http.MethodPatch && mediaType == "application/merge-patch+json"
+				if mediaType != "application/json" && !isMergePatch {
 					c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type must be application/json"})
 					c.Abort()
 					return
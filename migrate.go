@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"tutuplapak/db"
+)
+
+const migrateUsage = `usage: tutuplapak migrate <command>
+
+commands:
+  up [N]       apply all pending migrations, or the next N
+  down [N]     roll back the last applied migration, or the last N
+  status       list migrations and whether they are applied
+  goto V       migrate up or down to version V (0 rolls back everything)`
+
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	migrator, err := db.NewMigrator(db.DB)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx, parseSteps(args[1:], 0))
+	case "down":
+		err = migrator.Down(ctx, parseSteps(args[1:], 1))
+	case "goto":
+		if len(args) < 2 {
+			log.Fatal(migrateUsage)
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 64)
+		if parseErr != nil {
+			log.Fatalf("Invalid version %q", args[1])
+		}
+		err = migrator.Goto(ctx, uint(version))
+	case "status":
+		var statuses []db.MigrationStatus
+		statuses, err = migrator.Status(ctx)
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%06d  %-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+}
+
+func parseSteps(args []string, defaultSteps int) int {
+	if len(args) == 0 {
+		return defaultSteps
+	}
+
+	steps, err := strconv.Atoi(args[0])
+	if err != nil || steps < 1 {
+		log.Fatalf("Invalid step count %q", args[0])
+	}
+	return steps
+}
@@ -3,14 +3,41 @@ package models
 import "time"
 
 type Product struct {
-	ID        int       `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"size:32;not null" json:"name"`
-	Category  string    `gorm:"not null" json:"category"`
-	Qty       int       `gorm:"not null;check:qty >= 1" json:"qty"`
-	Price     float64   `gorm:"not null;check:price >= 100" json:"price"`
-	SKU       string    `gorm:"size:32;not null" json:"sku"`
-	FileID    string    `gorm:"type:uuid;not null" json:"fileId"`
-	File      File      `gorm:"foreignKey:FileID" json:"file"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"createdAt"`
-	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updatedAt"`
+	ID                 int              `gorm:"primaryKey" json:"id"`
+	Name               string           `gorm:"size:32;not null" json:"name"`
+	Category           string           `gorm:"not null" json:"category"`
+	Qty                int              `gorm:"not null;check:qty >= 0 OR allow_negative_stock" json:"qty"`
+	ReservedQty        int              `gorm:"not null;default:0;check:reserved_qty >= 0" json:"reservedQty"` // held by unpaid purchases
+	Price              float64          `gorm:"not null;check:price >= 100" json:"price"`
+	SKU                string           `gorm:"size:32;not null" json:"sku"`
+	FileID             string           `gorm:"type:uuid;not null" json:"fileId"`
+	File               File             `gorm:"foreignKey:FileID" json:"file"`
+	Images             []File           `gorm:"many2many:product_files" json:"images"`
+	Options            []string         `gorm:"type:text[];column:option_names" json:"options"`
+	Variants           []ProductVariant `gorm:"foreignKey:ProductID" json:"variants"`
+	UserID             uint             `gorm:"not null" json:"userId"`
+	Version            int              `gorm:"not null;default:1" json:"version"`
+	Archived           bool             `gorm:"column:is_archived;not null;default:false" json:"archived"`
+	AllowNegativeStock bool             `gorm:"not null;default:false" json:"allowNegativeStock"`                           // sales and adjustments may take qty below zero
+	LowStockThreshold  int              `gorm:"not null;default:0;check:low_stock_threshold >= 0" json:"lowStockThreshold"` // 0 never alerts
+	LowStockAlerted    bool             `gorm:"not null;default:false" json:"lowStockAlerted"`                              // an alert was raised and stock has not recovered since
+	CreatedAt          time.Time        `gorm:"default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt          time.Time        `gorm:"default:CURRENT_TIMESTAMP" json:"updatedAt"`
+	DeletedAt          *time.Time       `json:"deletedAt"` // nil unless soft deleted
+}
+
+// ProductVariant is one combination of a product's options, e.g. size M in
+// red. OptionValues line up with the product's Options.
+type ProductVariant struct {
+	ID           int       `gorm:"primaryKey" json:"id"`
+	ProductID    int       `gorm:"not null" json:"productId"`
+	OptionValues []string  `gorm:"type:text[];not null" json:"optionValues"`
+	SKU          string    `gorm:"size:32;not null" json:"sku"`
+	Price        float64   `gorm:"not null;check:price >= 100" json:"price"`
+	Qty          int       `gorm:"not null" json:"qty"`
+	ReservedQty  int       `gorm:"not null;default:0;check:reserved_qty >= 0" json:"reservedQty"` // held by unpaid purchases
+	FileID       string    `gorm:"type:uuid" json:"fileId"`
+	File         File      `gorm:"foreignKey:FileID" json:"file"`
+	CreatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updatedAt"`
 }
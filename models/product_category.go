@@ -1,6 +1,8 @@
 package models
 
 type ProductCategory struct {
-	ID   uint   `gorm:"primaryKey" json:"id"`
-	Type string `gorm:"unique;not null" json:"type"`
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Type     string `gorm:"unique;not null" json:"type"`
+	ParentID uint   `json:"parentId"` // 0 for a top-level category
+	IsActive bool   `gorm:"not null;default:true" json:"isActive"`
 }
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	PurchaseStatusPending = "pending"
+	PurchaseStatusPaid    = "paid"
+	PurchaseStatusExpired = "expired"
+)
+
+type Purchase struct {
+	ID                  int            `gorm:"primaryKey" json:"id"`
+	PublicID            string         `gorm:"type:uuid;not null;uniqueIndex" json:"publicId"` // the id the API shows, as sequential ids can be guessed
+	SenderName          string         `gorm:"size:55;not null" json:"senderName"`
+	SenderContactType   string         `gorm:"size:8;not null" json:"senderContactType"`
+	SenderContactDetail string         `gorm:"not null" json:"senderContactDetail"`
+	Status              string         `gorm:"size:16;not null;default:pending" json:"status"`
+	TotalPrice          float64        `gorm:"not null" json:"totalPrice"`
+	ReservedUntil       *time.Time     `json:"reservedUntil"` // stock is held for the purchase until then; nil if none is held
+	Items               []PurchaseItem `gorm:"foreignKey:PurchaseID" json:"items"`
+	CreatedAt           time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt           time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"updatedAt"`
+}
+
+type PurchaseItem struct {
+	ID         int            `gorm:"primaryKey" json:"id"`
+	PurchaseID int            `gorm:"not null" json:"purchaseId"`
+	ProductID  int            `gorm:"not null" json:"productId"`
+	Product    Product        `gorm:"foreignKey:ProductID" json:"product"`
+	VariantID  int            `json:"variantId"` // 0 for products without variants
+	Variant    ProductVariant `gorm:"foreignKey:VariantID" json:"variant"`
+	SellerID   uint           `gorm:"not null" json:"sellerId"`
+	Seller     User           `gorm:"foreignKey:SellerID" json:"seller"`
+	Qty        int            `gorm:"not null;check:qty >= 1" json:"qty"`
+	Price      float64        `gorm:"not null" json:"price"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// StockAlert tells a seller that a product's stock fell to or below its low
+// stock threshold. Only one is raised until the stock recovers above it.
+type StockAlert struct {
+	ID          int        `gorm:"primaryKey" json:"id"`
+	ProductID   int        `gorm:"not null" json:"productId"`
+	Product     Product    `gorm:"foreignKey:ProductID" json:"product"`
+	UserID      uint       `gorm:"not null" json:"userId"`
+	Seller      User       `gorm:"foreignKey:UserID" json:"seller"`
+	Qty         int        `gorm:"not null" json:"qty"`       // stock when the alert was raised
+	Threshold   int        `gorm:"not null" json:"threshold"` // the product's threshold at the time
+	CreatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"createdAt"`
+	DeliveredAt *time.Time `json:"deliveredAt"` // nil until a notifier has sent it
+}
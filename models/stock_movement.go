@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	StockMovementSale       = "sale"
+	StockMovementRestock    = "restock"
+	StockMovementAdjustment = "adjustment"
+	StockMovementReturn     = "return"
+	StockMovementCorrection = "correction"
+)
+
+// StockMovement is one entry of a product's inventory ledger. The product's
+// qty, or its variant's, is the sum of its movements.
+type StockMovement struct {
+	ID               int       `gorm:"primaryKey" json:"id"`
+	ProductID        int       `gorm:"not null" json:"productId"`
+	VariantID        int       `json:"variantId"` // 0 for products without variants
+	Reason           string    `gorm:"size:16;not null" json:"reason"`
+	Delta            int       `gorm:"not null;check:delta <> 0" json:"delta"`
+	QtyAfter         int       `gorm:"not null" json:"qtyAfter"` // stock of the product, or variant, after the movement
+	Note             string    `gorm:"size:255;not null" json:"note"`
+	PurchaseID       int       `json:"purchaseId"`                // set for sales
+	PurchasePublicID string    `gorm:"-" json:"purchasePublicId"` // the sale's purchase as the API shows it, loaded with the history
+	UserID           uint      `json:"userId"`                    // who made a manual movement, 0 for sales
+	CreatedAt        time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"createdAt"`
+}
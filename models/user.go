@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type User struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Email        string `gorm:"unique" json:"email"`
+	Phone        string `gorm:"unique" json:"phone"`
+	PasswordHash string `gorm:"not null" json:"-"`
+	IsAdmin      bool   `gorm:"not null;default:false" json:"isAdmin"`
+
+	BankAccountName   string `gorm:"size:32" json:"bankAccountName"`
+	BankAccountHolder string `gorm:"size:32" json:"bankAccountHolder"`
+	BankAccountNumber string `gorm:"size:32" json:"bankAccountNumber"`
+
+	FileID string `gorm:"type:uuid" json:"fileId"`
+	File   File   `gorm:"foreignKey:FileID" json:"file"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updatedAt"`
+}
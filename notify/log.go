@@ -0,0 +1,16 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"tutuplapak/models"
+)
+
+// LogNotifier writes alerts to the server log, which is enough during
+// development and when nothing else is configured.
+type LogNotifier struct{}
+
+func (n *LogNotifier) NotifyLowStock(ctx context.Context, alert models.StockAlert) error {
+	log.Printf("Low stock alert for seller %d: %s", alert.UserID, lowStockMessage(alert))
+	return nil
+}
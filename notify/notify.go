@@ -0,0 +1,34 @@
+// Package notify delivers messages to sellers outside the API.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"tutuplapak/config"
+	"tutuplapak/models"
+)
+
+// Notifier tells a seller that one of their products is running low. An
+// error means the alert was not delivered and should be tried again.
+type Notifier interface {
+	NotifyLowStock(ctx context.Context, alert models.StockAlert) error
+}
+
+func New(cfg *config.Config) (Notifier, error) {
+	switch cfg.NotifierDriver {
+	case "log":
+		return &LogNotifier{}, nil
+	case "webhook":
+		return NewWebhookNotifier(cfg.AlertWebhookURL)
+	case "smtp":
+		return NewSMTPNotifier(cfg)
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", cfg.NotifierDriver)
+	}
+}
+
+// lowStockMessage is the human readable text of an alert.
+func lowStockMessage(alert models.StockAlert) string {
+	return fmt.Sprintf("%s (SKU %s) is running low: %d left, threshold %d.",
+		alert.Product.Name, alert.Product.SKU, alert.Qty, alert.Threshold)
+}
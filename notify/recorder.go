@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"tutuplapak/models"
+)
+
+// Recorder is a Notifier for tests that keeps the alerts it is given, and
+// fails with Err instead when that is set.
+type Recorder struct {
+	mu     sync.Mutex
+	Err    error
+	alerts []models.StockAlert
+}
+
+func (r *Recorder) NotifyLowStock(ctx context.Context, alert models.StockAlert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return r.Err
+	}
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+// Alerts returns the alerts delivered so far, oldest first.
+func (r *Recorder) Alerts() []models.StockAlert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]models.StockAlert(nil), r.alerts...)
+}
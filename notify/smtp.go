@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+	"tutuplapak/config"
+	"tutuplapak/models"
+)
+
+// smtpTimeout bounds a whole delivery when ctx has no deadline of its own.
+const smtpTimeout = 30 * time.Second
+
+// SMTPNotifier emails alerts to the seller's address. Sellers who only
+// registered a phone number cannot be reached this way, so their alerts are
+// logged instead.
+type SMTPNotifier struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+func NewSMTPNotifier(cfg *config.Config) (*SMTPNotifier, error) {
+	if cfg.SMTPHost == "" || cfg.SMTPFrom == "" {
+		return nil, fmt.Errorf("SMTP_HOST and SMTP_FROM are required for the smtp notifier driver")
+	}
+
+	n := &SMTPNotifier{Addr: net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort), From: cfg.SMTPFrom}
+	if cfg.SMTPUsername != "" {
+		n.Auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return n, nil
+}
+
+func (n *SMTPNotifier) NotifyLowStock(ctx context.Context, alert models.StockAlert) error {
+	if alert.Seller.Email == "" {
+		log.Printf("Seller %d has no email for a low stock alert: %s", alert.UserID, lowStockMessage(alert))
+		return nil
+	}
+
+	// Product names are free text, so keep line breaks out of the header
+	// and encode anything beyond 7-bit ASCII
+	name := strings.NewReplacer("\r", " ", "\n", " ").Replace(alert.Product.Name)
+	subject := mime.QEncoding.Encode("utf-8", "Low stock: "+name)
+	message := strings.Join([]string{
+		"From: " + n.From,
+		"To: " + alert.Seller.Email,
+		"Subject: " + subject,
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		lowStockMessage(alert),
+	}, "\r\n")
+
+	if err := n.send(ctx, alert.Seller.Email, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send alert email: %v", err)
+	}
+	return nil
+}
+
+// send does what smtp.SendMail does, but over a connection that honours
+// ctx, so an unresponsive server cannot hold up the delivery job.
+func (n *SMTPNotifier) send(ctx context.Context, to string, message []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Auth != nil {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(n.Auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"tutuplapak/models"
+)
+
+// fakeSMTPServer accepts one delivery on a local port and sends the raw
+// message it received on the returned channel.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- string(data)
+				text.PrintfLine("250 Queued")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Unsupported")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestSMTPNotifierSubject(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		productName string
+		wantSubject string
+	}{
+		{"plain", "Coffee Beans", "Low stock: Coffee Beans"},
+		{"non-ASCII", "Kopi Señor", "Low stock: Kopi Señor"},
+		{"header injection", "Beans\r\nBcc: victim@example.com", "Low stock: Beans  Bcc: victim@example.com"},
+		{"body injection", "Beans\r\n\r\nPay here", "Low stock: Beans    Pay here"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			addr, received := fakeSMTPServer(t)
+			n := &SMTPNotifier{Addr: addr, From: "alerts@example.com"}
+
+			alert := models.StockAlert{
+				Product:   models.Product{Name: tc.productName, SKU: "SKU-1"},
+				Seller:    models.User{Email: "seller@example.com"},
+				Qty:       2,
+				Threshold: 5,
+			}
+			if err := n.NotifyLowStock(context.Background(), alert); err != nil {
+				t.Fatalf("NotifyLowStock: %v", err)
+			}
+
+			raw := <-received
+			msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(raw)))
+			if err != nil {
+				t.Fatalf("parsing %q: %v", raw, err)
+			}
+			if bcc := msg.Header.Get("Bcc"); bcc != "" {
+				t.Fatalf("injected Bcc header %q in %q", bcc, raw)
+			}
+
+			header := msg.Header.Get("Subject")
+			for _, r := range header {
+				if r > 0x7e || r < 0x20 {
+					t.Fatalf("subject header %q is not 7-bit printable ASCII", header)
+				}
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(header)
+			if err != nil || subject != tc.wantSubject {
+				t.Fatalf("subject: got %q, %v, want %q", subject, err, tc.wantSubject)
+			}
+		})
+	}
+}
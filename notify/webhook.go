@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"tutuplapak/models"
+)
+
+// WebhookNotifier POSTs alerts as JSON to a URL and expects a 2xx reply.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string) (*WebhookNotifier, error) {
+	if url == "" {
+		return nil, fmt.Errorf("ALERT_WEBHOOK_URL is required for the webhook notifier driver")
+	}
+
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// lowStockEvent is the body of a webhook call.
+type lowStockEvent struct {
+	Event     string    `json:"event"`
+	AlertID   string    `json:"alertId"` // the same alert may be delivered more than once
+	SellerID  string    `json:"sellerId"`
+	ProductID string    `json:"productId"`
+	Name      string    `json:"name"`
+	SKU       string    `json:"sku"`
+	Qty       int       `json:"qty"`
+	Threshold int       `json:"threshold"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (n *WebhookNotifier) NotifyLowStock(ctx context.Context, alert models.StockAlert) error {
+	body, err := json.Marshal(lowStockEvent{
+		Event:     "product.low_stock",
+		AlertID:   strconv.Itoa(alert.ID),
+		SellerID:  strconv.FormatUint(uint64(alert.UserID), 10),
+		ProductID: strconv.Itoa(alert.ProductID),
+		Name:      alert.Product.Name,
+		SKU:       alert.Product.SKU,
+		Qty:       alert.Qty,
+		Threshold: alert.Threshold,
+		Message:   lowStockMessage(alert),
+		CreatedAt: alert.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tutuplapak/models"
+)
+
+func newTestAlert() models.StockAlert {
+	return models.StockAlert{
+		ID:        7,
+		ProductID: 3,
+		Product:   models.Product{Name: "Coffee Beans", SKU: "SKU-1"},
+		UserID:    2,
+		Qty:       1,
+		Threshold: 5,
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var contentType string
+	var event lowStockEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decoding webhook body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n, err := NewWebhookNotifier(server.URL)
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier: %v", err)
+	}
+	if err := n.NotifyLowStock(context.Background(), newTestAlert()); err != nil {
+		t.Fatalf("NotifyLowStock: %v", err)
+	}
+
+	if contentType != "application/json" {
+		t.Fatalf("Content-Type: got %q, want application/json", contentType)
+	}
+	if event.Event != "product.low_stock" || event.AlertID != "7" || event.SellerID != "2" || event.ProductID != "3" ||
+		event.SKU != "SKU-1" || event.Qty != 1 || event.Threshold != 5 || event.Message == "" {
+		t.Fatalf("webhook body: got %+v", event)
+	}
+}
+
+func TestWebhookNotifierFailures(t *testing.T) {
+	if _, err := NewWebhookNotifier(""); err == nil {
+		t.Fatal("NewWebhookNotifier without a URL: got nil error")
+	}
+
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusBadRequest, http.StatusInternalServerError} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		n := &WebhookNotifier{URL: server.URL, Client: server.Client()}
+		err := n.NotifyLowStock(context.Background(), newTestAlert())
+		server.Close()
+		if err == nil {
+			t.Fatalf("NotifyLowStock answered with %d: got nil error", status)
+		}
+	}
+
+	// A webhook that never answers is given up on at the context deadline
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	n := &WebhookNotifier{URL: server.URL, Client: server.Client()}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.NotifyLowStock(ctx, newTestAlert()); err == nil {
+		t.Fatal("NotifyLowStock to a hanging webhook: got nil error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("NotifyLowStock to a hanging webhook took %v", elapsed)
+	}
+}
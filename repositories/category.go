@@ -0,0 +1,245 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+)
+
+var (
+	ErrCategoryNotFound       = errors.New("category not found")
+	ErrCategoryAlreadyExists  = errors.New("category already exists")
+	ErrCategoryParentNotFound = errors.New("parent category not found")
+	ErrCategoryCycle          = errors.New("category cannot be moved under itself or its descendants")
+	ErrCategoryInUse          = errors.New("category still has products or subcategories")
+	// ErrCategoryHasDeletedProducts is returned instead of ErrCategoryInUse
+	// when only soft deleted products still reference the category.
+	ErrCategoryHasDeletedProducts = errors.New("category is still used by deleted products; restore and move them, or wait until they are purged")
+)
+
+// categorySubtree expands an array of category types, given as the
+// placeholder filled in for %s, to include all their descendants. The
+// hierarchy is kept acyclic by UpdateCategory, and UNION stops the recursion
+// even if it were not.
+const categorySubtree = `
+	WITH RECURSIVE subtree AS (
+		SELECT id, type FROM product_categories WHERE type = ANY(%s)
+		UNION
+		SELECT child.id, child.type
+		FROM product_categories child
+		JOIN subtree ON child.parent_id = subtree.id
+	)
+	SELECT type FROM subtree
+`
+
+type CategoryRepository struct {
+	DB *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{DB: db}
+}
+
+// ListCategories returns the categories ordered by id, leaving out the
+// deactivated ones unless includeInactive is set.
+func (r *CategoryRepository) ListCategories(includeInactive bool) ([]models.ProductCategory, error) {
+	query := `
+		SELECT id, type, COALESCE(parent_id, 0), is_active
+		FROM product_categories
+		WHERE $1 OR is_active
+		ORDER BY id
+	`
+
+	rows, err := r.DB.Query(query, includeInactive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %v", err)
+	}
+	defer rows.Close()
+
+	var categories []models.ProductCategory
+	for rows.Next() {
+		var category models.ProductCategory
+		if err := rows.Scan(&category.ID, &category.Type, &category.ParentID, &category.IsActive); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %v", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// CreateCategory adds a category under parentId, or at the top level when
+// parentId is 0.
+func (r *CategoryRepository) CreateCategory(categoryType string, parentId uint) (models.ProductCategory, error) {
+	query := `
+		INSERT INTO product_categories (type, parent_id)
+		VALUES ($1, NULLIF($2, 0))
+		RETURNING id, type, COALESCE(parent_id, 0), is_active
+	`
+
+	var category models.ProductCategory
+	err := r.DB.QueryRow(query, categoryType, parentId).Scan(&category.ID, &category.Type, &category.ParentID, &category.IsActive)
+	if isUniqueViolation(err) {
+		return models.ProductCategory{}, ErrCategoryAlreadyExists
+	}
+	if isForeignKeyViolation(err) {
+		return models.ProductCategory{}, ErrCategoryParentNotFound
+	}
+	if err != nil {
+		return models.ProductCategory{}, fmt.Errorf("failed to create category: %v", err)
+	}
+
+	return category, nil
+}
+
+// UpdateCategory renames, moves and/or (de)activates a category. Renaming
+// cascades to the products in it through the foreign key on
+// products.category, and bumps their version so cached ETags go stale.
+func (r *CategoryRepository) UpdateCategory(id uint, req dto.UpdateCategoryRequest) (models.ProductCategory, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return models.ProductCategory{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	parentId := ""
+	if req.ParentID != nil {
+		parentId = *req.ParentID
+		if err := checkCategoryParent(tx, id, parentId); err != nil {
+			return models.ProductCategory{}, err
+		}
+	}
+
+	query := `
+		UPDATE product_categories
+		SET type = COALESCE($1, type),
+			parent_id = CASE WHEN $2 THEN NULLIF($3, '')::int ELSE parent_id END,
+			is_active = COALESCE($4, is_active)
+		WHERE id = $5
+		RETURNING id, type, COALESCE(parent_id, 0), is_active
+	`
+
+	var category models.ProductCategory
+	err = tx.QueryRow(query, req.Type, req.ParentID != nil, parentId, req.IsActive, id).Scan(
+		&category.ID,
+		&category.Type,
+		&category.ParentID,
+		&category.IsActive,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ProductCategory{}, ErrCategoryNotFound
+	}
+	if isUniqueViolation(err) {
+		return models.ProductCategory{}, ErrCategoryAlreadyExists
+	}
+	if err != nil {
+		return models.ProductCategory{}, fmt.Errorf("failed to update category: %v", err)
+	}
+
+	// The cascade rewrites products.category behind the products' backs, so
+	// mark them changed like any other product update
+	if req.Type != nil {
+		_, err := tx.Exec(`
+			UPDATE products SET version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE category = $1
+		`, category.Type)
+		if err != nil {
+			return models.ProductCategory{}, fmt.Errorf("failed to touch renamed category's products: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ProductCategory{}, fmt.Errorf("failed to commit category: %v", err)
+	}
+
+	return category, nil
+}
+
+// checkCategoryParent verifies that parentId exists and is not id or one of
+// its descendants. The table is locked against concurrent moves until the
+// transaction ends, as two moves that are each valid alone can form a cycle.
+func checkCategoryParent(tx *sql.Tx, id uint, parentId string) error {
+	if parentId == "" {
+		return nil
+	}
+
+	if _, err := tx.Exec("LOCK TABLE product_categories IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return fmt.Errorf("failed to lock categories: %v", err)
+	}
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM product_categories WHERE id = $1
+			UNION
+			SELECT parent.id, parent.parent_id
+			FROM product_categories parent
+			JOIN ancestors ON parent.id = ancestors.parent_id
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors), EXISTS(SELECT 1 FROM ancestors WHERE id = $2)
+	`
+
+	var parentExists, cycle bool
+	if err := tx.QueryRow(query, parentId, id).Scan(&parentExists, &cycle); err != nil {
+		return fmt.Errorf("failed to check category parent: %v", err)
+	}
+	if !parentExists {
+		return ErrCategoryParentNotFound
+	}
+	if cycle {
+		return ErrCategoryCycle
+	}
+
+	return nil
+}
+
+// DeleteCategory removes a category that has neither products nor
+// subcategories; anything else fails with ErrCategoryInUse, or with
+// ErrCategoryHasDeletedProducts when the only products left are soft deleted
+// ones. Those still hold the category until they are purged, so RestoreProduct
+// keeps working.
+func (r *CategoryRepository) DeleteCategory(id uint) error {
+	query := `
+		DELETE FROM product_categories
+		WHERE id = $1
+		AND NOT EXISTS (SELECT 1 FROM products WHERE products.category = product_categories.type)
+		AND NOT EXISTS (SELECT 1 FROM product_categories child WHERE child.parent_id = product_categories.id)
+	`
+
+	result, err := r.DB.Exec(query, id)
+	if isForeignKeyViolation(err) {
+		// A product or subcategory was added since the checks above ran
+		return ErrCategoryInUse
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete category: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var exists, inUse bool
+	query = `
+		SELECT true, EXISTS (SELECT 1 FROM products WHERE products.category = product_categories.type AND products.deleted_at IS NULL)
+			OR EXISTS (SELECT 1 FROM product_categories child WHERE child.parent_id = product_categories.id)
+		FROM product_categories
+		WHERE id = $1
+	`
+	err = r.DB.QueryRow(query, id).Scan(&exists, &inUse)
+	if err == sql.ErrNoRows {
+		return ErrCategoryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check category: %v", err)
+	}
+	if inUse {
+		return ErrCategoryInUse
+	}
+	return ErrCategoryHasDeletedProducts
+}
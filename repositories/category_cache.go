@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+	"tutuplapak/models"
+)
+
+// CategoryCache keeps every category in process so that validating a
+// product's category does not cost a query. Writes through this process
+// call Invalidate; the TTL bounds how long other instances keep serving a
+// stale set.
+type CategoryCache struct {
+	Store CategoryStore
+	TTL   time.Duration
+
+	mu         sync.Mutex
+	categories map[string]models.ProductCategory
+	loadedAt   time.Time
+}
+
+func NewCategoryCache(store CategoryStore, ttl time.Duration) *CategoryCache {
+	return &CategoryCache{Store: store, TTL: ttl}
+}
+
+// All returns the categories keyed by type, active or not. The map is
+// shared between callers and must not be modified.
+func (c *CategoryCache) All() (map[string]models.ProductCategory, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.categories != nil && time.Since(c.loadedAt) < c.TTL {
+		return c.categories, nil
+	}
+
+	list, err := c.Store.ListCategories(true)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make(map[string]models.ProductCategory, len(list))
+	for _, category := range list {
+		categories[category.Type] = category
+	}
+	c.categories = categories
+	c.loadedAt = time.Now()
+
+	return categories, nil
+}
+
+func (c *CategoryCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.categories = nil
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"tutuplapak/models"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+type FileRepository struct {
+	DB *sql.DB
+}
+
+func NewFileRepository(db *sql.DB) *FileRepository {
+	return &FileRepository{DB: db}
+}
+
+func (r *FileRepository) CreateFile(fileUri, fileThumbnailUri string) (models.File, error) {
+	query := `
+		INSERT INTO files (original_file_uri, compressed_file_uri)
+		VALUES ($1, $2)
+		RETURNING id, original_file_uri, compressed_file_uri, created_at, updated_at
+	`
+
+	var file models.File
+	err := r.DB.QueryRow(query, fileUri, fileThumbnailUri).Scan(
+		&file.FileID,
+		&file.FileUri,
+		&file.FileThumbnailUri,
+		&file.CreatedAt,
+		&file.UpdatedAt,
+	)
+	if err != nil {
+		return models.File{}, fmt.Errorf("failed to create file: %v", err)
+	}
+
+	return file, nil
+}
+
+func (r *FileRepository) IsFileExists(fileId string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM files WHERE id = $1)`
+	err := r.DB.QueryRow(query, fileId).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"tutuplapak/dto"
+)
+
+// ErrImportVariants is returned for an import row that sets options or
+// variants on a product that already exists; those are managed through the
+// variant endpoints instead.
+var ErrImportVariants = errors.New("options and variants can only be imported with a new product")
+
+// ImportRow is one product of an import: Create is the product to create
+// when its sku is new, and Update holds just the fields the row provides,
+// to apply when the seller already has a product with that sku.
+type ImportRow struct {
+	Create dto.CreateProductRequest
+	Update dto.UpdateProductRequest
+}
+
+// ImportResult is the outcome of one row of ImportProducts.
+type ImportResult struct {
+	ProductID int
+	Created   bool // false when an existing product with the sku was updated
+	Err       error
+}
+
+// ImportProducts upserts the seller's products by sku in a single
+// transaction: a row whose sku is new creates the product, otherwise the
+// live product with that sku gets the row's update. Each row runs
+// under a savepoint so every failing row is reported, and if any row fails,
+// or dryRun is set, the transaction is rolled back and nothing is written.
+func (r *ProductRepository) ImportProducts(userId uint, rows []ImportRow, dryRun bool) ([]ImportResult, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	results := make([]ImportResult, len(rows))
+	failed := false
+	for i, row := range rows {
+		if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %v", err)
+		}
+
+		results[i], err = importProduct(tx, userId, row)
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return nil, fmt.Errorf("failed to roll back import row: %v", err)
+			}
+		}
+
+		if _, err := tx.Exec("RELEASE SAVEPOINT import_row"); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %v", err)
+		}
+	}
+
+	if failed || dryRun {
+		return results, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %v", err)
+	}
+
+	return results, nil
+}
+
+// importProduct creates or updates the product for one import row inside tx.
+func importProduct(tx *sql.Tx, userId uint, row ImportRow) (ImportResult, error) {
+	var id int
+	err := tx.QueryRow(
+		"SELECT id FROM products WHERE user_id = $1 AND sku = $2 AND deleted_at IS NULL FOR UPDATE",
+		userId, row.Create.SKU,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		product, err := createProduct(tx, userId, row.Create)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		return ImportResult{ProductID: product.ID, Created: true}, nil
+	}
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to find product by sku: %v", err)
+	}
+
+	result := ImportResult{ProductID: id}
+	if len(row.Create.Options) > 0 || len(row.Create.Variants) > 0 {
+		return result, ErrImportVariants
+	}
+	return result, updateProduct(tx, id, userId, 0, row.Update)
+}
@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"sort"
+	"strconv"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func (s *Store) ListCategories(includeInactive bool) ([]models.ProductCategory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var categories []models.ProductCategory
+	for _, category := range s.categories {
+		if includeInactive || category.IsActive {
+			categories = append(categories, category)
+		}
+	}
+
+	sort.Slice(categories, func(i, j int) bool { return categories[i].ID < categories[j].ID })
+	return categories, nil
+}
+
+func (s *Store) CreateCategory(categoryType string, parentId uint) (models.ProductCategory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.categoryTaken(0, categoryType) {
+		return models.ProductCategory{}, repositories.ErrCategoryAlreadyExists
+	}
+	if _, ok := s.categories[parentId]; parentId != 0 && !ok {
+		return models.ProductCategory{}, repositories.ErrCategoryParentNotFound
+	}
+
+	s.nextCategoryId++
+	category := models.ProductCategory{ID: s.nextCategoryId, Type: categoryType, ParentID: parentId, IsActive: true}
+	s.categories[category.ID] = category
+
+	return category, nil
+}
+
+func (s *Store) UpdateCategory(id uint, req dto.UpdateCategoryRequest) (models.ProductCategory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, ok := s.categories[id]
+	if !ok {
+		return models.ProductCategory{}, repositories.ErrCategoryNotFound
+	}
+
+	parentId := category.ParentID
+	if req.ParentID != nil {
+		parsed, _ := strconv.ParseUint(*req.ParentID, 10, 32)
+		parentId = uint(parsed)
+		if err := s.checkCategoryParent(id, parentId); err != nil {
+			return models.ProductCategory{}, err
+		}
+	}
+	if req.Type != nil && *req.Type != category.Type && s.categoryTaken(id, *req.Type) {
+		return models.ProductCategory{}, repositories.ErrCategoryAlreadyExists
+	}
+
+	if req.Type != nil && *req.Type != category.Type {
+		// Mirror ON UPDATE CASCADE on products.category
+		now := time.Now()
+		for productId, product := range s.products {
+			if product.Category == category.Type {
+				product.Category = *req.Type
+				product.Version++
+				product.UpdatedAt = now
+				s.products[productId] = product
+			}
+		}
+		category.Type = *req.Type
+	}
+	if req.IsActive != nil {
+		category.IsActive = *req.IsActive
+	}
+	category.ParentID = parentId
+	s.categories[id] = category
+
+	return category, nil
+}
+
+func (s *Store) DeleteCategory(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, ok := s.categories[id]
+	if !ok {
+		return repositories.ErrCategoryNotFound
+	}
+	for _, child := range s.categories {
+		if child.ParentID == id {
+			return repositories.ErrCategoryInUse
+		}
+	}
+	deletedOnly := false
+	for _, product := range s.products {
+		if product.Category != category.Type {
+			continue
+		}
+		if product.DeletedAt == nil {
+			return repositories.ErrCategoryInUse
+		}
+		deletedOnly = true
+	}
+	if deletedOnly {
+		return repositories.ErrCategoryHasDeletedProducts
+	}
+
+	delete(s.categories, id)
+	return nil
+}
+
+// checkCategoryParent verifies that parentId exists and is not id or one of
+// its descendants.
+func (s *Store) checkCategoryParent(id, parentId uint) error {
+	if parentId == 0 {
+		return nil
+	}
+	if _, ok := s.categories[parentId]; !ok {
+		return repositories.ErrCategoryParentNotFound
+	}
+
+	for ancestor := parentId; ancestor != 0; ancestor = s.categories[ancestor].ParentID {
+		if ancestor == id {
+			return repositories.ErrCategoryCycle
+		}
+	}
+	return nil
+}
+
+// categorySubtree returns the given category types together with the types
+// of all their descendants.
+func (s *Store) categorySubtree(types []string) []string {
+	included := make(map[uint]bool)
+	for _, category := range s.categories {
+		for _, categoryType := range types {
+			if category.Type == categoryType {
+				included[category.ID] = true
+			}
+		}
+	}
+
+	// Sweep until no new children are found; the hierarchy is acyclic
+	for grew := true; grew; {
+		grew = false
+		for _, category := range s.categories {
+			if !included[category.ID] && included[category.ParentID] {
+				included[category.ID] = true
+				grew = true
+			}
+		}
+	}
+
+	subtree := append([]string(nil), types...)
+	for id := range included {
+		subtree = append(subtree, s.categories[id].Type)
+	}
+	return subtree
+}
+
+func (s *Store) categoryTaken(exceptId uint, categoryType string) bool {
+	for _, category := range s.categories {
+		if category.ID != exceptId && category.Type == categoryType {
+			return true
+		}
+	}
+	return false
+}
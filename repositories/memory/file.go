@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"time"
+	"tutuplapak/models"
+)
+
+func (s *Store) CreateFile(fileUri, fileThumbnailUri string) (models.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	file := models.File{
+		FileID:           newUUID(),
+		FileUri:          fileUri,
+		FileThumbnailUri: fileThumbnailUri,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	s.files[file.FileID] = file
+
+	return file, nil
+}
+
+func (s *Store) IsFileExists(fileId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.files[fileId]
+	return ok, nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+// productTables is a copy of everything an import can write, so a failed or
+// dry run import can be undone like a rolled back transaction.
+type productTables struct {
+	products  map[int]models.Product
+	galleries map[int][]string
+	variants  map[int][]models.ProductVariant
+	movements []models.StockMovement
+	alerts    []models.StockAlert
+
+	nextProductId  int
+	nextVariantId  int
+	nextMovementId int
+	nextAlertId    int
+}
+
+func (s *Store) ImportProducts(userId uint, rows []repositories.ImportRow, dryRun bool) ([]repositories.ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := s.saveProductTables()
+
+	results := make([]repositories.ImportResult, len(rows))
+	failed := false
+	for i, row := range rows {
+		results[i] = s.importProduct(userId, row)
+		if results[i].Err != nil {
+			failed = true
+		}
+	}
+
+	if failed || dryRun {
+		s.restoreProductTables(saved)
+	}
+	return results, nil
+}
+
+func (s *Store) importProduct(userId uint, row repositories.ImportRow) repositories.ImportResult {
+	for id, product := range s.products {
+		if product.UserID != userId || product.SKU != row.Create.SKU || product.DeletedAt != nil {
+			continue
+		}
+
+		result := repositories.ImportResult{ProductID: id}
+		if len(row.Create.Options) > 0 || len(row.Create.Variants) > 0 {
+			result.Err = repositories.ErrImportVariants
+		} else {
+			result.Err = s.updateProduct(product, row.Update)
+		}
+		return result
+	}
+
+	product, err := s.createProduct(userId, row.Create)
+	if err != nil {
+		return repositories.ImportResult{Err: err}
+	}
+	return repositories.ImportResult{ProductID: product.ID, Created: true}
+}
+
+func (s *Store) saveProductTables() productTables {
+	saved := productTables{
+		products:       make(map[int]models.Product, len(s.products)),
+		galleries:      make(map[int][]string, len(s.galleries)),
+		variants:       make(map[int][]models.ProductVariant, len(s.variants)),
+		movements:      append([]models.StockMovement(nil), s.movements...),
+		alerts:         append([]models.StockAlert(nil), s.alerts...),
+		nextProductId:  s.nextProductId,
+		nextVariantId:  s.nextVariantId,
+		nextMovementId: s.nextMovementId,
+		nextAlertId:    s.nextAlertId,
+	}
+	for id, product := range s.products {
+		saved.products[id] = product
+	}
+	for id, gallery := range s.galleries {
+		saved.galleries[id] = append([]string(nil), gallery...)
+	}
+	for id, variants := range s.variants {
+		saved.variants[id] = append([]models.ProductVariant(nil), variants...)
+	}
+	return saved
+}
+
+func (s *Store) restoreProductTables(saved productTables) {
+	s.products = saved.products
+	s.galleries = saved.galleries
+	s.variants = saved.variants
+	s.movements = saved.movements
+	s.alerts = saved.alerts
+	s.nextProductId = saved.nextProductId
+	s.nextVariantId = saved.nextVariantId
+	s.nextMovementId = saved.nextMovementId
+	s.nextAlertId = saved.nextAlertId
+}
@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"testing"
+	"tutuplapak/repositories/memory"
+	"tutuplapak/repositories/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		store := memory.New()
+		return storetest.Stores{Products: store, Files: store, Users: store, Purchases: store, Categories: store, StockAlerts: store}
+	})
+}
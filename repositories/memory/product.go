@@ -0,0 +1,490 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func (s *Store) CreateProduct(userId uint, req dto.CreateProductRequest) (models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createProduct(userId, req)
+}
+
+func (s *Store) createProduct(userId uint, req dto.CreateProductRequest) (models.Product, error) {
+	fileIds := req.FileIDs
+	if len(fileIds) == 0 {
+		fileIds = []string{req.FileID}
+	}
+
+	variantValues := make([][]string, len(req.Variants))
+	for i, variant := range req.Variants {
+		values, err := repositories.VariantOptionValues(req.Options, variant.Options)
+		if err != nil {
+			return models.Product{}, err
+		}
+		variantValues[i] = values
+	}
+	qty, price := repositories.VariantTotals(req)
+
+	if s.skuTaken(userId, req.SKU, 0) {
+		return models.Product{}, repositories.ErrProductSKUExists
+	}
+
+	s.nextProductId++
+	now := time.Now()
+	product := models.Product{
+		ID:                 s.nextProductId,
+		Name:               req.Name,
+		Category:           req.Category,
+		Qty:                qty,
+		Price:              float64(price),
+		SKU:                req.SKU,
+		FileID:             fileIds[0],
+		Options:            append([]string{}, req.Options...),
+		AllowNegativeStock: req.AllowNegativeStock,
+		LowStockThreshold:  req.LowStockThreshold,
+		UserID:             userId,
+		Version:            1,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	s.products[product.ID] = product
+	s.galleries[product.ID] = append([]string(nil), fileIds...)
+
+	for i, variant := range req.Variants {
+		if _, err := s.insertVariant(product.ID, userId, variantValues[i], variant); err != nil {
+			delete(s.products, product.ID)
+			delete(s.galleries, product.ID)
+			delete(s.variants, product.ID)
+			s.deleteStockMovements(product.ID)
+			return models.Product{}, err
+		}
+	}
+	if len(req.Variants) == 0 {
+		s.recordStockMovement(&models.StockMovement{
+			ProductID: product.ID,
+			Reason:    models.StockMovementRestock,
+			Delta:     product.Qty,
+			QtyAfter:  product.Qty,
+			Note:      "initial stock",
+			UserID:    userId,
+		})
+	}
+	s.checkLowStock(product.ID)
+
+	return s.withProductFile(s.products[product.ID]), nil
+}
+
+func (s *Store) FilterProducts(filters map[string]string) ([]models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	products, relevance := s.matchingProducts(filters)
+
+	sortBy, ok := filters["sort_by"]
+	if !ok && filters["q"] != "" {
+		sortBy = "relevance"
+	}
+
+	if filters["keyset"] == "true" {
+		return keysetPage(products, filters), nil
+	}
+
+	switch {
+	case sortBy == "relevance":
+		sort.SliceStable(products, func(i, j int) bool {
+			return relevance[products[i].ID] > relevance[products[j].ID]
+		})
+	case sortBy == "newest":
+		sort.SliceStable(products, func(i, j int) bool {
+			return lastModified(products[i]).After(lastModified(products[j]))
+		})
+	case sortBy == "cheapest":
+		sort.SliceStable(products, func(i, j int) bool { return products[i].Price < products[j].Price })
+	case sortBy == "lowest_stock":
+		sort.SliceStable(products, func(i, j int) bool { return products[i].Qty < products[j].Qty })
+	case strings.HasPrefix(sortBy, "sold-"):
+		seconds, err := strconv.Atoi(strings.TrimPrefix(sortBy, "sold-"))
+		if err == nil && seconds > 0 {
+			since := time.Now().Add(-time.Duration(seconds) * time.Second)
+			sold := make(map[int]int)
+			for _, sale := range s.sales {
+				if !sale.SoldAt.Before(since) {
+					sold[sale.ProductID]++
+				}
+			}
+			sort.SliceStable(products, func(i, j int) bool {
+				return sold[products[i].ID] > sold[products[j].ID]
+			})
+		}
+	}
+
+	offset, _ := strconv.Atoi(filters["offset"])
+	if offset > 0 {
+		if offset >= len(products) {
+			return nil, nil
+		}
+		products = products[offset:]
+	}
+
+	limit, _ := strconv.Atoi(filters["limit"])
+	if limit > 0 && limit < len(products) {
+		products = products[:limit]
+	}
+
+	return products, nil
+}
+
+func (s *Store) CountProducts(filters map[string]string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	products, _ := s.matchingProducts(filters)
+	return len(products), nil
+}
+
+// matchingProducts returns the products passing filters ordered by id, with
+// their search relevance when filters has a "q". A category filter also
+// matches the subcategories.
+func (s *Store) matchingProducts(filters map[string]string) ([]models.Product, map[int]float64) {
+	if categories, ok := filters["category"]; ok {
+		expanded := make(map[string]string, len(filters))
+		for key, value := range filters {
+			expanded[key] = value
+		}
+		expanded["category"] = strings.Join(s.categorySubtree(strings.Split(categories, ",")), ",")
+		filters = expanded
+	}
+
+	var products []models.Product
+	relevance := make(map[int]float64)
+	for _, product := range s.products {
+		if product.DeletedAt != nil || !matchesFilters(product, filters) {
+			continue
+		}
+		if q, ok := filters["q"]; ok {
+			score, matched := searchScore(product, q)
+			if !matched {
+				continue
+			}
+			relevance[product.ID] = score
+		}
+		products = append(products, s.withProductFile(product))
+	}
+
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products, relevance
+}
+
+// keysetPage orders products newest first by (created_at, id) and returns
+// the page following the cursor in filters.
+func keysetPage(products []models.Product, filters map[string]string) []models.Product {
+	sort.SliceStable(products, func(i, j int) bool { return keysetBefore(products[j], products[i]) })
+
+	if cursorId, ok := filters["cursor_id"]; ok {
+		cursor := models.Product{}
+		cursor.ID, _ = strconv.Atoi(cursorId)
+		cursor.CreatedAt, _ = time.Parse(time.RFC3339Nano, filters["cursor_created_at"])
+
+		start := len(products)
+		for i, product := range products {
+			if keysetBefore(product, cursor) {
+				start = i
+				break
+			}
+		}
+		products = products[start:]
+	}
+
+	limit, _ := strconv.Atoi(filters["limit"])
+	if limit > 0 && limit < len(products) {
+		products = products[:limit]
+	}
+	return products
+}
+
+// keysetBefore reports whether a sorts before b in (created_at, id) order.
+func keysetBefore(a, b models.Product) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+func (s *Store) GetProductById(id int) (*models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[id]
+	if !ok || product.DeletedAt != nil {
+		return nil, repositories.ErrProductNotFound
+	}
+
+	product = s.withProductFile(product)
+	return &product, nil
+}
+
+func (s *Store) UpdateProduct(id int, userId uint, version int, req dto.UpdateProductRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, err := s.ownedProduct(id, userId, version)
+	if err != nil {
+		return err
+	}
+
+	return s.updateProduct(product, req)
+}
+
+func (s *Store) updateProduct(product models.Product, req dto.UpdateProductRequest) error {
+	id, userId := product.ID, product.UserID
+	if (req.Qty != nil || req.Price != nil) && len(s.variants[id]) > 0 {
+		return repositories.ErrProductHasVariants
+	}
+	if req.SKU != nil && s.skuTaken(userId, *req.SKU, id) {
+		return repositories.ErrProductSKUExists
+	}
+
+	if req.Name != nil {
+		product.Name = *req.Name
+	}
+	if req.Category != nil {
+		product.Category = *req.Category
+	}
+	if req.AllowNegativeStock != nil {
+		if !*req.AllowNegativeStock && product.Qty < 0 {
+			return fmt.Errorf("%w: restock the product before disallowing negative stock", repositories.ErrInsufficientStock)
+		}
+		product.AllowNegativeStock = *req.AllowNegativeStock
+	}
+	if req.Qty != nil && *req.Qty != product.Qty {
+		if err := repositories.CheckStockFloor(id, product.Qty, *req.Qty, product.ReservedQty, product.AllowNegativeStock); err != nil {
+			return err
+		}
+		s.recordStockMovement(&models.StockMovement{
+			ProductID: id,
+			Reason:    models.StockMovementCorrection,
+			Delta:     *req.Qty - product.Qty,
+			QtyAfter:  *req.Qty,
+			Note:      "qty set",
+			UserID:    userId,
+		})
+		product.Qty = *req.Qty
+	}
+	if req.Price != nil {
+		product.Price = float64(*req.Price)
+	}
+	if req.SKU != nil {
+		product.SKU = *req.SKU
+	}
+	if req.FileIDs != nil {
+		product.FileID = (*req.FileIDs)[0]
+		s.galleries[id] = append([]string(nil), *req.FileIDs...)
+	} else if req.FileID != nil {
+		product.FileID = *req.FileID
+		s.galleries[id][0] = *req.FileID
+	}
+	if req.Archived != nil {
+		product.Archived = *req.Archived
+	}
+	if req.LowStockThreshold != nil {
+		product.LowStockThreshold = *req.LowStockThreshold
+	}
+	product.Version++
+	product.UpdatedAt = time.Now()
+	s.products[id] = product
+	s.checkLowStock(id)
+
+	return nil
+}
+
+func (s *Store) DeleteProduct(id int, userId uint, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, err := s.ownedProduct(id, userId, version)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	product.DeletedAt = &now
+	product.Version++
+	s.products[id] = product
+	return nil
+}
+
+func (s *Store) RestoreProduct(id int, userId uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[id]
+	if !ok {
+		return repositories.ErrProductNotFound
+	}
+	if product.UserID != userId {
+		return repositories.ErrProductForbidden
+	}
+
+	if product.DeletedAt != nil {
+		if s.skuTaken(userId, product.SKU, id) {
+			return repositories.ErrProductSKUExists
+		}
+		product.DeletedAt = nil
+		product.Version++
+		product.UpdatedAt = time.Now()
+		s.products[id] = product
+	}
+	return nil
+}
+
+func (s *Store) PurgeDeletedProducts(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purchased := make(map[int]bool)
+	for _, purchase := range s.purchases {
+		for _, item := range purchase.Items {
+			purchased[item.ProductID] = true
+		}
+	}
+
+	purged := 0
+	for id, product := range s.products {
+		if product.DeletedAt == nil || !product.DeletedAt.Before(before) || purchased[id] {
+			continue
+		}
+		delete(s.products, id)
+		delete(s.galleries, id)
+		delete(s.variants, id)
+		s.deleteStockMovements(id)
+		s.deleteStockAlerts(id)
+		purged++
+	}
+	return purged, nil
+}
+
+// skuTaken mirrors the partial unique index on (user_id, sku): another
+// product of the user that is not deleted already has the sku.
+func (s *Store) skuTaken(userId uint, sku string, exceptId int) bool {
+	for id, product := range s.products {
+		if id != exceptId && product.UserID == userId && product.SKU == sku && product.DeletedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) deleteStockMovements(productId int) {
+	kept := s.movements[:0]
+	for _, movement := range s.movements {
+		if movement.ProductID != productId {
+			kept = append(kept, movement)
+		}
+	}
+	s.movements = kept
+}
+
+func (s *Store) ownedProduct(id int, userId uint, version int) (models.Product, error) {
+	product, ok := s.products[id]
+	if !ok || product.DeletedAt != nil {
+		return models.Product{}, repositories.ErrProductNotFound
+	}
+	if product.UserID != userId {
+		return models.Product{}, repositories.ErrProductForbidden
+	}
+	if version != 0 && product.Version != version {
+		return models.Product{}, repositories.ErrProductVersionMismatch
+	}
+	return product, nil
+}
+
+func (s *Store) withProductFile(product models.Product) models.Product {
+	product.File = s.files[product.FileID]
+	product.Images = nil
+	for _, fileId := range s.galleries[product.ID] {
+		product.Images = append(product.Images, s.files[fileId])
+	}
+	product.Variants = nil
+	for _, variant := range s.variants[product.ID] {
+		product.Variants = append(product.Variants, s.withVariantFile(variant))
+	}
+	return product
+}
+
+func matchesFilters(product models.Product, filters map[string]string) bool {
+	for key, value := range filters {
+		switch key {
+		case "product_id":
+			if strconv.Itoa(product.ID) != value {
+				return false
+			}
+		case "category":
+			matched := false
+			for _, category := range strings.Split(value, ",") {
+				if product.Category == category {
+					matched = true
+				}
+			}
+			if !matched {
+				return false
+			}
+		case "min_price":
+			minPrice, _ := strconv.ParseFloat(value, 64)
+			if product.Price < minPrice {
+				return false
+			}
+		case "max_price":
+			maxPrice, _ := strconv.ParseFloat(value, 64)
+			if product.Price > maxPrice {
+				return false
+			}
+		case "in_stock":
+			if (value == "true") != (product.Qty-product.ReservedQty > 0) {
+				return false
+			}
+		case "low_stock":
+			if product.LowStockThreshold == 0 || product.Qty-product.ReservedQty > product.LowStockThreshold {
+				return false
+			}
+		case "created_after":
+			after, _ := time.Parse(time.RFC3339Nano, value)
+			if product.CreatedAt.Before(after) {
+				return false
+			}
+		case "created_before":
+			before, _ := time.Parse(time.RFC3339Nano, value)
+			if !product.CreatedAt.Before(before) {
+				return false
+			}
+		case "sku":
+			if product.SKU != value {
+				return false
+			}
+		case "user_id":
+			if strconv.FormatUint(uint64(product.UserID), 10) != value {
+				return false
+			}
+		case "visible_to":
+			if product.Archived && strconv.FormatUint(uint64(product.UserID), 10) != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func lastModified(product models.Product) time.Time {
+	if product.UpdatedAt.After(product.CreatedAt) {
+		return product.UpdatedAt
+	}
+	return product.CreatedAt
+}
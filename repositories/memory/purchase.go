@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func (s *Store) CreatePurchase(req dto.CreatePurchaseRequest, reservedUntil time.Time) (models.Purchase, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type purchaseLine struct {
+		productId int
+		variantId int
+	}
+
+	quantities := make(map[purchaseLine]int)
+	for _, item := range req.PurchasedItems {
+		productId, err := strconv.Atoi(item.ProductID)
+		if err != nil {
+			return models.Purchase{}, fmt.Errorf("%w: %s", repositories.ErrProductNotFound, item.ProductID)
+		}
+		line := purchaseLine{productId: productId}
+		if item.VariantID != "" {
+			if line.variantId, err = strconv.Atoi(item.VariantID); err != nil {
+				return models.Purchase{}, fmt.Errorf("%w: %s", repositories.ErrVariantNotFound, item.VariantID)
+			}
+		}
+		quantities[line] += item.Qty
+	}
+
+	lines := make([]purchaseLine, 0, len(quantities))
+	for line := range quantities {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].productId != lines[j].productId {
+			return lines[i].productId < lines[j].productId
+		}
+		return lines[i].variantId < lines[j].variantId
+	})
+
+	purchase := models.Purchase{
+		SenderName:          req.SenderName,
+		SenderContactType:   req.SenderContactType,
+		SenderContactDetail: req.SenderContactDetail,
+		Status:              models.PurchaseStatusPending,
+		ReservedUntil:       &reservedUntil,
+	}
+
+	productQty := make(map[int]int)
+	for _, line := range lines {
+		productQty[line.productId] += quantities[line]
+	}
+
+	for _, line := range lines {
+		product, ok := s.products[line.productId]
+		if !ok || product.DeletedAt != nil || product.Archived {
+			return models.Purchase{}, fmt.Errorf("%w: %d", repositories.ErrProductNotFound, line.productId)
+		}
+
+		item := models.PurchaseItem{
+			ProductID: line.productId,
+			Product:   s.withProductFile(product),
+			SellerID:  product.UserID,
+			Seller:    s.users[product.UserID],
+			Qty:       quantities[line],
+			Price:     product.Price,
+		}
+
+		checkStock := !product.AllowNegativeStock
+		if line.variantId == 0 {
+			if len(s.variants[line.productId]) > 0 {
+				return models.Purchase{}, fmt.Errorf("%w: product %d", repositories.ErrVariantRequired, line.productId)
+			}
+		} else {
+			i := s.variantIndex(line.productId, line.variantId)
+			if i < 0 {
+				return models.Purchase{}, fmt.Errorf("%w: product %d has no variant %d", repositories.ErrVariantNotFound, line.productId, line.variantId)
+			}
+			variant := s.variants[line.productId][i]
+			if available := variant.Qty - variant.ReservedQty; checkStock && available < item.Qty {
+				return models.Purchase{}, fmt.Errorf("%w: variant %d of product %d has %d available", repositories.ErrInsufficientStock, variant.ID, line.productId, available)
+			}
+			item.VariantID = variant.ID
+			item.Variant = s.withVariantFile(variant)
+			item.Price = variant.Price
+		}
+
+		if available := product.Qty - product.ReservedQty; checkStock && available < productQty[line.productId] {
+			return models.Purchase{}, fmt.Errorf("%w: product %d has %d available", repositories.ErrInsufficientStock, line.productId, available)
+		}
+
+		purchase.Items = append(purchase.Items, item)
+		purchase.TotalPrice += item.Price * float64(item.Qty)
+	}
+
+	s.reserveStock(purchase.Items, 1)
+	for i, item := range purchase.Items {
+		purchase.Items[i].Product = s.withProductFile(s.products[item.ProductID])
+		if item.VariantID != 0 {
+			purchase.Items[i].Variant = s.withVariantFile(s.variants[item.ProductID][s.variantIndex(item.ProductID, item.VariantID)])
+		}
+	}
+
+	s.nextPurchaseId++
+	now := time.Now()
+	purchase.ID = s.nextPurchaseId
+	purchase.PublicID = newUUID()
+	purchase.CreatedAt = now
+	purchase.UpdatedAt = now
+	for i := range purchase.Items {
+		s.nextItemId++
+		purchase.Items[i].ID = s.nextItemId
+		purchase.Items[i].PurchaseID = purchase.ID
+	}
+	s.purchases[purchase.ID] = purchase
+
+	return purchase, nil
+}
+
+func (s *Store) ConfirmPayment(publicId string, fileIds []string) (models.Purchase, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purchase models.Purchase
+	for _, candidate := range s.purchases {
+		if candidate.PublicID == publicId {
+			purchase = candidate
+		}
+	}
+	if purchase.ID == 0 {
+		return models.Purchase{}, repositories.ErrPurchaseNotFound
+	}
+	if purchase.Status == models.PurchaseStatusPaid {
+		return models.Purchase{}, repositories.ErrPurchaseAlreadyPaid
+	}
+	if purchase.Status == models.PurchaseStatusExpired || (purchase.ReservedUntil != nil && !purchase.ReservedUntil.After(time.Now())) {
+		return models.Purchase{}, repositories.ErrPurchaseExpired
+	}
+
+	sellers := make(map[uint]bool)
+	for _, item := range purchase.Items {
+		sellers[item.SellerID] = true
+	}
+	if len(fileIds) != len(sellers) {
+		return models.Purchase{}, fmt.Errorf("%w: expected %d, got %d", repositories.ErrPaymentProofMismatch, len(sellers), len(fileIds))
+	}
+
+	for _, fileId := range fileIds {
+		if _, ok := s.files[fileId]; !ok {
+			return models.Purchase{}, repositories.ErrFileNotFound
+		}
+	}
+
+	productQty := make(map[int]int)
+	variantQty := make(map[int]int)
+	for _, item := range purchase.Items {
+		if item.VariantID != 0 {
+			i := s.variantIndex(item.ProductID, item.VariantID)
+			if i < 0 {
+				return models.Purchase{}, repositories.ErrVariantNotFound
+			}
+			variantQty[item.VariantID] += item.Qty
+			if !s.products[item.ProductID].AllowNegativeStock && s.variants[item.ProductID][i].Qty < variantQty[item.VariantID] {
+				return models.Purchase{}, fmt.Errorf("%w: variant %d of product %d", repositories.ErrInsufficientStock, item.VariantID, item.ProductID)
+			}
+		}
+		productQty[item.ProductID] += item.Qty
+		if !s.products[item.ProductID].AllowNegativeStock && s.products[item.ProductID].Qty < productQty[item.ProductID] {
+			return models.Purchase{}, fmt.Errorf("%w: product %d", repositories.ErrInsufficientStock, item.ProductID)
+		}
+	}
+
+	if purchase.ReservedUntil != nil {
+		s.reserveStock(purchase.Items, -1)
+	}
+
+	now := time.Now()
+	items := make([]models.PurchaseItem, len(purchase.Items))
+	for i, item := range purchase.Items {
+		movement := models.StockMovement{
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Reason:     models.StockMovementSale,
+			Delta:      -item.Qty,
+			PurchaseID: purchase.ID,
+		}
+		if err := s.applyStockMovement(&movement); err != nil {
+			return models.Purchase{}, err
+		}
+		s.sales = append(s.sales, sale{ProductID: item.ProductID, Qty: item.Qty, SoldAt: now})
+
+		if item.VariantID != 0 {
+			item.Variant = s.withVariantFile(s.variants[item.ProductID][s.variantIndex(item.ProductID, item.VariantID)])
+		}
+		item.Product = s.withProductFile(s.products[item.ProductID])
+		items[i] = item
+	}
+
+	purchase.Items = items
+	purchase.Status = models.PurchaseStatusPaid
+	purchase.ReservedUntil = nil
+	purchase.UpdatedAt = now
+	s.purchases[purchase.ID] = purchase
+
+	return purchase, nil
+}
+
+func (s *Store) ExpireReservations(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := 0
+	for id, purchase := range s.purchases {
+		if purchase.Status != models.PurchaseStatusPending || purchase.ReservedUntil == nil || purchase.ReservedUntil.After(now) {
+			continue
+		}
+		s.reserveStock(purchase.Items, -1)
+		purchase.Status = models.PurchaseStatusExpired
+		purchase.ReservedUntil = nil
+		purchase.UpdatedAt = time.Now()
+		s.purchases[id] = purchase
+		expired++
+	}
+	return expired, nil
+}
+
+// reserveStock adds the items' quantities, times sign, to the reserved
+// quantity of their products and variants.
+func (s *Store) reserveStock(items []models.PurchaseItem, sign int) {
+	for _, item := range items {
+		product := s.products[item.ProductID]
+		product.ReservedQty += sign * item.Qty
+		s.products[item.ProductID] = product
+
+		if i := s.variantIndex(item.ProductID, item.VariantID); item.VariantID != 0 && i >= 0 {
+			s.variants[item.ProductID][i].ReservedQty += sign * item.Qty
+		}
+	}
+}
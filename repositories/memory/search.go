@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"regexp"
+	"strings"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// searchScore mirrors the Postgres search: every word of q must be a prefix
+// of a word in the product's name, SKU or category, or q must be similar
+// enough to the name to survive a typo. It returns the relevance and whether
+// the product matched at all.
+func searchScore(product models.Product, q string) (float64, bool) {
+	queryWords := wordPattern.FindAllString(strings.ToLower(q), -1)
+	if len(queryWords) == 0 {
+		return 0, true
+	}
+
+	productWords := wordPattern.FindAllString(strings.ToLower(product.Name+" "+product.SKU+" "+product.Category), -1)
+
+	prefixMatch := true
+	for _, queryWord := range queryWords {
+		found := false
+		for _, productWord := range productWords {
+			if strings.HasPrefix(productWord, queryWord) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			prefixMatch = false
+			break
+		}
+	}
+
+	similarity := wordSimilarity(q, product.Name)
+	if !prefixMatch && similarity < repositories.SearchSimilarityThreshold {
+		return 0, false
+	}
+
+	score := similarity
+	if prefixMatch {
+		score += 1
+	}
+	return score, true
+}
+
+// wordSimilarity approximates pg_trgm's word_similarity: the best trigram
+// similarity between a and any contiguous run of trigrams taken from b.
+func wordSimilarity(a, b string) float64 {
+	queryTrigrams := make(map[string]bool)
+	for _, trigram := range trigrams(a) {
+		queryTrigrams[trigram] = true
+	}
+	if len(queryTrigrams) == 0 {
+		return 0
+	}
+
+	target := trigrams(b)
+	best := 0.0
+	for start := range target {
+		extent := make(map[string]bool)
+		shared := 0
+		for end := start; end < len(target); end++ {
+			if !extent[target[end]] {
+				extent[target[end]] = true
+				if queryTrigrams[target[end]] {
+					shared++
+				}
+			}
+			similarity := float64(shared) / float64(len(queryTrigrams)+len(extent)-shared)
+			if similarity > best {
+				best = similarity
+			}
+		}
+	}
+	return best
+}
+
+// trigrams lists the trigrams of every word in s in order, padding each word
+// with two leading spaces and one trailing space the way pg_trgm does.
+func trigrams(s string) []string {
+	var result []string
+	for _, word := range wordPattern.FindAllString(strings.ToLower(s), -1) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			result = append(result, string(padded[i:i+3]))
+		}
+	}
+	return result
+}
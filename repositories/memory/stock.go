@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func (s *Store) AdjustStock(productId int, userId uint, req dto.StockAdjustmentRequest) (models.StockMovement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.ownedProduct(productId, userId, 0); err != nil {
+		return models.StockMovement{}, err
+	}
+
+	movement := models.StockMovement{
+		ProductID: productId,
+		Reason:    req.Reason,
+		Delta:     req.Delta,
+		Note:      req.Note,
+		UserID:    userId,
+	}
+	if req.VariantID != "" {
+		variantId, err := strconv.Atoi(req.VariantID)
+		if err != nil {
+			return models.StockMovement{}, repositories.ErrVariantNotFound
+		}
+		movement.VariantID = variantId
+	}
+
+	if err := s.applyStockMovement(&movement); err != nil {
+		return models.StockMovement{}, err
+	}
+	return movement, nil
+}
+
+func (s *Store) StockHistory(productId int, userId uint, limit, offset int) ([]models.StockMovement, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.ownedProduct(productId, userId, 0); err != nil {
+		return nil, 0, err
+	}
+
+	var movements []models.StockMovement
+	for _, movement := range s.movements {
+		if movement.ProductID == productId {
+			movement.PurchasePublicID = s.purchases[movement.PurchaseID].PublicID
+			movements = append(movements, movement)
+		}
+	}
+	sort.Slice(movements, func(i, j int) bool { return movements[i].ID > movements[j].ID })
+
+	total := len(movements)
+	if offset >= total {
+		return nil, total, nil
+	}
+	movements = movements[offset:]
+	if limit < len(movements) {
+		movements = movements[:limit]
+	}
+	return movements, total, nil
+}
+
+// applyStockMovement mirrors the Postgres repository: it checks and moves
+// the stock of the product, and of its variant, then records the movement.
+func (s *Store) applyStockMovement(movement *models.StockMovement) error {
+	product := s.products[movement.ProductID]
+
+	variantIndex := -1
+	qtyBefore, reserved := product.Qty, product.ReservedQty
+	if movement.VariantID == 0 {
+		if len(s.variants[movement.ProductID]) > 0 {
+			return fmt.Errorf("%w: product %d", repositories.ErrVariantRequired, movement.ProductID)
+		}
+	} else {
+		variantIndex = s.variantIndex(movement.ProductID, movement.VariantID)
+		if variantIndex < 0 {
+			return repositories.ErrVariantNotFound
+		}
+		variant := s.variants[movement.ProductID][variantIndex]
+		qtyBefore, reserved = variant.Qty, variant.ReservedQty
+	}
+	movement.QtyAfter = qtyBefore + movement.Delta
+	if err := repositories.CheckStockFloor(movement.ProductID, qtyBefore, movement.QtyAfter, reserved, product.AllowNegativeStock); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if variantIndex >= 0 {
+		variant := s.variants[movement.ProductID][variantIndex]
+		variant.Qty += movement.Delta
+		variant.UpdatedAt = now
+		s.variants[movement.ProductID][variantIndex] = variant
+	}
+	product.Qty += movement.Delta
+	product.Version++
+	s.products[movement.ProductID] = product
+
+	s.recordStockMovement(movement)
+	s.checkLowStock(movement.ProductID)
+	return nil
+}
+
+// recordStockMovement appends a movement whose stock change the caller
+// makes.
+func (s *Store) recordStockMovement(movement *models.StockMovement) {
+	s.nextMovementId++
+	movement.ID = s.nextMovementId
+	movement.CreatedAt = time.Now()
+	s.movements = append(s.movements, *movement)
+}
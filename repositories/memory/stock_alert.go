@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"time"
+	"tutuplapak/models"
+)
+
+func (s *Store) PendingStockAlerts(limit int) ([]models.StockAlert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var alerts []models.StockAlert
+	for _, alert := range s.alerts {
+		if alert.DeliveredAt != nil || s.products[alert.ProductID].DeletedAt != nil {
+			continue
+		}
+		alert.Product = s.products[alert.ProductID]
+		alert.Seller = s.users[alert.UserID]
+		alerts = append(alerts, alert)
+		if len(alerts) == limit {
+			break
+		}
+	}
+	return alerts, nil
+}
+
+func (s *Store) MarkStockAlertDelivered(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.alerts {
+		if s.alerts[i].ID == id {
+			now := time.Now()
+			s.alerts[i].DeliveredAt = &now
+		}
+	}
+	return nil
+}
+
+// checkLowStock mirrors the Postgres repository: falling to the threshold
+// raises one alert, and climbing back above it re-arms the product.
+func (s *Store) checkLowStock(productId int) {
+	product := s.products[productId]
+	low := product.LowStockThreshold > 0 && product.Qty <= product.LowStockThreshold
+	if low == product.LowStockAlerted {
+		return
+	}
+
+	product.LowStockAlerted = low
+	s.products[productId] = product
+	if !low {
+		return
+	}
+
+	s.nextAlertId++
+	s.alerts = append(s.alerts, models.StockAlert{
+		ID:        s.nextAlertId,
+		ProductID: productId,
+		UserID:    product.UserID,
+		Qty:       product.Qty,
+		Threshold: product.LowStockThreshold,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (s *Store) deleteStockAlerts(productId int) {
+	kept := s.alerts[:0]
+	for _, alert := range s.alerts {
+		if alert.ProductID != productId {
+			kept = append(kept, alert)
+		}
+	}
+	s.alerts = kept
+}
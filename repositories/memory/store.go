@@ -0,0 +1,101 @@
+// Package memory is an in-memory implementation of the repositories store
+// interfaces. It keeps the same error contract as the Postgres repositories
+// so handlers can be exercised without a database.
+package memory
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+type sale struct {
+	ProductID int
+	Qty       int
+	SoldAt    time.Time
+}
+
+// Store holds every table in maps guarded by a single mutex, which also
+// gives the purchase operations the same all-or-nothing behaviour as the
+// Postgres transactions.
+type Store struct {
+	mu sync.Mutex
+
+	files      map[string]models.File
+	users      map[uint]models.User
+	products   map[int]models.Product
+	galleries  map[int][]string
+	variants   map[int][]models.ProductVariant
+	purchases  map[int]models.Purchase
+	categories map[uint]models.ProductCategory
+	sales      []sale
+	movements  []models.StockMovement
+	alerts     []models.StockAlert
+
+	nextUserId     uint
+	nextProductId  int
+	nextVariantId  int
+	nextPurchaseId int
+	nextItemId     int
+	nextCategoryId uint
+	nextMovementId int
+	nextAlertId    int
+}
+
+var (
+	_ repositories.ProductStore    = (*Store)(nil)
+	_ repositories.FileStore       = (*Store)(nil)
+	_ repositories.UserStore       = (*Store)(nil)
+	_ repositories.PurchaseStore   = (*Store)(nil)
+	_ repositories.CategoryStore   = (*Store)(nil)
+	_ repositories.StockAlertStore = (*Store)(nil)
+)
+
+// New returns an empty store seeded with the same categories as the
+// product_categories migration.
+func New() *Store {
+	s := &Store{
+		files:      make(map[string]models.File),
+		users:      make(map[uint]models.User),
+		products:   make(map[int]models.Product),
+		galleries:  make(map[int][]string),
+		variants:   make(map[int][]models.ProductVariant),
+		purchases:  make(map[int]models.Purchase),
+		categories: make(map[uint]models.ProductCategory),
+	}
+	for _, categoryType := range []string{"Food", "Beverage", "Clothes", "Furniture", "Tools"} {
+		s.nextCategoryId++
+		s.categories[s.nextCategoryId] = models.ProductCategory{ID: s.nextCategoryId, Type: categoryType, IsActive: true}
+	}
+	return s
+}
+
+// SetAdmin grants or revokes admin rights, which the API has no endpoint
+// for.
+func (s *Store) SetAdmin(id uint, isAdmin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return repositories.ErrUserNotFound
+	}
+	user.IsAdmin = isAdmin
+	s.users[id] = user
+	return nil
+}
+
+// newUUID returns a random version 4 UUID, matching what gen_random_uuid()
+// produces for the files table.
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func (s *Store) CreateUser(email, phone, passwordHash string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.identifierTaken(0, email, phone) {
+		return models.User{}, repositories.ErrUserAlreadyExists
+	}
+
+	s.nextUserId++
+	now := time.Now()
+	user := models.User{
+		ID:           s.nextUserId,
+		Email:        email,
+		Phone:        phone,
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.users[user.ID] = user
+
+	return s.withUserFile(user), nil
+}
+
+func (s *Store) GetUserById(id uint) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return models.User{}, repositories.ErrUserNotFound
+	}
+	return s.withUserFile(user), nil
+}
+
+func (s *Store) GetUserByEmail(email string) (models.User, error) {
+	return s.findUser(func(user models.User) bool { return email != "" && user.Email == email })
+}
+
+func (s *Store) GetUserByPhone(phone string) (models.User, error) {
+	return s.findUser(func(user models.User) bool { return phone != "" && user.Phone == phone })
+}
+
+func (s *Store) UpdateProfile(id uint, req dto.UpdateUserRequest) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return models.User{}, repositories.ErrUserNotFound
+	}
+
+	user.FileID = req.FileID
+	user.BankAccountName = req.BankAccountName
+	user.BankAccountHolder = req.BankAccountHolder
+	user.BankAccountNumber = req.BankAccountNumber
+	user.UpdatedAt = time.Now()
+	s.users[id] = user
+
+	return s.withUserFile(user), nil
+}
+
+func (s *Store) LinkEmail(id uint, email string) (models.User, error) {
+	return s.linkIdentifier(id, func(user *models.User) *string { return &user.Email }, email, "")
+}
+
+func (s *Store) LinkPhone(id uint, phone string) (models.User, error) {
+	return s.linkIdentifier(id, func(user *models.User) *string { return &user.Phone }, "", phone)
+}
+
+// linkIdentifier sets the identifier field picks out to the email or phone
+// being linked, unless the user already has one.
+func (s *Store) linkIdentifier(id uint, field func(user *models.User) *string, email, phone string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return models.User{}, repositories.ErrUserNotFound
+	}
+	identifier := field(&user)
+	if *identifier != "" {
+		return models.User{}, repositories.ErrIdentifierLinked
+	}
+	if s.identifierTaken(id, email, phone) {
+		return models.User{}, repositories.ErrUserAlreadyExists
+	}
+
+	if email != "" {
+		*identifier = email
+	} else {
+		*identifier = phone
+	}
+	user.UpdatedAt = time.Now()
+	s.users[id] = user
+
+	return s.withUserFile(user), nil
+}
+
+func (s *Store) findUser(match func(user models.User) bool) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if match(user) {
+			return s.withUserFile(user), nil
+		}
+	}
+	return models.User{}, repositories.ErrUserNotFound
+}
+
+// identifierTaken reports whether a user other than exceptId already uses
+// the non-empty email or phone.
+func (s *Store) identifierTaken(exceptId uint, email, phone string) bool {
+	for _, user := range s.users {
+		if user.ID == exceptId {
+			continue
+		}
+		if (email != "" && user.Email == email) || (phone != "" && user.Phone == phone) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) withUserFile(user models.User) models.User {
+	user.File = s.files[user.FileID]
+	return user
+}
@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"strings"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func (s *Store) CreateVariant(productId int, userId uint, req dto.CreateVariantRequest) (models.ProductVariant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, err := s.ownedProduct(productId, userId, 0)
+	if err != nil {
+		return models.ProductVariant{}, err
+	}
+	if len(product.Options) == 0 {
+		return models.ProductVariant{}, repositories.ErrProductHasNoOptions
+	}
+	if len(s.variants[productId]) == 0 && (product.Qty != 0 || product.ReservedQty != 0) {
+		return models.ProductVariant{}, repositories.ErrProductHasStock
+	}
+
+	values, err := repositories.VariantOptionValues(product.Options, req.Options)
+	if err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	variant, err := s.insertVariant(productId, userId, values, req)
+	if err != nil {
+		return models.ProductVariant{}, err
+	}
+	s.refreshVariantTotals(productId)
+
+	return s.withVariantFile(variant), nil
+}
+
+func (s *Store) UpdateVariant(productId, variantId int, userId uint, req dto.UpdateVariantRequest) (models.ProductVariant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.ownedProduct(productId, userId, 0); err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	i := s.variantIndex(productId, variantId)
+	if i < 0 {
+		return models.ProductVariant{}, repositories.ErrVariantNotFound
+	}
+
+	variant := s.variants[productId][i]
+	if req.SKU != nil {
+		variant.SKU = *req.SKU
+	}
+	if req.Price != nil {
+		variant.Price = float64(*req.Price)
+	}
+	if req.Qty != nil && *req.Qty != variant.Qty {
+		product := s.products[productId]
+		if err := repositories.CheckStockFloor(productId, variant.Qty, *req.Qty, variant.ReservedQty, product.AllowNegativeStock); err != nil {
+			return models.ProductVariant{}, err
+		}
+		s.recordStockMovement(&models.StockMovement{
+			ProductID: productId,
+			VariantID: variantId,
+			Reason:    models.StockMovementCorrection,
+			Delta:     *req.Qty - variant.Qty,
+			QtyAfter:  *req.Qty,
+			Note:      "qty set",
+			UserID:    userId,
+		})
+		variant.Qty = *req.Qty
+	}
+	if req.FileID != nil {
+		variant.FileID = *req.FileID
+	}
+	variant.UpdatedAt = time.Now()
+	s.variants[productId][i] = variant
+	s.refreshVariantTotals(productId)
+
+	return s.withVariantFile(variant), nil
+}
+
+func (s *Store) DeleteVariant(productId, variantId int, userId uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.ownedProduct(productId, userId, 0); err != nil {
+		return err
+	}
+
+	i := s.variantIndex(productId, variantId)
+	if i < 0 {
+		return repositories.ErrVariantNotFound
+	}
+
+	for _, purchase := range s.purchases {
+		for _, item := range purchase.Items {
+			if item.VariantID == variantId {
+				return repositories.ErrVariantInUse
+			}
+		}
+	}
+
+	variants := s.variants[productId]
+	if variants[i].Qty != 0 {
+		s.recordStockMovement(&models.StockMovement{
+			ProductID: productId,
+			VariantID: variantId,
+			Reason:    models.StockMovementCorrection,
+			Delta:     -variants[i].Qty,
+			QtyAfter:  0,
+			Note:      "variant deleted",
+			UserID:    userId,
+		})
+	}
+	// Like ON DELETE SET NULL, the movements stay with the product
+	for j := range s.movements {
+		if s.movements[j].VariantID == variantId {
+			s.movements[j].VariantID = 0
+		}
+	}
+	s.variants[productId] = append(variants[:i:i], variants[i+1:]...)
+	s.refreshVariantTotals(productId)
+	return nil
+}
+
+// insertVariant appends a variant to the product, rejecting a second
+// variant with the same option values like the unique constraint does, and
+// records its initial stock.
+func (s *Store) insertVariant(productId int, userId uint, values []string, req dto.CreateVariantRequest) (models.ProductVariant, error) {
+	key := strings.Join(values, "\x00")
+	for _, existing := range s.variants[productId] {
+		if strings.Join(existing.OptionValues, "\x00") == key {
+			return models.ProductVariant{}, repositories.ErrVariantAlreadyExists
+		}
+	}
+
+	s.nextVariantId++
+	now := time.Now()
+	variant := models.ProductVariant{
+		ID:           s.nextVariantId,
+		ProductID:    productId,
+		OptionValues: values,
+		SKU:          req.SKU,
+		Price:        float64(req.Price),
+		Qty:          req.Qty,
+		FileID:       req.FileID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.variants[productId] = append(s.variants[productId], variant)
+
+	if req.Qty != 0 {
+		s.recordStockMovement(&models.StockMovement{
+			ProductID: productId,
+			VariantID: variant.ID,
+			Reason:    models.StockMovementRestock,
+			Delta:     req.Qty,
+			QtyAfter:  req.Qty,
+			Note:      "initial stock",
+			UserID:    userId,
+		})
+	}
+	return variant, nil
+}
+
+func (s *Store) variantIndex(productId, variantId int) int {
+	for i, variant := range s.variants[productId] {
+		if variant.ID == variantId {
+			return i
+		}
+	}
+	return -1
+}
+
+// refreshVariantTotals mirrors the Postgres repository: the product's qty is
+// the stock of its variants and its price the cheapest one.
+func (s *Store) refreshVariantTotals(productId int) {
+	product := s.products[productId]
+	product.Qty = 0
+	for i, variant := range s.variants[productId] {
+		product.Qty += variant.Qty
+		if i == 0 || variant.Price < product.Price {
+			product.Price = variant.Price
+		}
+	}
+	product.Version++
+	product.UpdatedAt = time.Now()
+	s.products[productId] = product
+	s.checkLowStock(productId)
+}
+
+func (s *Store) withVariantFile(variant models.ProductVariant) models.ProductVariant {
+	variant.File = s.files[variant.FileID]
+	return variant
+}
@@ -3,12 +3,41 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
-	"tutuplapak/db"
+	"time"
 	"tutuplapak/dto"
 	"tutuplapak/models"
+
+	"github.com/lib/pq"
+)
+
+// SearchSimilarityThreshold is the minimum pg_trgm word_similarity between
+// the search text and a product name for the product to match despite typos,
+// e.g. "kofee" still finds "Coffee". Searches set it as the session's
+// pg_trgm.word_similarity_threshold so the <% operator can use the trigram
+// index on products.name. A one letter typo in a short word scores only
+// about 0.33 ("kofee" against "Coffee"), so raising this loses those
+// matches; TestSearchSimilarityPostgres pins the cases that must still hit.
+const SearchSimilarityThreshold = 0.3
+
+// queryer is what FilterProducts and CountProducts need from *sql.DB, or
+// from the *sql.Tx a search runs in.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+var tsQueryTokenPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+var (
+	ErrProductNotFound        = errors.New("product not found")
+	ErrProductForbidden       = errors.New("product belongs to another user")
+	ErrProductVersionMismatch = errors.New("product has been modified")
+	ErrProductSKUExists       = errors.New("you already have a product with this sku")
 )
 
 type ProductRepository struct {
@@ -19,11 +48,55 @@ func NewProductRepository(db *sql.DB) *ProductRepository {
 	return &ProductRepository{DB: db}
 }
 
-func (r *ProductRepository) CreateProduct(req dto.CreateProductRequest) (models.Product, error) {
+// CreateProduct inserts the product together with its gallery and
+// variants. FileID is the primary image; FileIDs, when set, is the whole
+// gallery starting with it. A product with variants takes its qty and price
+// from them.
+func (r *ProductRepository) CreateProduct(userId uint, req dto.CreateProductRequest) (models.Product, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return models.Product{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	product, err := createProduct(tx, userId, req)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Product{}, fmt.Errorf("failed to commit product: %v", err)
+	}
+
+	products := []models.Product{product}
+	if err := r.attachDetails(products); err != nil {
+		return models.Product{}, err
+	}
+
+	return products[0], nil
+}
+
+// createProduct does the work of CreateProduct inside tx.
+func createProduct(tx *sql.Tx, userId uint, req dto.CreateProductRequest) (models.Product, error) {
+	fileIds := req.FileIDs
+	if len(fileIds) == 0 {
+		fileIds = []string{req.FileID}
+	}
+
+	variantValues := make([][]string, len(req.Variants))
+	for i, variant := range req.Variants {
+		values, err := VariantOptionValues(req.Options, variant.Options)
+		if err != nil {
+			return models.Product{}, err
+		}
+		variantValues[i] = values
+	}
+	qty, price := VariantTotals(req)
+
 	query := `
 				WITH inserted_product AS (
-					INSERT INTO products (name, category, qty, price, sku, fileId)
-					VALUES ($1, $2, $3, $4, $5, $6)
+					INSERT INTO products (name, category, qty, price, sku, file_id, user_id, option_names, allow_negative_stock, low_stock_threshold)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 					RETURNING *
 				)
 				SELECT 
@@ -33,33 +106,74 @@ func (r *ProductRepository) CreateProduct(req dto.CreateProductRequest) (models.
 					inserted_product.qty,
 					inserted_product.price,
 					inserted_product.sku,
+					inserted_product.user_id,
+					inserted_product.version,
+					inserted_product.option_names,
+					inserted_product.allow_negative_stock,
+					inserted_product.low_stock_threshold,
 					inserted_product.created_at,
 					inserted_product.updated_at,
 					files.id AS file_id,
 					files.original_file_uri AS file_uri,
 					files.compressed_file_uri AS file_thumbnail_uri
 				FROM inserted_product
-				JOIN files ON inserted_product.fileId = files.id;
+				JOIN files ON inserted_product.file_id = files.id;
 			`
 
 	var product models.Product
-	err := db.DB.QueryRow(query, req.Name, req.Category, req.Qty, req.Price, req.SKU, req.FileID).Scan(
+	err := tx.QueryRow(query, req.Name, req.Category, qty, price, req.SKU, fileIds[0], userId, pq.Array(req.Options), req.AllowNegativeStock, req.LowStockThreshold).Scan(
 		&product.ID,
 		&product.Name,
 		&product.Category,
 		&product.Qty,
 		&product.Price,
 		&product.SKU,
+		&product.UserID,
+		&product.Version,
+		pq.Array(&product.Options),
+		&product.AllowNegativeStock,
+		&product.LowStockThreshold,
 		&product.CreatedAt,
 		&product.UpdatedAt,
 		&product.File.FileID,
 		&product.File.FileUri,
 		&product.File.FileThumbnailUri,
 	)
+	if isUniqueViolation(err) {
+		return models.Product{}, ErrProductSKUExists
+	}
 	if err != nil {
 		return models.Product{}, fmt.Errorf("failed to create product: %v", err)
 	}
 
+	if err := replaceProductFiles(tx, product.ID, fileIds); err != nil {
+		return models.Product{}, err
+	}
+
+	// The opening stock is the first entry of the ledger, per variant when
+	// there are variants
+	for i, variant := range req.Variants {
+		if _, err := insertVariant(tx, product.ID, userId, variantValues[i], variant); err != nil {
+			return models.Product{}, err
+		}
+	}
+	if len(req.Variants) == 0 {
+		movement := models.StockMovement{
+			ProductID: product.ID,
+			Reason:    models.StockMovementRestock,
+			Delta:     product.Qty,
+			QtyAfter:  product.Qty,
+			Note:      "initial stock",
+			UserID:    userId,
+		}
+		if err := insertStockMovement(tx, &movement); err != nil {
+			return models.Product{}, err
+		}
+	}
+	if err := checkLowStock(tx, product.ID); err != nil {
+		return models.Product{}, err
+	}
+
 	return product, nil
 }
 
@@ -70,8 +184,15 @@ func (r *ProductRepository) FilterProducts(filters map[string]string) ([]models.
 			products.name,
 			products.category,
 			products.qty,
+			products.reserved_qty,
 			products.price,
 			products.sku,
+			products.user_id,
+			products.version,
+			products.option_names,
+			products.is_archived,
+			products.allow_negative_stock,
+			products.low_stock_threshold,
 			files.id,
 			files.original_file_uri,
 			files.compressed_file_uri,
@@ -79,44 +200,52 @@ func (r *ProductRepository) FilterProducts(filters map[string]string) ([]models.
 			products.updated_at
 		FROM products
 		JOIN files
-		ON files.id = products.id
+		ON files.id = products.file_id
 	`
 
-	args := []interface{}{}
-	argCount := 1
+	whereClause, relevance, args := productFilterClause(filters)
+	argCount := len(args) + 1
 
-	whereClause := " WHERE 1=1"
+	db, done, err := r.searchQueryer(relevance != "")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 
-	for key, value := range filters {
-		switch key {
-		case "product_id":
-			whereClause += fmt.Sprintf(" AND products.id = $%d", argCount)
-			args = append(args, value)
-			argCount++
-		case "category":
-			whereClause += fmt.Sprintf(" AND products.category = $%d", argCount)
-			args = append(args, value)
-			argCount++
-		case "sku":
-			whereClause += fmt.Sprintf(" AND products.sku = $%d", argCount)
-			args = append(args, value)
-			argCount++
-		default:
-			// Ignore unknown filters
-			continue
-		}
+	// Keyset pagination walks products newest first, continuing after the
+	// (created_at, id) of the last product on the previous page if given
+	keyset := filters["keyset"] == "true"
+	if _, ok := filters["cursor_id"]; keyset && ok {
+		whereClause += fmt.Sprintf(" AND (products.created_at, products.id) < ($%d, $%d)", argCount, argCount+1)
+		args = append(args, filters["cursor_created_at"], filters["cursor_id"])
+		argCount += 2
 	}
 
 	// Append the WHERE clause to the query
 	query += whereClause
 
-	// Handle SORT BY
-	if sortBy, ok := filters["sort_by"]; ok {
+	// Handle SORT BY, ranking search results by relevance unless told otherwise
+	sortBy, ok := filters["sort_by"]
+	if !ok && relevance != "" {
+		sortBy, ok = "relevance", true
+	}
+	// Every order ends on products.id so offset pages never skip or repeat
+	// rows that tie on the sort key
+	orderBy := "products.id"
+	if keyset {
+		orderBy = "products.created_at DESC, products.id DESC"
+	} else if ok {
 		switch sortBy {
+		case "relevance":
+			if relevance != "" {
+				orderBy = relevance + " DESC, products.id"
+			}
 		case "newest":
-			query += " ORDER BY GREATEST(products.created_at, products.updated_at) DESC"
+			orderBy = "GREATEST(products.created_at, products.updated_at) DESC, products.id"
 		case "cheapest":
-			query += " ORDER BY products.price ASC"
+			orderBy = "products.price ASC, products.id"
+		case "lowest_stock":
+			orderBy = "products.qty ASC, products.id"
 		default:
 			if strings.HasPrefix(sortBy, "sold-") {
 				// Extract the number of seconds from "sold-x"
@@ -124,17 +253,17 @@ func (r *ProductRepository) FilterProducts(filters map[string]string) ([]models.
 				seconds, err := strconv.Atoi(secondsStr)
 				if err == nil && seconds > 0 {
 					// Assuming you have a "sales" table with a "sold_at" timestamp column
-					query += fmt.Sprintf(`
-						ORDER BY (
+					orderBy = fmt.Sprintf(`(
 							SELECT COUNT(*) 
 							FROM sales 
 							WHERE sales.product_id = products.id 
 							AND sales.sold_at >= NOW() - INTERVAL '%d seconds'
-						) DESC`, seconds)
+						) DESC, products.id`, seconds)
 				}
 			}
 		}
 	}
+	query += " ORDER BY " + orderBy
 
 	limit, _ := strconv.Atoi(filters["limit"])
 	offset, _ := strconv.Atoi(filters["offset"])
@@ -143,13 +272,13 @@ func (r *ProductRepository) FilterProducts(filters map[string]string) ([]models.
 		args = append(args, limit)
 		argCount++
 	}
-	if offset > 0 {
+	if offset > 0 && !keyset {
 		query += fmt.Sprintf(" OFFSET $%d", argCount)
 		args = append(args, offset)
 		argCount++
 	}
 
-	rows, err := r.DB.QueryContext(context.Background(), query, args...)
+	rows, err := db.QueryContext(context.Background(), query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -163,8 +292,15 @@ func (r *ProductRepository) FilterProducts(filters map[string]string) ([]models.
 			&product.Name,
 			&product.Category,
 			&product.Qty,
+			&product.ReservedQty,
 			&product.Price,
 			&product.SKU,
+			&product.UserID,
+			&product.Version,
+			pq.Array(&product.Options),
+			&product.Archived,
+			&product.AllowNegativeStock,
+			&product.LowStockThreshold,
 			&product.File.FileID,
 			&product.File.FileUri,
 			&product.File.FileThumbnailUri,
@@ -176,10 +312,142 @@ func (r *ProductRepository) FilterProducts(filters map[string]string) ([]models.
 		}
 		products = append(products, product)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.attachDetails(products); err != nil {
+		return nil, err
+	}
 
 	return products, nil
 }
 
+// CountProducts returns how many products match filters, ignoring paging
+// and sorting keys.
+func (r *ProductRepository) CountProducts(filters map[string]string) (int, error) {
+	whereClause, relevance, args := productFilterClause(filters)
+
+	db, done, err := r.searchQueryer(relevance != "")
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	var total int
+	query := "SELECT COUNT(*) FROM products JOIN files ON files.id = products.file_id" + whereClause
+	if err := db.QueryRow(query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count products: %v", err)
+	}
+
+	return total, nil
+}
+
+// searchQueryer returns where to run a product query. A search runs in a
+// transaction with the word similarity threshold set, which SET LOCAL keeps
+// from leaking to other queries on the pooled connection; done ends it.
+func (r *ProductRepository) searchQueryer(search bool) (db queryer, done func(), err error) {
+	if !search {
+		return r.DB, func() {}, nil
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("SET LOCAL pg_trgm.word_similarity_threshold = %v", SearchSimilarityThreshold)); err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to set search similarity threshold: %v", err)
+	}
+	return tx, func() { tx.Rollback() }, nil
+}
+
+// productFilterClause builds the WHERE clause shared by FilterProducts and
+// CountProducts. relevance is the ranking expression for a "q" search, empty
+// otherwise.
+func productFilterClause(filters map[string]string) (whereClause, relevance string, args []interface{}) {
+	args = []interface{}{}
+	argCount := 1
+
+	whereClause = " WHERE products.deleted_at IS NULL"
+
+	for key, value := range filters {
+		switch key {
+		case "product_id":
+			whereClause += fmt.Sprintf(" AND products.id = $%d", argCount)
+			args = append(args, value)
+			argCount++
+		case "category":
+			// Several categories arrive comma separated and match any of them
+			// or their subcategories
+			whereClause += " AND products.category IN (" + fmt.Sprintf(categorySubtree, fmt.Sprintf("$%d", argCount)) + ")"
+			args = append(args, pq.Array(strings.Split(value, ",")))
+			argCount++
+		case "min_price":
+			whereClause += fmt.Sprintf(" AND products.price >= $%d", argCount)
+			args = append(args, value)
+			argCount++
+		case "max_price":
+			whereClause += fmt.Sprintf(" AND products.price <= $%d", argCount)
+			args = append(args, value)
+			argCount++
+		case "in_stock":
+			// Stock held for unpaid purchases can't be bought
+			if value == "true" {
+				whereClause += " AND products.qty - products.reserved_qty > 0"
+			} else {
+				whereClause += " AND products.qty - products.reserved_qty <= 0"
+			}
+		case "low_stock":
+			// Available stock at or below a threshold the seller set
+			whereClause += " AND products.low_stock_threshold > 0 AND products.qty - products.reserved_qty <= products.low_stock_threshold"
+		case "created_after":
+			whereClause += fmt.Sprintf(" AND products.created_at >= $%d", argCount)
+			args = append(args, value)
+			argCount++
+		case "created_before":
+			whereClause += fmt.Sprintf(" AND products.created_at < $%d", argCount)
+			args = append(args, value)
+			argCount++
+		case "sku":
+			whereClause += fmt.Sprintf(" AND products.sku = $%d", argCount)
+			args = append(args, value)
+			argCount++
+		case "user_id":
+			whereClause += fmt.Sprintf(" AND products.user_id = $%d", argCount)
+			args = append(args, value)
+			argCount++
+		case "visible_to":
+			// Archived products are only listed to their owner
+			whereClause += fmt.Sprintf(" AND (NOT products.is_archived OR products.user_id = $%d)", argCount)
+			args = append(args, value)
+			argCount++
+		case "q":
+			tsQuery := prefixTsQuery(value)
+			if tsQuery == "" {
+				continue
+			}
+			// <% is word_similarity against the session threshold, and unlike
+			// the function it can use idx_products_name_trgm
+			whereClause += fmt.Sprintf(
+				" AND (products.search_vector @@ to_tsquery('simple', $%d) OR $%d <%% products.name)",
+				argCount, argCount+1,
+			)
+			relevance = fmt.Sprintf(
+				"ts_rank(products.search_vector, to_tsquery('simple', $%d)) + word_similarity($%d, products.name)",
+				argCount, argCount+1,
+			)
+			args = append(args, tsQuery, value)
+			argCount += 2
+		default:
+			// Ignore unknown filters
+			continue
+		}
+	}
+
+	return whereClause, relevance, args
+}
+
 func (r *ProductRepository) GetProductById(id int) (*models.Product, error) {
 	query := `
 		SELECT 
@@ -187,8 +455,15 @@ func (r *ProductRepository) GetProductById(id int) (*models.Product, error) {
 			products.name,
 			products.category,
 			products.qty,
+			products.reserved_qty,
 			products.price,
 			products.sku,
+			products.user_id,
+			products.version,
+			products.option_names,
+			products.is_archived,
+			products.allow_negative_stock,
+			products.low_stock_threshold,
 			files.id,
 			files.original_file_uri,
 			files.compressed_file_uri,
@@ -196,8 +471,8 @@ func (r *ProductRepository) GetProductById(id int) (*models.Product, error) {
 			products.updated_at
 		FROM products
 		JOIN files
-		ON files.id = products.id
-		WHERE products.id = $1
+		ON files.id = products.file_id
+		WHERE products.id = $1 AND products.deleted_at IS NULL
 	`
 
 	var product models.Product
@@ -206,8 +481,15 @@ func (r *ProductRepository) GetProductById(id int) (*models.Product, error) {
 		&product.Name,
 		&product.Category,
 		&product.Qty,
+		&product.ReservedQty,
 		&product.Price,
 		&product.SKU,
+		&product.UserID,
+		&product.Version,
+		pq.Array(&product.Options),
+		&product.Archived,
+		&product.AllowNegativeStock,
+		&product.LowStockThreshold,
 		&product.File.FileID,
 		&product.File.FileUri,
 		&product.File.FileThumbnailUri,
@@ -215,36 +497,197 @@ func (r *ProductRepository) GetProductById(id int) (*models.Product, error) {
 		&product.UpdatedAt,
 	)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &product, nil
+
+	products := []models.Product{product}
+	if err := r.attachDetails(products); err != nil {
+		return nil, err
+	}
+	return &products[0], nil
 }
 
-func (r *ProductRepository) UpdateProduct(id int, req dto.UpdateProductRequest) error {
-	query := `
-		UPDATE products
-		SET name = $1, category = $2, qty = $3, price = $4, sku = $5, fileId = $6
-		WHERE id = $7
-	`
+// UpdateProduct applies a partial update, writing only the columns whose
+// fields are set in req. When version is non-zero the update only happens if
+// the stored version still matches, otherwise ErrProductVersionMismatch is
+// returned. Every successful update bumps the version.
+func (r *ProductRepository) UpdateProduct(id int, userId uint, version int, req dto.UpdateProductRequest) error {
+	if err := r.checkOwner(id, userId); err != nil {
+		return err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateProduct(tx, id, userId, version, req); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit product: %v", err)
+	}
+
+	return nil
+}
+
+// updateProduct does the work of UpdateProduct inside tx, once ownership
+// has been checked.
+func updateProduct(tx *sql.Tx, id int, userId uint, version int, req dto.UpdateProductRequest) error {
+	if req.Qty != nil || req.Price != nil {
+		var hasVariants bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM product_variants WHERE product_id = $1)", id).Scan(&hasVariants)
+		if err != nil {
+			return fmt.Errorf("failed to check product variants: %v", err)
+		}
+		if hasVariants {
+			return ErrProductHasVariants
+		}
+	}
 
-	_, err := r.DB.Exec(
-		query,
-		req.Name,
-		req.Category,
-		req.Qty,
-		req.Price,
-		req.SKU,
-		req.FileID,
-		id,
+	setClauses := []string{}
+	args := []interface{}{}
+	argCount := 1
+
+	set := func(column string, value interface{}) {
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", column, argCount))
+		args = append(args, value)
+		argCount++
+	}
+
+	if req.Name != nil {
+		set("name", *req.Name)
+	}
+	if req.Category != nil {
+		set("category", *req.Category)
+	}
+	if req.Qty != nil {
+		set("qty", *req.Qty)
+	}
+	if req.Price != nil {
+		set("price", *req.Price)
+	}
+	if req.SKU != nil {
+		set("sku", *req.SKU)
+	}
+	if req.FileIDs != nil {
+		set("file_id", (*req.FileIDs)[0])
+	} else if req.FileID != nil {
+		set("file_id", *req.FileID)
+	}
+	if req.Archived != nil {
+		set("is_archived", *req.Archived)
+	}
+	if req.AllowNegativeStock != nil {
+		set("allow_negative_stock", *req.AllowNegativeStock)
+	}
+	if req.LowStockThreshold != nil {
+		set("low_stock_threshold", *req.LowStockThreshold)
+	}
+
+	if len(setClauses) == 0 {
+		return nil
+	}
+
+	// Setting qty is a correction in the ledger
+	if req.Qty != nil {
+		level, err := lockStock(tx, id, 0)
+		if err != nil {
+			return err
+		}
+		allowNegative := level.allowNegative
+		if req.AllowNegativeStock != nil {
+			allowNegative = *req.AllowNegativeStock
+		}
+		if err := CheckStockFloor(id, level.productQty, *req.Qty, level.productReserved, allowNegative); err != nil {
+			return err
+		}
+		if *req.Qty != level.productQty {
+			movement := models.StockMovement{
+				ProductID: id,
+				Reason:    models.StockMovementCorrection,
+				Delta:     *req.Qty - level.productQty,
+				QtyAfter:  *req.Qty,
+				Note:      "qty set",
+				UserID:    userId,
+			}
+			if err := insertStockMovement(tx, &movement); err != nil {
+				return err
+			}
+		}
+	}
+
+	query := fmt.Sprintf(
+		`UPDATE products SET %s, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $%d AND user_id = $%d AND ($%d = 0 OR version = $%d) AND deleted_at IS NULL`,
+		strings.Join(setClauses, ", "), argCount, argCount+1, argCount+2, argCount+2,
 	)
-	return err
+	args = append(args, id, userId, version)
+
+	result, err := tx.Exec(query, args...)
+	if isCheckViolation(err) {
+		// Only allow_negative_stock can be turned off while qty is negative
+		return fmt.Errorf("%w: restock the product before disallowing negative stock", ErrInsufficientStock)
+	}
+	if isUniqueViolation(err) {
+		return ErrProductSKUExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update product: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrProductVersionMismatch
+	}
+
+	if req.Qty != nil || req.LowStockThreshold != nil {
+		if err := checkLowStock(tx, id); err != nil {
+			return err
+		}
+	}
+
+	// A new gallery replaces the old one; a new fileId alone replaces just
+	// the primary image at position 0
+	if req.FileIDs != nil {
+		if err := replaceProductFiles(tx, id, *req.FileIDs); err != nil {
+			return err
+		}
+	} else if req.FileID != nil {
+		_, err := tx.Exec("UPDATE product_files SET file_id = $1 WHERE product_id = $2 AND position = 0", *req.FileID, id)
+		if err != nil {
+			return fmt.Errorf("failed to update product primary image: %v", err)
+		}
+	}
+
+	return nil
 }
 
-func (r *ProductRepository) DeleteProduct(id int) error {
-	query := "DELETE FROM products WHERE id = $1"
+// DeleteProduct soft deletes the product, honouring version the same way as
+// UpdateProduct. It disappears from the API but purchases keep referring to
+// it, and RestoreProduct brings it back until PurgeDeletedProducts removes
+// it for good.
+func (r *ProductRepository) DeleteProduct(id int, userId uint, version int) error {
+	if err := r.checkOwner(id, userId); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE products SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1 AND user_id = $2 AND ($3 = 0 OR version = $3) AND deleted_at IS NULL
+	`
 
-	result, err := db.DB.Exec(query, id)
+	result, err := r.DB.Exec(query, id, userId, version)
 	if err != nil {
 		return fmt.Errorf("failed to delete product: %v", err)
 	}
@@ -255,7 +698,158 @@ func (r *ProductRepository) DeleteProduct(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("product with id %d not found", id)
+		return ErrProductVersionMismatch
+	}
+
+	return nil
+}
+
+// RestoreProduct undoes DeleteProduct. Restoring a product that is not
+// deleted does nothing.
+func (r *ProductRepository) RestoreProduct(id int, userId uint) error {
+	var ownerId uint
+	err := r.DB.QueryRow("SELECT user_id FROM products WHERE id = $1", id).Scan(&ownerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check product owner: %v", err)
+	}
+	if ownerId != userId {
+		return ErrProductForbidden
+	}
+
+	query := `
+		UPDATE products SET deleted_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+	_, err = r.DB.Exec(query, id)
+	if isUniqueViolation(err) {
+		// Another product took the sku while this one was deleted
+		return ErrProductSKUExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore product: %v", err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedProducts permanently removes products deleted before the
+// given time, along with their gallery and variants. Products that were
+// ever purchased are kept so purchase history stays intact.
+func (r *ProductRepository) PurgeDeletedProducts(before time.Time) (int, error) {
+	query := `
+		DELETE FROM products
+		WHERE deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM purchase_items WHERE purchase_items.product_id = products.id)
+	`
+
+	result, err := r.DB.Exec(query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge products: %v", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+
+	return int(purged), nil
+}
+
+// replaceProductFiles makes fileIds, in order, the gallery of the product.
+func replaceProductFiles(tx *sql.Tx, productId int, fileIds []string) error {
+	if _, err := tx.Exec("DELETE FROM product_files WHERE product_id = $1", productId); err != nil {
+		return fmt.Errorf("failed to clear product images: %v", err)
+	}
+
+	query := `
+		INSERT INTO product_files (product_id, file_id, position)
+		SELECT $1, file_id, position - 1
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS gallery(file_id, position)
+	`
+	if _, err := tx.Exec(query, productId, pq.Array(fileIds)); err != nil {
+		return fmt.Errorf("failed to save product images: %v", err)
+	}
+
+	return nil
+}
+
+// attachDetails loads the galleries and variants of products.
+func (r *ProductRepository) attachDetails(products []models.Product) error {
+	if err := r.attachImages(products); err != nil {
+		return err
+	}
+	return r.attachVariants(products)
+}
+
+// attachImages loads the galleries of products in a single query.
+func (r *ProductRepository) attachImages(products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(products))
+	productIds := make([]int64, len(products))
+	for i, product := range products {
+		index[product.ID] = i
+		productIds[i] = int64(product.ID)
+	}
+
+	query := `
+		SELECT product_files.product_id, files.id, files.original_file_uri, files.compressed_file_uri
+		FROM product_files
+		JOIN files ON files.id = product_files.file_id
+		WHERE product_files.product_id = ANY($1)
+		ORDER BY product_files.product_id, product_files.position
+	`
+
+	rows, err := r.DB.Query(query, pq.Array(productIds))
+	if err != nil {
+		return fmt.Errorf("failed to load product images: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productId int
+		var file models.File
+		if err := rows.Scan(&productId, &file.FileID, &file.FileUri, &file.FileThumbnailUri); err != nil {
+			return fmt.Errorf("failed to scan product image: %v", err)
+		}
+		i := index[productId]
+		products[i].Images = append(products[i].Images, file)
+	}
+
+	return rows.Err()
+}
+
+// prefixTsQuery turns free text into a tsquery that requires every word to
+// match as a prefix, e.g. "iced te" becomes "iced:* & te:*". Only letters and
+// digits are kept so user input cannot inject tsquery operators.
+func prefixTsQuery(text string) string {
+	tokens := tsQueryTokenPattern.FindAllString(strings.ToLower(text), -1)
+	for i, token := range tokens {
+		tokens[i] = token + ":*"
+	}
+	return strings.Join(tokens, " & ")
+}
+
+// checkOwner returns ErrProductNotFound when the product does not exist or
+// is deleted and ErrProductForbidden when it is owned by someone other than
+// userId.
+func (r *ProductRepository) checkOwner(id int, userId uint) error {
+	var ownerId uint
+	err := r.DB.QueryRow("SELECT user_id FROM products WHERE id = $1 AND deleted_at IS NULL", id).Scan(&ownerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check product owner: %v", err)
+	}
+
+	if ownerId != userId {
+		return ErrProductForbidden
 	}
 
 	return nil
@@ -264,7 +858,7 @@ func (r *ProductRepository) DeleteProduct(id int) error {
 func (r *ProductRepository) IsFileExists(fileId string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM files WHERE id = $1)`
-	err := db.DB.QueryRow(query, fileId).Scan(&exists)
+	err := r.DB.QueryRow(query, fileId).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
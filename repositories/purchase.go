@@ -0,0 +1,679 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPurchaseNotFound     = errors.New("purchase not found")
+	ErrPurchaseAlreadyPaid  = errors.New("purchase is already paid")
+	ErrPaymentProofMismatch = errors.New("payment proof count does not match number of sellers")
+	ErrInsufficientStock    = errors.New("insufficient stock")
+	ErrPurchaseExpired      = errors.New("purchase reservation has expired")
+)
+
+type PurchaseRepository struct {
+	DB *sql.DB
+}
+
+func NewPurchaseRepository(db *sql.DB) *PurchaseRepository {
+	return &PurchaseRepository{DB: db}
+}
+
+// purchaseLine identifies what is bought: a product, or one of its
+// variants when variantId is not 0.
+type purchaseLine struct {
+	productId int
+	variantId int
+}
+
+// CreatePurchase records a pending purchase for the requested items and
+// reserves their stock until reservedUntil, so that it is no longer
+// available to other buyers while this one pays. The products and their
+// variants are locked for the duration of the transaction so that the stock
+// check and the reservation see a consistent quantity under concurrent
+// checkouts.
+func (r *PurchaseRepository) CreatePurchase(req dto.CreatePurchaseRequest, reservedUntil time.Time) (models.Purchase, error) {
+	// Merge repeated lines so each one is checked against its total qty
+	quantities := make(map[purchaseLine]int)
+	for _, item := range req.PurchasedItems {
+		productId, err := strconv.Atoi(item.ProductID)
+		if err != nil {
+			return models.Purchase{}, fmt.Errorf("%w: %s", ErrProductNotFound, item.ProductID)
+		}
+		line := purchaseLine{productId: productId}
+		if item.VariantID != "" {
+			if line.variantId, err = strconv.Atoi(item.VariantID); err != nil {
+				return models.Purchase{}, fmt.Errorf("%w: %s", ErrVariantNotFound, item.VariantID)
+			}
+		}
+		quantities[line] += item.Qty
+	}
+
+	lines := make([]purchaseLine, 0, len(quantities))
+	productIds := make([]int64, 0, len(quantities))
+	seenProducts := make(map[int]bool)
+	for line := range quantities {
+		lines = append(lines, line)
+		if !seenProducts[line.productId] {
+			seenProducts[line.productId] = true
+			productIds = append(productIds, int64(line.productId))
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].productId != lines[j].productId {
+			return lines[i].productId < lines[j].productId
+		}
+		return lines[i].variantId < lines[j].variantId
+	})
+	sort.Slice(productIds, func(i, j int) bool { return productIds[i] < productIds[j] })
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT
+			products.id,
+			products.name,
+			products.category,
+			products.qty,
+			products.reserved_qty,
+			products.price,
+			products.sku,
+			products.user_id,
+			products.option_names,
+			products.allow_negative_stock,
+			files.id,
+			files.original_file_uri,
+			files.compressed_file_uri,
+			products.created_at,
+			products.updated_at,
+			users.bank_account_name,
+			users.bank_account_holder,
+			users.bank_account_number
+		FROM products
+		JOIN files ON files.id = products.file_id
+		JOIN users ON users.id = products.user_id
+		WHERE products.id = ANY($1) AND products.deleted_at IS NULL AND NOT products.is_archived
+		ORDER BY products.id
+		FOR UPDATE OF products
+	`
+
+	rows, err := tx.Query(query, pq.Array(productIds))
+	if err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to load products: %v", err)
+	}
+
+	products := make(map[int]models.PurchaseItem, len(productIds))
+	for rows.Next() {
+		var item models.PurchaseItem
+		err := rows.Scan(
+			&item.Product.ID,
+			&item.Product.Name,
+			&item.Product.Category,
+			&item.Product.Qty,
+			&item.Product.ReservedQty,
+			&item.Product.Price,
+			&item.Product.SKU,
+			&item.Product.UserID,
+			pq.Array(&item.Product.Options),
+			&item.Product.AllowNegativeStock,
+			&item.Product.File.FileID,
+			&item.Product.File.FileUri,
+			&item.Product.File.FileThumbnailUri,
+			&item.Product.CreatedAt,
+			&item.Product.UpdatedAt,
+			&item.Seller.BankAccountName,
+			&item.Seller.BankAccountHolder,
+			&item.Seller.BankAccountNumber,
+		)
+		if err != nil {
+			rows.Close()
+			return models.Purchase{}, err
+		}
+		item.ProductID = item.Product.ID
+		item.SellerID = item.Product.UserID
+		item.Seller.ID = item.Product.UserID
+		item.Price = item.Product.Price
+		products[item.ProductID] = item
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.Purchase{}, err
+	}
+
+	variants, err := lockVariants(tx, productIds)
+	if err != nil {
+		return models.Purchase{}, err
+	}
+
+	items := make([]models.PurchaseItem, 0, len(lines))
+	for _, line := range lines {
+		item, ok := products[line.productId]
+		if !ok {
+			return models.Purchase{}, fmt.Errorf("%w: %d", ErrProductNotFound, line.productId)
+		}
+		item.Qty = quantities[line]
+
+		if line.variantId == 0 {
+			if len(variants[line.productId]) > 0 {
+				return models.Purchase{}, fmt.Errorf("%w: product %d", ErrVariantRequired, line.productId)
+			}
+		} else {
+			variant, ok := variants[line.productId][line.variantId]
+			if !ok {
+				return models.Purchase{}, fmt.Errorf("%w: product %d has no variant %d", ErrVariantNotFound, line.productId, line.variantId)
+			}
+			item.VariantID = variant.ID
+			item.Variant = variant
+			item.Price = variant.Price
+		}
+		items = append(items, item)
+	}
+
+	purchase := models.Purchase{
+		SenderName:          req.SenderName,
+		SenderContactType:   req.SenderContactType,
+		SenderContactDetail: req.SenderContactDetail,
+		Status:              models.PurchaseStatusPending,
+		ReservedUntil:       &reservedUntil,
+	}
+
+	// Only stock that is not already reserved can be bought. Several lines
+	// may draw on the same product through different variants.
+	productQty := make(map[int]int)
+	reservedQty := make(map[int]int)
+	for _, item := range items {
+		productQty[item.ProductID] += item.Qty
+		reservedQty[item.ProductID] = item.Product.ReservedQty
+	}
+	for i, item := range items {
+		purchase.TotalPrice += item.Price * float64(item.Qty)
+		if !item.Product.AllowNegativeStock {
+			if available := item.Variant.Qty - item.Variant.ReservedQty; item.VariantID != 0 && available < item.Qty {
+				return models.Purchase{}, fmt.Errorf("%w: variant %d of product %d has %d available", ErrInsufficientStock, item.VariantID, item.ProductID, available)
+			}
+			if available := item.Product.Qty - item.Product.ReservedQty; available < productQty[item.ProductID] {
+				return models.Purchase{}, fmt.Errorf("%w: product %d has %d available", ErrInsufficientStock, item.ProductID, available)
+			}
+		}
+
+		if err := reserveStock(tx, item.ProductID, item.VariantID, item.Qty); err != nil {
+			return models.Purchase{}, err
+		}
+		if item.VariantID != 0 {
+			items[i].Variant.ReservedQty += item.Qty
+		}
+	}
+
+	// Each product is reserved once for all of its lines
+	for product, qty := range productQty {
+		reservedQty[product] += qty
+	}
+	for i, item := range items {
+		items[i].Product.ReservedQty = reservedQty[item.ProductID]
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO purchases (sender_name, sender_contact_type, sender_contact_detail, status, total_price, reserved_until)
+		VALUES ($1, $2, $3, $4, $5, $6::timestamptz)
+		RETURNING id, public_id, created_at, updated_at
+	`,
+		purchase.SenderName,
+		purchase.SenderContactType,
+		purchase.SenderContactDetail,
+		purchase.Status,
+		purchase.TotalPrice,
+		reservedUntil,
+	).Scan(&purchase.ID, &purchase.PublicID, &purchase.CreatedAt, &purchase.UpdatedAt)
+	if err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to create purchase: %v", err)
+	}
+
+	for i := range items {
+		items[i].PurchaseID = purchase.ID
+		err := tx.QueryRow(`
+			INSERT INTO purchase_items (purchase_id, product_id, variant_id, seller_id, qty, price)
+			VALUES ($1, $2, NULLIF($3::int, 0), $4, $5, $6)
+			RETURNING id
+		`, purchase.ID, items[i].ProductID, items[i].VariantID, items[i].SellerID, items[i].Qty, items[i].Price).Scan(&items[i].ID)
+		if err != nil {
+			return models.Purchase{}, fmt.Errorf("failed to create purchase item: %v", err)
+		}
+	}
+	purchase.Items = items
+
+	if err := tx.Commit(); err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to commit purchase: %v", err)
+	}
+
+	return purchase, nil
+}
+
+// ConfirmPayment attaches one payment proof file per seller to the pending
+// purchase with the given public id, in the same seller order as the purchase's payment details, then
+// marks it paid, turns its reservation into a sale by taking the purchased
+// quantities out of stock, and records the sales. Everything happens in one
+// transaction. A purchase whose reservation has run out can no longer be
+// paid, even if the sweeper has not released it yet.
+func (r *PurchaseRepository) ConfirmPayment(publicId string, fileIds []string) (models.Purchase, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var purchase models.Purchase
+	var overdue bool
+	err = tx.QueryRow(`
+		SELECT
+			id,
+			public_id,
+			sender_name,
+			sender_contact_type,
+			sender_contact_detail,
+			status,
+			total_price,
+			reserved_until,
+			COALESCE(reserved_until <= CURRENT_TIMESTAMP, FALSE),
+			created_at,
+			updated_at
+		FROM purchases
+		WHERE public_id = $1
+		FOR UPDATE
+	`, publicId).Scan(
+		&purchase.ID,
+		&purchase.PublicID,
+		&purchase.SenderName,
+		&purchase.SenderContactType,
+		&purchase.SenderContactDetail,
+		&purchase.Status,
+		&purchase.TotalPrice,
+		&purchase.ReservedUntil,
+		&overdue,
+		&purchase.CreatedAt,
+		&purchase.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Purchase{}, ErrPurchaseNotFound
+	}
+	if err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to get purchase: %v", err)
+	}
+
+	if purchase.Status == models.PurchaseStatusPaid {
+		return models.Purchase{}, ErrPurchaseAlreadyPaid
+	}
+	if purchase.Status == models.PurchaseStatusExpired || overdue {
+		return models.Purchase{}, ErrPurchaseExpired
+	}
+
+	purchase.Items, err = getPurchaseItems(tx, purchase.ID)
+	if err != nil {
+		return models.Purchase{}, err
+	}
+
+	sellerIds := make([]uint, 0)
+	seen := make(map[uint]bool)
+	for _, item := range purchase.Items {
+		if !seen[item.SellerID] {
+			seen[item.SellerID] = true
+			sellerIds = append(sellerIds, item.SellerID)
+		}
+	}
+
+	if len(fileIds) != len(sellerIds) {
+		return models.Purchase{}, fmt.Errorf("%w: expected %d, got %d", ErrPaymentProofMismatch, len(sellerIds), len(fileIds))
+	}
+
+	var existing int
+	err = tx.QueryRow("SELECT COUNT(*) FROM files WHERE id = ANY($1::uuid[])", pq.Array(fileIds)).Scan(&existing)
+	if err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to validate fileIds: %v", err)
+	}
+	if existing != len(uniqueStrings(fileIds)) {
+		return models.Purchase{}, ErrFileNotFound
+	}
+
+	for i, sellerId := range sellerIds {
+		_, err := tx.Exec(
+			"INSERT INTO purchase_payments (purchase_id, seller_id, file_id) VALUES ($1, $2, $3)",
+			purchase.ID, sellerId, fileIds[i],
+		)
+		if err != nil {
+			return models.Purchase{}, fmt.Errorf("failed to record payment proof: %v", err)
+		}
+	}
+
+	// Purchases made before reservations existed hold no stock
+	released := make(map[int]int)
+	if purchase.ReservedUntil != nil {
+		if err := releaseReservation(tx, purchase.Items); err != nil {
+			return models.Purchase{}, err
+		}
+		for _, item := range purchase.Items {
+			released[item.ProductID] += item.Qty
+		}
+	}
+
+	for i, item := range purchase.Items {
+		movement := models.StockMovement{
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Reason:     models.StockMovementSale,
+			Delta:      -item.Qty,
+			PurchaseID: purchase.ID,
+		}
+		productQty, err := applyStockMovement(tx, &movement)
+		if err != nil {
+			return models.Purchase{}, err
+		}
+		purchase.Items[i].Product.Qty = productQty
+		if item.VariantID != 0 {
+			purchase.Items[i].Variant.Qty = movement.QtyAfter
+		}
+		purchase.Items[i].Product.ReservedQty -= released[item.ProductID]
+		if item.VariantID != 0 && purchase.ReservedUntil != nil {
+			purchase.Items[i].Variant.ReservedQty -= item.Qty
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO sales (purchase_id, product_id, qty, price) VALUES ($1, $2, $3, $4)",
+			purchase.ID, item.ProductID, item.Qty, item.Price,
+		)
+		if err != nil {
+			return models.Purchase{}, fmt.Errorf("failed to record sale: %v", err)
+		}
+	}
+
+	err = tx.QueryRow(
+		"UPDATE purchases SET status = $1, reserved_until = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING status, updated_at",
+		models.PurchaseStatusPaid, purchase.ID,
+	).Scan(&purchase.Status, &purchase.UpdatedAt)
+	if err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to update purchase: %v", err)
+	}
+	purchase.ReservedUntil = nil
+
+	if err := tx.Commit(); err != nil {
+		return models.Purchase{}, fmt.Errorf("failed to commit payment: %v", err)
+	}
+
+	return purchase, nil
+}
+
+// ExpireReservations releases the stock held by pending purchases whose
+// reservation ran out before now and marks them expired, returning how many
+// were expired. Each purchase is expired in its own transaction, skipping
+// any that a payment is confirming at the same time.
+func (r *PurchaseRepository) ExpireReservations(now time.Time) (int, error) {
+	rows, err := r.DB.Query(
+		"SELECT id FROM purchases WHERE status = $1 AND reserved_until <= $2::timestamptz ORDER BY id",
+		models.PurchaseStatusPending, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired reservations: %v", err)
+	}
+
+	var purchaseIds []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan purchase id: %v", err)
+		}
+		purchaseIds = append(purchaseIds, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range purchaseIds {
+		ok, err := r.expireReservation(id, now)
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// expireReservation expires one purchase if it is still pending and overdue
+// once locked, reporting whether it did.
+func (r *PurchaseRepository) expireReservation(purchaseId int, now time.Time) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		SELECT id FROM purchases
+		WHERE id = $1 AND status = $2 AND reserved_until <= $3::timestamptz
+		FOR UPDATE SKIP LOCKED
+	`, purchaseId, models.PurchaseStatusPending, now).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock purchase: %v", err)
+	}
+
+	items, err := getPurchaseItems(tx, purchaseId)
+	if err != nil {
+		return false, err
+	}
+	if err := releaseReservation(tx, items); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE purchases SET status = $1, reserved_until = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		models.PurchaseStatusExpired, purchaseId,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to expire purchase: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit expired purchase: %v", err)
+	}
+
+	return true, nil
+}
+
+// reserveStock adds qty, which is negative to release it, to the reserved
+// quantity of a product and of its variant when variantId is not 0. The
+// product is updated first, the same order purchases lock in. Its version is
+// left alone: nothing the seller edits changes, so a checkout must not fail
+// the seller's pending If-Match updates.
+func reserveStock(tx *sql.Tx, productId, variantId, qty int) error {
+	_, err := tx.Exec(
+		"UPDATE products SET reserved_qty = reserved_qty + $1 WHERE id = $2",
+		qty, productId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reserve stock: %v", err)
+	}
+
+	if variantId != 0 {
+		_, err := tx.Exec(
+			"UPDATE product_variants SET reserved_qty = reserved_qty + $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+			qty, variantId,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reserve variant stock: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// releaseReservation gives the stock a purchase's items hold back.
+func releaseReservation(tx *sql.Tx, items []models.PurchaseItem) error {
+	for _, item := range items {
+		if err := reserveStock(tx, item.ProductID, item.VariantID, -item.Qty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getPurchaseItems(tx *sql.Tx, purchaseId int) ([]models.PurchaseItem, error) {
+	query := `
+		SELECT
+			purchase_items.id,
+			purchase_items.qty,
+			purchase_items.price,
+			purchase_items.seller_id,
+			COALESCE(purchase_items.variant_id, 0),
+			COALESCE(product_variants.option_values, '{}'),
+			COALESCE(product_variants.sku, ''),
+			COALESCE(product_variants.price, 0),
+			COALESCE(product_variants.qty, 0),
+			COALESCE(product_variants.reserved_qty, 0),
+			COALESCE(variant_files.id::text, ''),
+			COALESCE(variant_files.original_file_uri, ''),
+			COALESCE(variant_files.compressed_file_uri, ''),
+			products.id,
+			products.name,
+			products.category,
+			products.qty,
+			products.reserved_qty,
+			products.price,
+			products.sku,
+			products.user_id,
+			products.option_names,
+			files.id,
+			files.original_file_uri,
+			files.compressed_file_uri,
+			products.created_at,
+			products.updated_at,
+			users.bank_account_name,
+			users.bank_account_holder,
+			users.bank_account_number
+		FROM purchase_items
+		JOIN products ON products.id = purchase_items.product_id
+		JOIN files ON files.id = products.file_id
+		LEFT JOIN product_variants ON product_variants.id = purchase_items.variant_id
+		LEFT JOIN files variant_files ON variant_files.id = product_variants.file_id
+		JOIN users ON users.id = purchase_items.seller_id
+		WHERE purchase_items.purchase_id = $1
+		ORDER BY purchase_items.id
+	`
+
+	rows, err := tx.Query(query, purchaseId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load purchase items: %v", err)
+	}
+	defer rows.Close()
+
+	var items []models.PurchaseItem
+	for rows.Next() {
+		var item models.PurchaseItem
+		err := rows.Scan(
+			&item.ID,
+			&item.Qty,
+			&item.Price,
+			&item.SellerID,
+			&item.VariantID,
+			pq.Array(&item.Variant.OptionValues),
+			&item.Variant.SKU,
+			&item.Variant.Price,
+			&item.Variant.Qty,
+			&item.Variant.ReservedQty,
+			&item.Variant.File.FileID,
+			&item.Variant.File.FileUri,
+			&item.Variant.File.FileThumbnailUri,
+			&item.Product.ID,
+			&item.Product.Name,
+			&item.Product.Category,
+			&item.Product.Qty,
+			&item.Product.ReservedQty,
+			&item.Product.Price,
+			&item.Product.SKU,
+			&item.Product.UserID,
+			pq.Array(&item.Product.Options),
+			&item.Product.File.FileID,
+			&item.Product.File.FileUri,
+			&item.Product.File.FileThumbnailUri,
+			&item.Product.CreatedAt,
+			&item.Product.UpdatedAt,
+			&item.Seller.BankAccountName,
+			&item.Seller.BankAccountHolder,
+			&item.Seller.BankAccountNumber,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.PurchaseID = purchaseId
+		item.ProductID = item.Product.ID
+		if item.VariantID != 0 {
+			item.Variant.ID = item.VariantID
+			item.Variant.ProductID = item.ProductID
+			item.Variant.FileID = item.Variant.File.FileID
+		}
+		item.Seller.ID = item.SellerID
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// lockVariants loads the variants of products keyed by product and variant
+// id, locking them against concurrent checkouts.
+func lockVariants(tx *sql.Tx, productIds []int64) (map[int]map[int]models.ProductVariant, error) {
+	rows, err := tx.Query(variantSelect+`
+		WHERE product_variants.product_id = ANY($1)
+		ORDER BY product_variants.id
+		FOR UPDATE OF product_variants
+	`, pq.Array(productIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load variants: %v", err)
+	}
+	defer rows.Close()
+
+	variants := make(map[int]map[int]models.ProductVariant)
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan variant: %v", err)
+		}
+		if variants[variant.ProductID] == nil {
+			variants[variant.ProductID] = make(map[int]models.ProductVariant)
+		}
+		variants[variant.ProductID][variant.ID] = variant
+	}
+
+	return variants, rows.Err()
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+)
+
+// stockLevel is the locked stock of a product and, for a product with
+// variants, of one of them.
+type stockLevel struct {
+	productQty      int
+	productReserved int
+	variantQty      int
+	variantReserved int
+	allowNegative   bool
+}
+
+// CheckStockFloor fails with ErrInsufficientStock when taking the product's,
+// or variant's, stock from qtyBefore down to qtyAfter would leave less than
+// zero, or less than the reserved units unpaid purchases are still owed.
+// Only products that allow negative stock may go lower.
+func CheckStockFloor(productId, qtyBefore, qtyAfter, reserved int, allowNegative bool) error {
+	if allowNegative || qtyAfter >= qtyBefore {
+		return nil
+	}
+	if qtyAfter < 0 {
+		return fmt.Errorf("%w: product %d has %d left", ErrInsufficientStock, productId, qtyBefore)
+	}
+	if qtyAfter < reserved {
+		return fmt.Errorf("%w: product %d has %d of its %d units reserved by unpaid purchases", ErrInsufficientStock, productId, reserved, qtyBefore)
+	}
+	return nil
+}
+
+// lockStock locks the product row, then the variant's when variantId is not
+// 0, in the same order as purchases do. A product that has variants only
+// moves stock through one of them.
+func lockStock(tx *sql.Tx, productId, variantId int) (stockLevel, error) {
+	var level stockLevel
+	var hasVariants bool
+	err := tx.QueryRow(`
+		SELECT qty, reserved_qty, allow_negative_stock, EXISTS(SELECT 1 FROM product_variants WHERE product_id = products.id)
+		FROM products
+		WHERE id = $1
+		FOR UPDATE
+	`, productId).Scan(&level.productQty, &level.productReserved, &level.allowNegative, &hasVariants)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stockLevel{}, ErrProductNotFound
+	}
+	if err != nil {
+		return stockLevel{}, fmt.Errorf("failed to lock product stock: %v", err)
+	}
+
+	if variantId == 0 {
+		if hasVariants {
+			return stockLevel{}, fmt.Errorf("%w: product %d", ErrVariantRequired, productId)
+		}
+		return level, nil
+	}
+
+	err = tx.QueryRow(
+		"SELECT qty, reserved_qty FROM product_variants WHERE id = $1 AND product_id = $2 FOR UPDATE",
+		variantId, productId,
+	).Scan(&level.variantQty, &level.variantReserved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stockLevel{}, ErrVariantNotFound
+	}
+	if err != nil {
+		return stockLevel{}, fmt.Errorf("failed to lock variant stock: %v", err)
+	}
+
+	return level, nil
+}
+
+// applyStockMovement moves the product's stock, and its variant's, by
+// movement.Delta and appends the movement to the ledger, returning the
+// product's new qty. Stock may only go below zero, or below what is
+// reserved, on products that allow it.
+// QtyAfter is filled in. A product that falls to its low stock threshold
+// raises an alert.
+func applyStockMovement(tx *sql.Tx, movement *models.StockMovement) (int, error) {
+	level, err := lockStock(tx, movement.ProductID, movement.VariantID)
+	if err != nil {
+		return 0, err
+	}
+
+	qtyBefore, reserved := level.productQty, level.productReserved
+	if movement.VariantID != 0 {
+		qtyBefore, reserved = level.variantQty, level.variantReserved
+	}
+	movement.QtyAfter = qtyBefore + movement.Delta
+	if err := CheckStockFloor(movement.ProductID, qtyBefore, movement.QtyAfter, reserved, level.allowNegative); err != nil {
+		return 0, err
+	}
+
+	if movement.VariantID != 0 {
+		_, err := tx.Exec(
+			"UPDATE product_variants SET qty = qty + $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+			movement.Delta, movement.VariantID,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update variant stock: %v", err)
+		}
+	}
+
+	_, err = tx.Exec(
+		"UPDATE products SET qty = qty + $1, version = version + 1 WHERE id = $2",
+		movement.Delta, movement.ProductID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update stock: %v", err)
+	}
+
+	if err := insertStockMovement(tx, movement); err != nil {
+		return 0, err
+	}
+	return level.productQty + movement.Delta, checkLowStock(tx, movement.ProductID)
+}
+
+// insertStockMovement appends a movement whose stock change has been, or is
+// about to be, written by the caller.
+func insertStockMovement(tx *sql.Tx, movement *models.StockMovement) error {
+	err := tx.QueryRow(`
+		INSERT INTO stock_movements (product_id, variant_id, reason, delta, qty_after, note, purchase_id, user_id)
+		VALUES ($1, NULLIF($2::int, 0), $3, $4, $5, $6, NULLIF($7::int, 0), NULLIF($8::int, 0))
+		RETURNING id, created_at
+	`,
+		movement.ProductID,
+		movement.VariantID,
+		movement.Reason,
+		movement.Delta,
+		movement.QtyAfter,
+		movement.Note,
+		movement.PurchaseID,
+		movement.UserID,
+	).Scan(&movement.ID, &movement.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record stock movement: %v", err)
+	}
+	return nil
+}
+
+// AdjustStock records a manual stock movement on the product, or on one of
+// its variants, and returns it.
+func (r *ProductRepository) AdjustStock(productId int, userId uint, req dto.StockAdjustmentRequest) (models.StockMovement, error) {
+	if err := r.checkOwner(productId, userId); err != nil {
+		return models.StockMovement{}, err
+	}
+
+	movement := models.StockMovement{
+		ProductID: productId,
+		Reason:    req.Reason,
+		Delta:     req.Delta,
+		Note:      req.Note,
+		UserID:    userId,
+	}
+	if req.VariantID != "" {
+		variantId, err := strconv.Atoi(req.VariantID)
+		if err != nil {
+			return models.StockMovement{}, ErrVariantNotFound
+		}
+		movement.VariantID = variantId
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return models.StockMovement{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := applyStockMovement(tx, &movement); err != nil {
+		return models.StockMovement{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.StockMovement{}, fmt.Errorf("failed to commit stock movement: %v", err)
+	}
+
+	return movement, nil
+}
+
+// StockHistory returns a page of the product's ledger, newest first, along
+// with the total number of movements.
+func (r *ProductRepository) StockHistory(productId int, userId uint, limit, offset int) ([]models.StockMovement, int, error) {
+	if err := r.checkOwner(productId, userId); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM stock_movements WHERE product_id = $1", productId).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count stock movements: %v", err)
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT
+			stock_movements.id,
+			stock_movements.product_id,
+			COALESCE(stock_movements.variant_id, 0),
+			stock_movements.reason,
+			stock_movements.delta,
+			stock_movements.qty_after,
+			stock_movements.note,
+			COALESCE(stock_movements.purchase_id, 0),
+			COALESCE(purchases.public_id::text, ''),
+			COALESCE(stock_movements.user_id, 0),
+			stock_movements.created_at
+		FROM stock_movements
+		LEFT JOIN purchases ON purchases.id = stock_movements.purchase_id
+		WHERE stock_movements.product_id = $1
+		ORDER BY stock_movements.id DESC
+		LIMIT $2 OFFSET $3
+	`, productId, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load stock movements: %v", err)
+	}
+	defer rows.Close()
+
+	var movements []models.StockMovement
+	for rows.Next() {
+		var movement models.StockMovement
+		err := rows.Scan(
+			&movement.ID,
+			&movement.ProductID,
+			&movement.VariantID,
+			&movement.Reason,
+			&movement.Delta,
+			&movement.QtyAfter,
+			&movement.Note,
+			&movement.PurchaseID,
+			&movement.PurchasePublicID,
+			&movement.UserID,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan stock movement: %v", err)
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, total, rows.Err()
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"tutuplapak/models"
+)
+
+type StockAlertRepository struct {
+	DB *sql.DB
+}
+
+func NewStockAlertRepository(db *sql.DB) *StockAlertRepository {
+	return &StockAlertRepository{DB: db}
+}
+
+// PendingStockAlerts returns up to limit alerts that have not been delivered
+// yet, oldest first, together with the product and seller they are about.
+// Alerts about deleted products are held back; restoring the product lets
+// them through again.
+func (r *StockAlertRepository) PendingStockAlerts(limit int) ([]models.StockAlert, error) {
+	query := `
+		SELECT
+			stock_alerts.id,
+			stock_alerts.product_id,
+			stock_alerts.user_id,
+			stock_alerts.qty,
+			stock_alerts.threshold,
+			stock_alerts.created_at,
+			products.name,
+			products.sku,
+			COALESCE(users.email, ''),
+			COALESCE(users.phone, '')
+		FROM stock_alerts
+		JOIN products ON products.id = stock_alerts.product_id
+		JOIN users ON users.id = stock_alerts.user_id
+		WHERE stock_alerts.delivered_at IS NULL AND products.deleted_at IS NULL
+		ORDER BY stock_alerts.id
+		LIMIT $1
+	`
+
+	rows, err := r.DB.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stock alerts: %v", err)
+	}
+	defer rows.Close()
+
+	var alerts []models.StockAlert
+	for rows.Next() {
+		var alert models.StockAlert
+		err := rows.Scan(
+			&alert.ID,
+			&alert.ProductID,
+			&alert.UserID,
+			&alert.Qty,
+			&alert.Threshold,
+			&alert.CreatedAt,
+			&alert.Product.Name,
+			&alert.Product.SKU,
+			&alert.Seller.Email,
+			&alert.Seller.Phone,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock alert: %v", err)
+		}
+		alert.Product.ID = alert.ProductID
+		alert.Product.UserID = alert.UserID
+		alert.Seller.ID = alert.UserID
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+// MarkStockAlertDelivered records that a notifier has sent the alert.
+func (r *StockAlertRepository) MarkStockAlertDelivered(id int) error {
+	_, err := r.DB.Exec("UPDATE stock_alerts SET delivered_at = CURRENT_TIMESTAMP WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to mark stock alert delivered: %v", err)
+	}
+	return nil
+}
+
+// checkLowStock compares the product's qty with its low stock threshold
+// after a change to either. Falling to the threshold raises an alert unless
+// one is already outstanding; climbing back above it clears the outstanding
+// one, so the next fall alerts again.
+func checkLowStock(tx *sql.Tx, productId int) error {
+	alert := models.StockAlert{ProductID: productId}
+	var low bool
+	err := tx.QueryRow(`
+		UPDATE products
+		SET low_stock_alerted = (low_stock_threshold > 0 AND qty <= low_stock_threshold)
+		WHERE id = $1 AND low_stock_alerted <> (low_stock_threshold > 0 AND qty <= low_stock_threshold)
+		RETURNING low_stock_alerted, user_id, qty, low_stock_threshold
+	`, productId).Scan(&low, &alert.UserID, &alert.Qty, &alert.Threshold)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check low stock: %v", err)
+	}
+	if !low {
+		return nil
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO stock_alerts (product_id, user_id, qty, threshold) VALUES ($1, $2, $3, $4)",
+		alert.ProductID, alert.UserID, alert.Qty, alert.Threshold,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to raise stock alert: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+)
+
+// The store interfaces describe what the handlers need from persistence.
+// The Postgres repositories in this package implement them, and so does the
+// in-memory store in repositories/memory that handler tests run against.
+
+type ProductStore interface {
+	CreateProduct(userId uint, req dto.CreateProductRequest) (models.Product, error)
+	FilterProducts(filters map[string]string) ([]models.Product, error)
+	CountProducts(filters map[string]string) (int, error)
+	GetProductById(id int) (*models.Product, error)
+	UpdateProduct(id int, userId uint, version int, req dto.UpdateProductRequest) error
+	DeleteProduct(id int, userId uint, version int) error
+	RestoreProduct(id int, userId uint) error
+	PurgeDeletedProducts(before time.Time) (int, error)
+	CreateVariant(productId int, userId uint, req dto.CreateVariantRequest) (models.ProductVariant, error)
+	UpdateVariant(productId, variantId int, userId uint, req dto.UpdateVariantRequest) (models.ProductVariant, error)
+	DeleteVariant(productId, variantId int, userId uint) error
+	AdjustStock(productId int, userId uint, req dto.StockAdjustmentRequest) (models.StockMovement, error)
+	StockHistory(productId int, userId uint, limit, offset int) ([]models.StockMovement, int, error)
+	ImportProducts(userId uint, rows []ImportRow, dryRun bool) ([]ImportResult, error)
+	IsFileExists(fileId string) (bool, error)
+}
+
+type FileStore interface {
+	CreateFile(fileUri, fileThumbnailUri string) (models.File, error)
+	IsFileExists(fileId string) (bool, error)
+}
+
+type UserStore interface {
+	CreateUser(email, phone, passwordHash string) (models.User, error)
+	GetUserById(id uint) (models.User, error)
+	GetUserByEmail(email string) (models.User, error)
+	GetUserByPhone(phone string) (models.User, error)
+	UpdateProfile(id uint, req dto.UpdateUserRequest) (models.User, error)
+	LinkEmail(id uint, email string) (models.User, error)
+	LinkPhone(id uint, phone string) (models.User, error)
+}
+
+type PurchaseStore interface {
+	CreatePurchase(req dto.CreatePurchaseRequest, reservedUntil time.Time) (models.Purchase, error)
+	ConfirmPayment(publicId string, fileIds []string) (models.Purchase, error)
+	ExpireReservations(now time.Time) (int, error)
+}
+
+type StockAlertStore interface {
+	PendingStockAlerts(limit int) ([]models.StockAlert, error)
+	MarkStockAlertDelivered(id int) error
+}
+
+type CategoryStore interface {
+	ListCategories(includeInactive bool) ([]models.ProductCategory, error)
+	CreateCategory(categoryType string, parentId uint) (models.ProductCategory, error)
+	UpdateCategory(id uint, req dto.UpdateCategoryRequest) (models.ProductCategory, error)
+	DeleteCategory(id uint) error
+}
+
+var (
+	_ ProductStore    = (*ProductRepository)(nil)
+	_ FileStore       = (*FileRepository)(nil)
+	_ UserStore       = (*UserRepository)(nil)
+	_ PurchaseStore   = (*PurchaseRepository)(nil)
+	_ CategoryStore   = (*CategoryRepository)(nil)
+	_ StockAlertStore = (*StockAlertRepository)(nil)
+)
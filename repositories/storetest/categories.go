@@ -0,0 +1,170 @@
+package storetest
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func testCategories(t *testing.T, s Stores) {
+	seeded, err := s.Categories.ListCategories(false)
+	if err != nil {
+		t.Fatalf("ListCategories: %v", err)
+	}
+	if len(seeded) != 5 || seeded[0].Type != "Food" || !seeded[0].IsActive {
+		t.Fatalf("ListCategories on a fresh database: got %+v, want the five seeded categories", seeded)
+	}
+
+	toys, err := s.Categories.CreateCategory("Toys", 0)
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	if toys.ID == 0 || toys.Type != "Toys" || !toys.IsActive {
+		t.Fatalf("CreateCategory returned %+v", toys)
+	}
+	if _, err := s.Categories.CreateCategory("Toys", 0); !errors.Is(err, repositories.ErrCategoryAlreadyExists) {
+		t.Fatalf("CreateCategory with duplicate type: got %v, want ErrCategoryAlreadyExists", err)
+	}
+
+	seller, file := mustCreateSeller(t, s, "seller@example.com")
+	product := mustCreateProduct(t, s, seller.ID, newProductRequest("Yo-yo", "Toys", "T-1", 1, 1000, file.FileID))
+
+	renamed, err := s.Categories.UpdateCategory(toys.ID, dto.UpdateCategoryRequest{Type: stringPtr("Games")})
+	if err != nil {
+		t.Fatalf("UpdateCategory rename: %v", err)
+	}
+	if renamed.Type != "Games" || !renamed.IsActive {
+		t.Fatalf("UpdateCategory rename returned %+v", renamed)
+	}
+	got, err := s.Products.GetProductById(product.ID)
+	if err != nil || got.Category != "Games" {
+		t.Fatalf("product after category rename: got %+v, %v, want category Games", got, err)
+	}
+	if got.Version != product.Version+1 || got.UpdatedAt.Before(product.UpdatedAt) {
+		t.Fatalf("product after category rename: got version %d updated at %v, want version %d updated no earlier than %v", got.Version, got.UpdatedAt, product.Version+1, product.UpdatedAt)
+	}
+
+	if _, err := s.Categories.UpdateCategory(toys.ID, dto.UpdateCategoryRequest{Type: stringPtr("Food")}); !errors.Is(err, repositories.ErrCategoryAlreadyExists) {
+		t.Fatalf("UpdateCategory to a taken type: got %v, want ErrCategoryAlreadyExists", err)
+	}
+	if _, err := s.Categories.UpdateCategory(toys.ID+1000, dto.UpdateCategoryRequest{Type: stringPtr("Nope")}); !errors.Is(err, repositories.ErrCategoryNotFound) {
+		t.Fatalf("UpdateCategory for unknown id: got %v, want ErrCategoryNotFound", err)
+	}
+
+	inactive := false
+	deactivated, err := s.Categories.UpdateCategory(toys.ID, dto.UpdateCategoryRequest{IsActive: &inactive})
+	if err != nil || deactivated.IsActive || deactivated.Type != "Games" {
+		t.Fatalf("UpdateCategory deactivate: got %+v, %v", deactivated, err)
+	}
+
+	active, _ := s.Categories.ListCategories(false)
+	all, _ := s.Categories.ListCategories(true)
+	if len(active) != 5 || len(all) != 6 {
+		t.Fatalf("ListCategories after deactivating: got %d active and %d total, want 5 and 6", len(active), len(all))
+	}
+}
+
+func testCategoryTree(t *testing.T, s Stores) {
+	furniture := categoryByType(t, s, "Furniture")
+	chairs := mustCreateCategory(t, s, "Chairs", furniture.ID)
+	office := mustCreateCategory(t, s, "Office Chairs", chairs.ID)
+	if office.ParentID != chairs.ID {
+		t.Fatalf("CreateCategory with parent returned %+v", office)
+	}
+	if _, err := s.Categories.CreateCategory("Stools", furniture.ID+1000); !errors.Is(err, repositories.ErrCategoryParentNotFound) {
+		t.Fatalf("CreateCategory under unknown parent: got %v, want ErrCategoryParentNotFound", err)
+	}
+
+	seller, file := mustCreateSeller(t, s, "seller@example.com")
+	mustCreateProduct(t, s, seller.ID, newProductRequest("Wardrobe", "Furniture", "F-1", 1, 1000, file.FileID))
+	mustCreateProduct(t, s, seller.ID, newProductRequest("Armchair", "Chairs", "F-2", 1, 1000, file.FileID))
+	mustCreateProduct(t, s, seller.ID, newProductRequest("Desk Chair", "Office Chairs", "F-3", 1, 1000, file.FileID))
+	mustCreateProduct(t, s, seller.ID, newProductRequest("Hammer", "Tools", "T-1", 1, 1000, file.FileID))
+
+	if products := mustFilter(t, s, map[string]string{"category": "Furniture"}); len(products) != 3 {
+		t.Fatalf("filter by category with descendants: got %d products, want 3", len(products))
+	}
+	if products := mustFilter(t, s, map[string]string{"category": "Chairs,Tools"}); len(products) != 3 {
+		t.Fatalf("filter by several categories with descendants: got %d products, want 3", len(products))
+	}
+	if total, err := s.Products.CountProducts(map[string]string{"category": "Chairs"}); err != nil || total != 2 {
+		t.Fatalf("CountProducts by category with descendants: got %d, %v, want 2", total, err)
+	}
+
+	ownId := strconv.FormatUint(uint64(chairs.ID), 10)
+	if _, err := s.Categories.UpdateCategory(chairs.ID, dto.UpdateCategoryRequest{ParentID: &ownId}); !errors.Is(err, repositories.ErrCategoryCycle) {
+		t.Fatalf("UpdateCategory under itself: got %v, want ErrCategoryCycle", err)
+	}
+	descendantId := strconv.FormatUint(uint64(office.ID), 10)
+	if _, err := s.Categories.UpdateCategory(chairs.ID, dto.UpdateCategoryRequest{ParentID: &descendantId}); !errors.Is(err, repositories.ErrCategoryCycle) {
+		t.Fatalf("UpdateCategory under a descendant: got %v, want ErrCategoryCycle", err)
+	}
+
+	moved, err := s.Categories.UpdateCategory(office.ID, dto.UpdateCategoryRequest{ParentID: stringPtr("")})
+	if err != nil || moved.ParentID != 0 {
+		t.Fatalf("UpdateCategory to top level: got %+v, %v", moved, err)
+	}
+	if products := mustFilter(t, s, map[string]string{"category": "Furniture"}); len(products) != 2 {
+		t.Fatalf("filter by category after moving a subcategory out: got %d products, want 2", len(products))
+	}
+
+	if err := s.Categories.DeleteCategory(chairs.ID); !errors.Is(err, repositories.ErrCategoryInUse) {
+		t.Fatalf("DeleteCategory with products: got %v, want ErrCategoryInUse", err)
+	}
+	if err := s.Categories.DeleteCategory(furniture.ID); !errors.Is(err, repositories.ErrCategoryInUse) {
+		t.Fatalf("DeleteCategory with subcategories: got %v, want ErrCategoryInUse", err)
+	}
+	empty := mustCreateCategory(t, s, "Stools", furniture.ID)
+	if err := s.Categories.DeleteCategory(empty.ID); err != nil {
+		t.Fatalf("DeleteCategory: %v", err)
+	}
+	if err := s.Categories.DeleteCategory(empty.ID); !errors.Is(err, repositories.ErrCategoryNotFound) {
+		t.Fatalf("DeleteCategory twice: got %v, want ErrCategoryNotFound", err)
+	}
+
+	// Deleted products hold on to their category until they are purged
+	benches := mustCreateCategory(t, s, "Benches", furniture.ID)
+	bench := mustCreateProduct(t, s, seller.ID, newProductRequest("Bench", "Benches", "F-4", 1, 1000, file.FileID))
+	if err := s.Products.DeleteProduct(bench.ID, seller.ID, 0); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	if err := s.Categories.DeleteCategory(benches.ID); !errors.Is(err, repositories.ErrCategoryHasDeletedProducts) {
+		t.Fatalf("DeleteCategory with only deleted products: got %v, want ErrCategoryHasDeletedProducts", err)
+	}
+	if _, err := s.Products.PurgeDeletedProducts(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PurgeDeletedProducts: %v", err)
+	}
+	if err := s.Categories.DeleteCategory(benches.ID); err != nil {
+		t.Fatalf("DeleteCategory after purging its deleted products: %v", err)
+	}
+}
+
+func mustCreateCategory(t *testing.T, s Stores, categoryType string, parentId uint) models.ProductCategory {
+	t.Helper()
+
+	category, err := s.Categories.CreateCategory(categoryType, parentId)
+	if err != nil {
+		t.Fatalf("CreateCategory %s: %v", categoryType, err)
+	}
+	return category
+}
+
+func categoryByType(t *testing.T, s Stores, categoryType string) models.ProductCategory {
+	t.Helper()
+
+	categories, err := s.Categories.ListCategories(true)
+	if err != nil {
+		t.Fatalf("ListCategories: %v", err)
+	}
+	for _, category := range categories {
+		if category.Type == categoryType {
+			return category
+		}
+	}
+	t.Fatalf("category %s not found", categoryType)
+	return models.ProductCategory{}
+}
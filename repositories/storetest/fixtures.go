@@ -0,0 +1,100 @@
+package storetest
+
+import (
+	"fmt"
+	"testing"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+)
+
+// mustCreateSeller creates a user and one uploaded file, the fixture most
+// tests start from before creating products.
+func mustCreateSeller(t *testing.T, s Stores, email string) (models.User, models.File) {
+	t.Helper()
+
+	return mustCreateUser(t, s, email), mustCreateFile(t, s)
+}
+
+func mustCreateUser(t *testing.T, s Stores, email string) models.User {
+	t.Helper()
+
+	user, err := s.Users.CreateUser(email, "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+func mustCreateFile(t *testing.T, s Stores) models.File {
+	t.Helper()
+
+	file, err := s.Files.CreateFile("http://files/image.png", "http://files/image_thumbnail.jpg")
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	return file
+}
+
+func mustCreateProduct(t *testing.T, s Stores, userId uint, req dto.CreateProductRequest) models.Product {
+	t.Helper()
+
+	product, err := s.Products.CreateProduct(userId, req)
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	return product
+}
+
+func mustFilter(t *testing.T, s Stores, filters map[string]string) []models.Product {
+	t.Helper()
+
+	products, err := s.Products.FilterProducts(filters)
+	if err != nil {
+		t.Fatalf("FilterProducts(%v): %v", filters, err)
+	}
+	return products
+}
+
+func newProductRequest(name, category, sku string, qty, price int, fileId string) dto.CreateProductRequest {
+	return dto.CreateProductRequest{
+		Name:     name,
+		Category: category,
+		Qty:      qty,
+		Price:    price,
+		SKU:      sku,
+		FileID:   fileId,
+	}
+}
+
+func newPurchaseRequest(quantities map[int]int) dto.CreatePurchaseRequest {
+	req := dto.CreatePurchaseRequest{
+		SenderName:          "Buyer",
+		SenderContactType:   "email",
+		SenderContactDetail: "buyer@example.com",
+	}
+	for productId, qty := range quantities {
+		req.PurchasedItems = append(req.PurchasedItems, dto.PurchasedItemRequest{
+			ProductID: fmt.Sprint(productId),
+			Qty:       qty,
+		})
+	}
+	return req
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func assertFileExists(t *testing.T, store interface {
+	IsFileExists(fileId string) (bool, error)
+}, fileId string, want bool) {
+	t.Helper()
+
+	exists, err := store.IsFileExists(fileId)
+	if err != nil {
+		t.Fatalf("IsFileExists(%s): %v", fileId, err)
+	}
+	if exists != want {
+		t.Fatalf("IsFileExists(%s) = %v, want %v", fileId, exists, want)
+	}
+}
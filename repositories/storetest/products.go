@@ -0,0 +1,500 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func testProducts(t *testing.T, s Stores) {
+	owner, file := mustCreateSeller(t, s, "owner@example.com")
+	stranger := mustCreateUser(t, s, "stranger@example.com")
+
+	product, err := s.Products.CreateProduct(owner.ID, newProductRequest("Coffee Beans", "Beverage", "SKU-1", 10, 25000, file.FileID))
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	if product.ID == 0 || product.UserID != owner.ID || product.Qty != 10 || product.Price != 25000 || product.File.FileUri != file.FileUri {
+		t.Fatalf("CreateProduct returned %+v", product)
+	}
+
+	got, err := s.Products.GetProductById(product.ID)
+	if err != nil {
+		t.Fatalf("GetProductById: %v", err)
+	}
+	if got.Name != "Coffee Beans" || got.File.FileID != file.FileID {
+		t.Fatalf("GetProductById returned %+v", got)
+	}
+	if _, err := s.Products.GetProductById(product.ID + 1000); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("GetProductById for unknown id: got %v, want ErrProductNotFound", err)
+	}
+
+	name, qty, price := "Coffee Grounds", 5, 30000
+	update := dto.UpdateProductRequest{Name: &name, Qty: &qty, Price: &price}
+	if err := s.Products.UpdateProduct(product.ID, stranger.ID, 0, update); !errors.Is(err, repositories.ErrProductForbidden) {
+		t.Fatalf("UpdateProduct by non-owner: got %v, want ErrProductForbidden", err)
+	}
+	if err := s.Products.UpdateProduct(product.ID+1000, owner.ID, 0, update); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("UpdateProduct for unknown id: got %v, want ErrProductNotFound", err)
+	}
+	if err := s.Products.UpdateProduct(product.ID, owner.ID, product.Version+1, update); !errors.Is(err, repositories.ErrProductVersionMismatch) {
+		t.Fatalf("UpdateProduct with stale version: got %v, want ErrProductVersionMismatch", err)
+	}
+	if err := s.Products.UpdateProduct(product.ID, owner.ID, product.Version, update); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	got, err = s.Products.GetProductById(product.ID)
+	if err != nil || got.Name != "Coffee Grounds" || got.Qty != 5 || got.Price != 30000 || got.SKU != "SKU-1" || got.Category != "Beverage" {
+		t.Fatalf("GetProductById after update: got %+v, %v", got, err)
+	}
+	if got.Version != product.Version+1 {
+		t.Fatalf("version after update: got %d, want %d", got.Version, product.Version+1)
+	}
+
+	if err := s.Products.DeleteProduct(product.ID, stranger.ID, 0); !errors.Is(err, repositories.ErrProductForbidden) {
+		t.Fatalf("DeleteProduct by non-owner: got %v, want ErrProductForbidden", err)
+	}
+	if err := s.Products.DeleteProduct(product.ID, owner.ID, product.Version); !errors.Is(err, repositories.ErrProductVersionMismatch) {
+		t.Fatalf("DeleteProduct with stale version: got %v, want ErrProductVersionMismatch", err)
+	}
+	if err := s.Products.DeleteProduct(product.ID, owner.ID, got.Version); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	if err := s.Products.DeleteProduct(product.ID, owner.ID, 0); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("DeleteProduct twice: got %v, want ErrProductNotFound", err)
+	}
+}
+
+func testProductSKUs(t *testing.T, s Stores) {
+	owner, file := mustCreateSeller(t, s, "owner@example.com")
+	stranger := mustCreateUser(t, s, "stranger@example.com")
+
+	beans := mustCreateProduct(t, s, owner.ID, newProductRequest("Coffee Beans", "Beverage", "SKU-1", 10, 25000, file.FileID))
+	grounds := mustCreateProduct(t, s, owner.ID, newProductRequest("Coffee Grounds", "Beverage", "SKU-2", 10, 25000, file.FileID))
+
+	if _, err := s.Products.CreateProduct(owner.ID, newProductRequest("Coffee Pods", "Beverage", "SKU-1", 10, 25000, file.FileID)); !errors.Is(err, repositories.ErrProductSKUExists) {
+		t.Fatalf("CreateProduct with a taken sku: got %v, want ErrProductSKUExists", err)
+	}
+	mustCreateProduct(t, s, stranger.ID, newProductRequest("Coffee Beans", "Beverage", "SKU-1", 10, 25000, file.FileID))
+
+	sku := "SKU-1"
+	if err := s.Products.UpdateProduct(grounds.ID, owner.ID, 0, dto.UpdateProductRequest{SKU: &sku}); !errors.Is(err, repositories.ErrProductSKUExists) {
+		t.Fatalf("UpdateProduct to a taken sku: got %v, want ErrProductSKUExists", err)
+	}
+	if err := s.Products.UpdateProduct(beans.ID, owner.ID, 0, dto.UpdateProductRequest{SKU: &sku}); err != nil {
+		t.Fatalf("UpdateProduct keeping its own sku: %v", err)
+	}
+
+	// A deleted product frees its sku until it is restored
+	if err := s.Products.DeleteProduct(beans.ID, owner.ID, 0); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	mustCreateProduct(t, s, owner.ID, newProductRequest("Coffee Pods", "Beverage", "SKU-1", 10, 25000, file.FileID))
+	if err := s.Products.RestoreProduct(beans.ID, owner.ID); !errors.Is(err, repositories.ErrProductSKUExists) {
+		t.Fatalf("RestoreProduct with its sku taken: got %v, want ErrProductSKUExists", err)
+	}
+	if got := mustFilter(t, s, map[string]string{"user_id": fmt.Sprint(owner.ID), "sku": "SKU-1"}); len(got) != 1 {
+		t.Fatalf("lookup by sku: got %d products, want 1", len(got))
+	}
+}
+
+func testProductImport(t *testing.T, s Stores) {
+	owner, file := mustCreateSeller(t, s, "owner@example.com")
+
+	beans := mustCreateProduct(t, s, owner.ID, newProductRequest("Coffee Beans", "Beverage", "SKU-1", 10, 25000, file.FileID))
+	name, qty, price := "Dark Coffee Beans", 20, 27000
+	rows := []repositories.ImportRow{
+		{
+			Create: newProductRequest(name, "Beverage", "SKU-1", qty, price, file.FileID),
+			Update: dto.UpdateProductRequest{Name: &name, Qty: &qty, Price: &price},
+		},
+		{Create: newProductRequest("Coffee Grounds", "Beverage", "SKU-2", 5, 25000, file.FileID)},
+	}
+
+	// A dry run reports what would happen without writing it
+	results, err := s.Products.ImportProducts(owner.ID, rows, true)
+	if err != nil {
+		t.Fatalf("ImportProducts dry run: %v", err)
+	}
+	if len(results) != 2 || results[0].Err != nil || results[0].Created || results[0].ProductID != beans.ID ||
+		results[1].Err != nil || !results[1].Created {
+		t.Fatalf("ImportProducts dry run: got %+v", results)
+	}
+	assertImportWritten(t, s, owner.ID, beans.ID, false)
+
+	// One failing row keeps every row from being written
+	invalid := newProductRequest("Coffee Beans", "Beverage", "SKU-1", 0, 0, file.FileID)
+	invalid.Options = []string{"roast"}
+	invalid.Variants = []dto.CreateVariantRequest{{Options: map[string]string{"roast": "dark"}, SKU: "SKU-1-D", Price: 27000, Qty: 5}}
+	results, err = s.Products.ImportProducts(owner.ID, []repositories.ImportRow{rows[1], {Create: invalid}}, false)
+	if err != nil {
+		t.Fatalf("ImportProducts with a failing row: %v", err)
+	}
+	if results[0].Err != nil || !errors.Is(results[1].Err, repositories.ErrImportVariants) {
+		t.Fatalf("ImportProducts with variants for an existing product: got %+v, want ErrImportVariants", results)
+	}
+	assertImportWritten(t, s, owner.ID, beans.ID, false)
+
+	results, err = s.Products.ImportProducts(owner.ID, rows, false)
+	if err != nil {
+		t.Fatalf("ImportProducts: %v", err)
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("ImportProducts row %d: %v", i, result.Err)
+		}
+	}
+	assertImportWritten(t, s, owner.ID, beans.ID, true)
+
+	// Setting qty is recorded in the ledger like any other update
+	movements, total, err := s.Products.StockHistory(beans.ID, owner.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("StockHistory: %v", err)
+	}
+	if total != 2 || movements[0].Reason != models.StockMovementCorrection || movements[0].Delta != 10 {
+		t.Fatalf("StockHistory after import: got %d movements, latest %+v", total, movements[0])
+	}
+}
+
+// assertImportWritten checks whether the import of testProductImport has
+// updated beans and created the SKU-2 product.
+func assertImportWritten(t *testing.T, s Stores, userId uint, beansId int, written bool) {
+	t.Helper()
+
+	beans, err := s.Products.GetProductById(beansId)
+	if err != nil {
+		t.Fatalf("GetProductById: %v", err)
+	}
+	created := mustFilter(t, s, map[string]string{"user_id": fmt.Sprint(userId), "sku": "SKU-2"})
+
+	if written {
+		if beans.Name != "Dark Coffee Beans" || beans.Qty != 20 || beans.Price != 27000 || len(created) != 1 || created[0].Qty != 5 {
+			t.Fatalf("after import: got %+v and %+v", *beans, created)
+		}
+		return
+	}
+	if beans.Name != "Coffee Beans" || beans.Qty != 10 || beans.Price != 25000 || len(created) != 0 {
+		t.Fatalf("after an import that wrote nothing: got %+v and %+v", *beans, created)
+	}
+}
+
+func testProductFilters(t *testing.T, s Stores) {
+	alice, file := mustCreateSeller(t, s, "alice@example.com")
+	bob := mustCreateUser(t, s, "bob@example.com")
+
+	mustCreateProduct(t, s, alice.ID, newProductRequest("Fried Rice", "Food", "A-1", 3, 30000, file.FileID))
+	mustCreateProduct(t, s, alice.ID, newProductRequest("Iced Tea", "Beverage", "A-2", 3, 10000, file.FileID))
+	mustCreateProduct(t, s, bob.ID, newProductRequest("Hammer", "Tools", "B-1", 3, 20000, file.FileID))
+	mustCreateProduct(t, s, bob.ID, newProductRequest("Saw", "Tools", "B-2", 0, 25000, file.FileID))
+
+	byUser := mustFilter(t, s, map[string]string{"user_id": strconv.FormatUint(uint64(alice.ID), 10)})
+	if len(byUser) != 2 {
+		t.Fatalf("filter by user_id: got %d products, want 2", len(byUser))
+	}
+
+	byCategory := mustFilter(t, s, map[string]string{"category": "Tools"})
+	if len(byCategory) != 2 || byCategory[0].UserID != bob.ID {
+		t.Fatalf("filter by category: got %+v", byCategory)
+	}
+
+	byCategories := mustFilter(t, s, map[string]string{"category": "Food,Beverage"})
+	if len(byCategories) != 2 {
+		t.Fatalf("filter by several categories: got %d products, want 2", len(byCategories))
+	}
+
+	byPrice := mustFilter(t, s, map[string]string{"min_price": "20000", "max_price": "25000"})
+	if len(byPrice) != 2 {
+		t.Fatalf("filter by price range: got %d products, want 2", len(byPrice))
+	}
+
+	inStock := mustFilter(t, s, map[string]string{"category": "Tools", "in_stock": "true"})
+	if len(inStock) != 1 || inStock[0].Name != "Hammer" {
+		t.Fatalf("filter by in_stock=true: got %+v", inStock)
+	}
+	outOfStock := mustFilter(t, s, map[string]string{"in_stock": "false"})
+	if len(outOfStock) != 1 || outOfStock[0].Name != "Saw" {
+		t.Fatalf("filter by in_stock=false: got %+v", outOfStock)
+	}
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)
+	if created := mustFilter(t, s, map[string]string{"created_after": past, "created_before": future}); len(created) != 4 {
+		t.Fatalf("filter by creation window: got %d products, want 4", len(created))
+	}
+	if created := mustFilter(t, s, map[string]string{"created_after": future}); len(created) != 0 {
+		t.Fatalf("filter by created_after in the future: got %d products, want 0", len(created))
+	}
+
+	bySku := mustFilter(t, s, map[string]string{"sku": "A-2"})
+	if len(bySku) != 1 || bySku[0].Name != "Iced Tea" {
+		t.Fatalf("filter by sku: got %+v", bySku)
+	}
+
+	cheapest := mustFilter(t, s, map[string]string{"sort_by": "cheapest"})
+	if len(cheapest) != 4 || cheapest[0].Price != 10000 || cheapest[3].Price != 30000 {
+		t.Fatalf("sort by cheapest: got %+v", cheapest)
+	}
+
+	paged := mustFilter(t, s, map[string]string{"sort_by": "cheapest", "limit": "1", "offset": "1"})
+	if len(paged) != 1 || paged[0].Price != 20000 {
+		t.Fatalf("limit and offset: got %+v", paged)
+	}
+
+	total, err := s.Products.CountProducts(map[string]string{"category": "Tools", "limit": "1", "offset": "1"})
+	if err != nil || total != 2 {
+		t.Fatalf("CountProducts ignoring paging: got %d, %v, want 2", total, err)
+	}
+
+	// Walk every product newest first through keyset pages of two
+	var walked []models.Product
+	filters := map[string]string{"keyset": "true", "limit": "2"}
+	for page := 0; page < 3; page++ {
+		products := mustFilter(t, s, filters)
+		if len(products) == 0 {
+			break
+		}
+		walked = append(walked, products...)
+		last := products[len(products)-1]
+		filters["cursor_created_at"] = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+		filters["cursor_id"] = strconv.Itoa(last.ID)
+	}
+	if len(walked) != 4 {
+		t.Fatalf("keyset pagination: walked %d products, want 4", len(walked))
+	}
+	for i := 1; i < len(walked); i++ {
+		prev, cur := walked[i-1], walked[i]
+		if cur.CreatedAt.After(prev.CreatedAt) || (cur.CreatedAt.Equal(prev.CreatedAt) && cur.ID >= prev.ID) {
+			t.Fatalf("keyset pagination: product %d listed after %d out of order", cur.ID, prev.ID)
+		}
+	}
+
+	// A product oversold below zero is out of stock too
+	drill := newProductRequest("Drill", "Tools", "B-3", 1, 90000, file.FileID)
+	drill.AllowNegativeStock = true
+	oversold := mustCreateProduct(t, s, bob.ID, drill)
+	if _, err := s.Products.AdjustStock(oversold.ID, bob.ID, dto.StockAdjustmentRequest{Delta: -3, Reason: models.StockMovementAdjustment}); err != nil {
+		t.Fatalf("AdjustStock below zero: %v", err)
+	}
+	if outOfStock := mustFilter(t, s, map[string]string{"category": "Tools", "in_stock": "false", "sort_by": "cheapest"}); len(outOfStock) != 2 || outOfStock[1].Name != "Drill" {
+		t.Fatalf("filter by in_stock=false with an oversold product: got %+v", outOfStock)
+	}
+	if inStock := mustFilter(t, s, map[string]string{"category": "Tools", "in_stock": "true"}); len(inStock) != 1 {
+		t.Fatalf("filter by in_stock=true with an oversold product: got %+v", inStock)
+	}
+}
+
+func testProductOffsetPages(t *testing.T, s Stores) {
+	seller, file := mustCreateSeller(t, s, "seller@example.com")
+
+	// Every product ties on price so only the id tiebreaker orders them
+	var ids []int
+	for i := 1; i <= 5; i++ {
+		sku := fmt.Sprintf("PG-%d", i)
+		product := mustCreateProduct(t, s, seller.ID, newProductRequest("Pencil "+sku, "Tools", sku, 3, 5000, file.FileID))
+		ids = append(ids, product.ID)
+	}
+
+	for _, sortBy := range []string{"", "cheapest", "newest"} {
+		var walked []int
+		for offset := 0; offset < len(ids)+2; offset += 2 {
+			filters := map[string]string{"limit": "2", "offset": strconv.Itoa(offset)}
+			if sortBy != "" {
+				filters["sort_by"] = sortBy
+			}
+			for _, product := range mustFilter(t, s, filters) {
+				walked = append(walked, product.ID)
+			}
+		}
+		if sortBy == "newest" {
+			// Products created in the same instant tie here too, so only
+			// require every product to show up exactly once
+			seen := make(map[int]bool)
+			for _, id := range walked {
+				seen[id] = true
+			}
+			if len(walked) != len(ids) || len(seen) != len(ids) {
+				t.Fatalf("offset pages sorted by %q: walked %v, want each of %v once", sortBy, walked, ids)
+			}
+			continue
+		}
+		if fmt.Sprint(walked) != fmt.Sprint(ids) {
+			t.Fatalf("offset pages sorted by %q: walked %v, want %v", sortBy, walked, ids)
+		}
+	}
+}
+
+func testProductSearch(t *testing.T, s Stores) {
+	seller, file := mustCreateSeller(t, s, "seller@example.com")
+
+	mustCreateProduct(t, s, seller.ID, newProductRequest("Iced Coffee", "Beverage", "BEV-1", 3, 15000, file.FileID))
+	mustCreateProduct(t, s, seller.ID, newProductRequest("Coffee Table", "Furniture", "FUR-1", 3, 900000, file.FileID))
+	mustCreateProduct(t, s, seller.ID, newProductRequest("Hammer", "Tools", "TOO-1", 3, 20000, file.FileID))
+
+	prefix := mustFilter(t, s, map[string]string{"q": "coff"})
+	if len(prefix) != 2 {
+		t.Fatalf("prefix search: got %d products, want 2", len(prefix))
+	}
+
+	bySku := mustFilter(t, s, map[string]string{"q": "too"})
+	if len(bySku) != 1 || bySku[0].Name != "Hammer" {
+		t.Fatalf("search by sku/category: got %+v", bySku)
+	}
+
+	typo := mustFilter(t, s, map[string]string{"q": "kofee"})
+	if len(typo) != 2 {
+		t.Fatalf("typo search: got %d products, want 2", len(typo))
+	}
+
+	ranked := mustFilter(t, s, map[string]string{"q": "coffee table", "sort_by": "relevance"})
+	if len(ranked) == 0 || ranked[0].Name != "Coffee Table" {
+		t.Fatalf("relevance ranking: got %+v", ranked)
+	}
+
+	if none := mustFilter(t, s, map[string]string{"q": "bicycle"}); len(none) != 0 {
+		t.Fatalf("unmatched search: got %+v", none)
+	}
+}
+
+func testProductImages(t *testing.T, s Stores) {
+	seller := mustCreateUser(t, s, "seller@example.com")
+	front, side, back := mustCreateFile(t, s), mustCreateFile(t, s), mustCreateFile(t, s)
+
+	single := mustCreateProduct(t, s, seller.ID, newProductRequest("Hammer", "Tools", "T-1", 1, 1000, front.FileID))
+	assertImages(t, "product created with fileId", single, front.FileID)
+
+	req := newProductRequest("Chair", "Furniture", "F-1", 1, 1000, "")
+	req.FileIDs = []string{side.FileID, front.FileID}
+	gallery := mustCreateProduct(t, s, seller.ID, req)
+	if gallery.File.FileID != side.FileID {
+		t.Fatalf("primary image of product created with fileIds: got %s, want %s", gallery.File.FileID, side.FileID)
+	}
+	assertImages(t, "product created with fileIds", gallery, side.FileID, front.FileID)
+
+	reordered := []string{back.FileID, side.FileID, front.FileID}
+	if err := s.Products.UpdateProduct(gallery.ID, seller.ID, 0, dto.UpdateProductRequest{FileIDs: &reordered}); err != nil {
+		t.Fatalf("UpdateProduct fileIds: %v", err)
+	}
+	got, _ := s.Products.GetProductById(gallery.ID)
+	if got.File.FileID != back.FileID {
+		t.Fatalf("primary image after replacing the gallery: got %s, want %s", got.File.FileID, back.FileID)
+	}
+	assertImages(t, "product after replacing the gallery", *got, back.FileID, side.FileID, front.FileID)
+
+	if err := s.Products.UpdateProduct(gallery.ID, seller.ID, 0, dto.UpdateProductRequest{FileID: &front.FileID}); err != nil {
+		t.Fatalf("UpdateProduct fileId: %v", err)
+	}
+	got, _ = s.Products.GetProductById(gallery.ID)
+	assertImages(t, "product after replacing the primary image", *got, front.FileID, side.FileID, front.FileID)
+
+	listed := mustFilter(t, s, map[string]string{"product_id": strconv.Itoa(gallery.ID)})
+	if len(listed) != 1 {
+		t.Fatalf("filter by product_id: got %d products, want 1", len(listed))
+	}
+	assertImages(t, "listed product", listed[0], front.FileID, side.FileID, front.FileID)
+}
+
+func assertImages(t *testing.T, what string, product models.Product, fileIds ...string) {
+	t.Helper()
+
+	if len(product.Images) != len(fileIds) {
+		t.Fatalf("%s: got %d images, want %d", what, len(product.Images), len(fileIds))
+	}
+	for i, image := range product.Images {
+		if image.FileID != fileIds[i] || image.FileUri == "" {
+			t.Fatalf("%s: image %d is %+v, want file %s", what, i, image, fileIds[i])
+		}
+	}
+}
+
+func testProductSoftDelete(t *testing.T, s Stores) {
+	owner, file := mustCreateSeller(t, s, "owner@example.com")
+	stranger := mustCreateUser(t, s, "stranger@example.com")
+	reservedUntil := time.Now().Add(time.Hour)
+
+	kept := mustCreateProduct(t, s, owner.ID, newProductRequest("Hammer", "Tools", "T-1", 5, 1000, file.FileID))
+	sold := mustCreateProduct(t, s, owner.ID, newProductRequest("Wrench", "Tools", "T-2", 5, 1000, file.FileID))
+	archived := mustCreateProduct(t, s, owner.ID, newProductRequest("Pliers", "Tools", "T-3", 5, 1000, file.FileID))
+
+	yes := true
+	if err := s.Products.UpdateProduct(archived.ID, owner.ID, 0, dto.UpdateProductRequest{Archived: &yes}); err != nil {
+		t.Fatalf("UpdateProduct archived: %v", err)
+	}
+	if got := mustFilter(t, s, map[string]string{"visible_to": fmt.Sprint(stranger.ID)}); len(got) != 2 {
+		t.Fatalf("listing for another user: got %d products, want 2 without the archived one", len(got))
+	}
+	if got := mustFilter(t, s, map[string]string{"visible_to": fmt.Sprint(owner.ID)}); len(got) != 3 {
+		t.Fatalf("listing for the owner: got %d products, want 3 with the archived one", len(got))
+	}
+	if got, err := s.Products.GetProductById(archived.ID); err != nil || !got.Archived {
+		t.Fatalf("GetProductById of an archived product: got %+v, %v", got, err)
+	}
+	if _, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{archived.ID: 1}), reservedUntil); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("CreatePurchase of an archived product: got %v, want ErrProductNotFound", err)
+	}
+
+	purchase, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{sold.ID: 1}), reservedUntil)
+	if err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+
+	for _, product := range []models.Product{kept, sold} {
+		if err := s.Products.DeleteProduct(product.ID, owner.ID, 0); err != nil {
+			t.Fatalf("DeleteProduct: %v", err)
+		}
+	}
+	if _, err := s.Products.GetProductById(kept.ID); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("GetProductById of a deleted product: got %v, want ErrProductNotFound", err)
+	}
+	if got := mustFilter(t, s, map[string]string{"visible_to": fmt.Sprint(owner.ID)}); len(got) != 1 {
+		t.Fatalf("listing after delete: got %d products, want 1", len(got))
+	}
+	if total, err := s.Products.CountProducts(map[string]string{}); err != nil || total != 1 {
+		t.Fatalf("CountProducts after delete: got %d, %v, want 1", total, err)
+	}
+	if _, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{kept.ID: 1}), reservedUntil); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("CreatePurchase of a deleted product: got %v, want ErrProductNotFound", err)
+	}
+	if err := s.Products.UpdateProduct(kept.ID, owner.ID, 0, dto.UpdateProductRequest{Archived: &yes}); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("UpdateProduct of a deleted product: got %v, want ErrProductNotFound", err)
+	}
+
+	// A purchase made before the delete still goes through
+	if _, err := s.Purchases.ConfirmPayment(purchase.PublicID, []string{file.FileID}); err != nil {
+		t.Fatalf("ConfirmPayment for a deleted product: %v", err)
+	}
+
+	if err := s.Products.RestoreProduct(kept.ID, stranger.ID); !errors.Is(err, repositories.ErrProductForbidden) {
+		t.Fatalf("RestoreProduct by non-owner: got %v, want ErrProductForbidden", err)
+	}
+	if err := s.Products.RestoreProduct(kept.ID, owner.ID); err != nil {
+		t.Fatalf("RestoreProduct: %v", err)
+	}
+	if err := s.Products.RestoreProduct(kept.ID, owner.ID); err != nil {
+		t.Fatalf("RestoreProduct twice: %v", err)
+	}
+	if got, err := s.Products.GetProductById(kept.ID); err != nil || got.Qty != 5 {
+		t.Fatalf("GetProductById after restore: got %+v, %v", got, err)
+	}
+
+	if purged, err := s.Products.PurgeDeletedProducts(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Fatalf("PurgeDeletedProducts before retention: got %d, %v, want 0", purged, err)
+	}
+	if err := s.Products.DeleteProduct(kept.ID, owner.ID, 0); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	// The purchased product stays for the purchase history
+	if purged, err := s.Products.PurgeDeletedProducts(time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedProducts: got %d, %v, want 1", purged, err)
+	}
+	if err := s.Products.RestoreProduct(kept.ID, owner.ID); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("RestoreProduct after purge: got %v, want ErrProductNotFound", err)
+	}
+	if err := s.Products.RestoreProduct(sold.ID, owner.ID); err != nil {
+		t.Fatalf("RestoreProduct of a purchased product: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func testPurchases(t *testing.T, s Stores) {
+	alice, file := mustCreateSeller(t, s, "alice@example.com")
+	bob := mustCreateUser(t, s, "bob@example.com")
+	reservedUntil := time.Now().Add(time.Hour)
+
+	rice := mustCreateProduct(t, s, alice.ID, newProductRequest("Fried Rice", "Food", "A-1", 5, 30000, file.FileID))
+	tea := mustCreateProduct(t, s, alice.ID, newProductRequest("Iced Tea", "Beverage", "A-2", 5, 10000, file.FileID))
+	hammer := mustCreateProduct(t, s, bob.ID, newProductRequest("Hammer", "Tools", "B-1", 1, 20000, file.FileID))
+
+	if _, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{hammer.ID: 2}), reservedUntil); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("CreatePurchase over stock: got %v, want ErrInsufficientStock", err)
+	}
+	if _, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{hammer.ID + 1000: 1}), reservedUntil); !errors.Is(err, repositories.ErrProductNotFound) {
+		t.Fatalf("CreatePurchase for unknown product: got %v, want ErrProductNotFound", err)
+	}
+
+	purchase, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{rice.ID: 2, tea.ID: 1, hammer.ID: 1}), reservedUntil)
+	if err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+	if purchase.Status != models.PurchaseStatusPending || purchase.TotalPrice != 2*30000+10000+20000 || len(purchase.Items) != 3 {
+		t.Fatalf("CreatePurchase returned %+v", purchase)
+	}
+	if purchase.PublicID == "" || purchase.PublicID == strconv.Itoa(purchase.ID) {
+		t.Fatalf("CreatePurchase returned public id %q for purchase %d, want a uuid", purchase.PublicID, purchase.ID)
+	}
+
+	if _, err := s.Purchases.ConfirmPayment(missingPurchaseId, []string{file.FileID}); !errors.Is(err, repositories.ErrPurchaseNotFound) {
+		t.Fatalf("ConfirmPayment for unknown purchase: got %v, want ErrPurchaseNotFound", err)
+	}
+	if _, err := s.Purchases.ConfirmPayment(purchase.PublicID, []string{file.FileID}); !errors.Is(err, repositories.ErrPaymentProofMismatch) {
+		t.Fatalf("ConfirmPayment with one proof for two sellers: got %v, want ErrPaymentProofMismatch", err)
+	}
+	if _, err := s.Purchases.ConfirmPayment(purchase.PublicID, []string{file.FileID, missingFileId}); !errors.Is(err, repositories.ErrFileNotFound) {
+		t.Fatalf("ConfirmPayment with unknown file: got %v, want ErrFileNotFound", err)
+	}
+
+	proof := mustCreateFile(t, s)
+	paid, err := s.Purchases.ConfirmPayment(purchase.PublicID, []string{file.FileID, proof.FileID})
+	if err != nil {
+		t.Fatalf("ConfirmPayment: %v", err)
+	}
+	if paid.Status != models.PurchaseStatusPaid {
+		t.Fatalf("ConfirmPayment returned status %q", paid.Status)
+	}
+
+	if got, err := s.Products.GetProductById(rice.ID); err != nil || got.Qty != 3 {
+		t.Fatalf("stock after payment: got %+v, %v, want qty 3", got, err)
+	}
+	if got, err := s.Products.GetProductById(hammer.ID); err != nil || got.Qty != 0 {
+		t.Fatalf("stock after payment: got %+v, %v, want qty 0", got, err)
+	}
+
+	if _, err := s.Purchases.ConfirmPayment(purchase.PublicID, []string{file.FileID, proof.FileID}); !errors.Is(err, repositories.ErrPurchaseAlreadyPaid) {
+		t.Fatalf("ConfirmPayment twice: got %v, want ErrPurchaseAlreadyPaid", err)
+	}
+}
+
+func testReservations(t *testing.T, s Stores) {
+	seller, file := mustCreateSeller(t, s, "seller@example.com")
+	reservedUntil := time.Now().Add(time.Hour)
+
+	rice := mustCreateProduct(t, s, seller.ID, newProductRequest("Fried Rice", "Food", "A-1", 3, 30000, file.FileID))
+	req := newProductRequest("Shirt", "Clothes", "S-1", 0, 0, file.FileID)
+	req.Options = []string{"size"}
+	req.Variants = []dto.CreateVariantRequest{
+		{Options: map[string]string{"size": "M"}, SKU: "S-1-M", Price: 50000, Qty: 1},
+		{Options: map[string]string{"size": "L"}, SKU: "S-1-L", Price: 50000, Qty: 2},
+	}
+	shirt := mustCreateProduct(t, s, seller.ID, req)
+	medium := shirt.Variants[0]
+
+	assertStock := func(what string, productId, qty, reserved int) {
+		t.Helper()
+		got, err := s.Products.GetProductById(productId)
+		if err != nil {
+			t.Fatalf("%s: GetProductById: %v", what, err)
+		}
+		if got.Qty != qty || got.ReservedQty != reserved {
+			t.Fatalf("%s: got qty %d with %d reserved, want %d with %d reserved", what, got.Qty, got.ReservedQty, qty, reserved)
+		}
+	}
+
+	held, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{rice.ID: 2}), reservedUntil)
+	if err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+	if held.ReservedUntil == nil || held.Items[0].Product.ReservedQty != 2 {
+		t.Fatalf("CreatePurchase returned %+v", held)
+	}
+	assertStock("after CreatePurchase", rice.ID, 3, 2)
+	if _, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{rice.ID: 2}), reservedUntil); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("CreatePurchase over available stock: got %v, want ErrInsufficientStock", err)
+	}
+
+	lapsed, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{rice.ID: 1}), time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("CreatePurchase of the last unit: %v", err)
+	}
+	purchaseReq := newPurchaseRequest(nil)
+	purchaseReq.PurchasedItems = []dto.PurchasedItemRequest{{ProductID: fmt.Sprint(shirt.ID), VariantID: fmt.Sprint(medium.ID), Qty: 1}}
+	if _, err := s.Purchases.CreatePurchase(purchaseReq, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("CreatePurchase of a variant: %v", err)
+	}
+	if _, err := s.Purchases.CreatePurchase(purchaseReq, reservedUntil); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("CreatePurchase over available variant stock: got %v, want ErrInsufficientStock", err)
+	}
+	assertStock("after reserving a variant", shirt.ID, 3, 1)
+
+	if _, err := s.Purchases.ConfirmPayment(lapsed.PublicID, []string{file.FileID}); !errors.Is(err, repositories.ErrPurchaseExpired) {
+		t.Fatalf("ConfirmPayment after the reservation ran out: got %v, want ErrPurchaseExpired", err)
+	}
+	expired, err := s.Purchases.ExpireReservations(time.Now())
+	if err != nil || expired != 2 {
+		t.Fatalf("ExpireReservations: got %d, %v, want 2", expired, err)
+	}
+	assertStock("after ExpireReservations", rice.ID, 3, 2)
+	assertStock("variant after ExpireReservations", shirt.ID, 3, 0)
+
+	// Reserving and releasing stock leaves the seller's version alone
+	for _, product := range []models.Product{rice, shirt} {
+		if got, _ := s.Products.GetProductById(product.ID); got.Version != product.Version {
+			t.Fatalf("product %d after reserving and releasing: got version %d, want %d", product.ID, got.Version, product.Version)
+		}
+	}
+	if got, _ := s.Products.GetProductById(shirt.ID); got.Variants[0].ReservedQty != 0 {
+		t.Fatalf("variant after ExpireReservations: got %d reserved, want 0", got.Variants[0].ReservedQty)
+	}
+	if _, err := s.Purchases.ConfirmPayment(lapsed.PublicID, []string{file.FileID}); !errors.Is(err, repositories.ErrPurchaseExpired) {
+		t.Fatalf("ConfirmPayment of an expired purchase: got %v, want ErrPurchaseExpired", err)
+	}
+
+	// The seller cannot take stock below what is reserved, or the buyer
+	// would be refused at payment
+	takeOut := dto.StockAdjustmentRequest{Delta: -2, Reason: models.StockMovementAdjustment}
+	if _, err := s.Products.AdjustStock(rice.ID, seller.ID, takeOut); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("AdjustStock below the reserved stock: got %v, want ErrInsufficientStock", err)
+	}
+	one := 1
+	if err := s.Products.UpdateProduct(rice.ID, seller.ID, 0, dto.UpdateProductRequest{Qty: &one}); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("UpdateProduct qty below the reserved stock: got %v, want ErrInsufficientStock", err)
+	}
+	assertStock("after taking out reserved stock", rice.ID, 3, 2)
+
+	paid, err := s.Purchases.ConfirmPayment(held.PublicID, []string{file.FileID})
+	if err != nil {
+		t.Fatalf("ConfirmPayment: %v", err)
+	}
+	if paid.ReservedUntil != nil || paid.Items[0].Product.Qty != 1 || paid.Items[0].Product.ReservedQty != 0 {
+		t.Fatalf("ConfirmPayment returned %+v", paid)
+	}
+	assertStock("after ConfirmPayment", rice.ID, 1, 0)
+
+	if expired, err := s.Purchases.ExpireReservations(time.Now().Add(2 * time.Hour)); err != nil || expired != 0 {
+		t.Fatalf("ExpireReservations with nothing pending: got %d, %v, want 0", expired, err)
+	}
+
+	// Every line of a product reports the product's whole reservation
+	both := newPurchaseRequest(nil)
+	both.PurchasedItems = []dto.PurchasedItemRequest{
+		{ProductID: fmt.Sprint(shirt.ID), VariantID: fmt.Sprint(medium.ID), Qty: 1},
+		{ProductID: fmt.Sprint(shirt.ID), VariantID: fmt.Sprint(shirt.Variants[1].ID), Qty: 1},
+	}
+	bothHeld, err := s.Purchases.CreatePurchase(both, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("CreatePurchase of two variants: %v", err)
+	}
+	for _, item := range bothHeld.Items {
+		if item.Product.ReservedQty != 2 || item.Variant.ReservedQty != 1 {
+			t.Fatalf("CreatePurchase of two variants: item reports %d reserved of the product and %d of the variant, want 2 and 1",
+				item.Product.ReservedQty, item.Variant.ReservedQty)
+		}
+	}
+	if _, err := s.Purchases.ExpireReservations(time.Now()); err != nil {
+		t.Fatalf("ExpireReservations: %v", err)
+	}
+
+	// Nor can the seller take a variant below its reserved stock
+	variantHeld, err := s.Purchases.CreatePurchase(purchaseReq, reservedUntil)
+	if err != nil {
+		t.Fatalf("CreatePurchase of a variant: %v", err)
+	}
+	zero := 0
+	if _, err := s.Products.UpdateVariant(shirt.ID, medium.ID, seller.ID, dto.UpdateVariantRequest{Qty: &zero}); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("UpdateVariant qty below the reserved stock: got %v, want ErrInsufficientStock", err)
+	}
+	takeOut = dto.StockAdjustmentRequest{Delta: -1, Reason: models.StockMovementAdjustment, VariantID: fmt.Sprint(medium.ID)}
+	if _, err := s.Products.AdjustStock(shirt.ID, seller.ID, takeOut); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("AdjustStock of a variant below its reserved stock: got %v, want ErrInsufficientStock", err)
+	}
+	if _, err := s.Purchases.ConfirmPayment(variantHeld.PublicID, []string{file.FileID}); err != nil {
+		t.Fatalf("ConfirmPayment of a variant: %v", err)
+	}
+	assertStock("after paying for a variant", shirt.ID, 2, 0)
+
+	// Listings filter stock on what is left after reservations
+	teaReq := newProductRequest("Iced Tea", "Beverage", "B-1", 3, 10000, file.FileID)
+	teaReq.LowStockThreshold = 1
+	tea := mustCreateProduct(t, s, seller.ID, teaReq)
+	teaFilters := func(key string) map[string]string {
+		return map[string]string{"sku": "B-1", key: "true"}
+	}
+	if low := mustFilter(t, s, teaFilters("low_stock")); len(low) != 0 {
+		t.Fatalf("low_stock before reserving: got %d products, want 0", len(low))
+	}
+	if _, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{tea.ID: 2}), reservedUntil); err != nil {
+		t.Fatalf("CreatePurchase of tea: %v", err)
+	}
+	if low := mustFilter(t, s, teaFilters("low_stock")); len(low) != 1 {
+		t.Fatalf("low_stock with one unit available: got %d products, want 1", len(low))
+	}
+	if _, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{tea.ID: 1}), reservedUntil); err != nil {
+		t.Fatalf("CreatePurchase of the last tea: %v", err)
+	}
+	if inStock := mustFilter(t, s, teaFilters("in_stock")); len(inStock) != 0 {
+		t.Fatalf("in_stock=true with every unit reserved: got %+v", inStock)
+	}
+	if outOfStock := mustFilter(t, s, map[string]string{"sku": "B-1", "in_stock": "false"}); len(outOfStock) != 1 {
+		t.Fatalf("in_stock=false with every unit reserved: got %d products, want 1", len(outOfStock))
+	}
+}
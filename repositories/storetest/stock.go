@@ -0,0 +1,180 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func testStockLedger(t *testing.T, s Stores) {
+	owner, file := mustCreateSeller(t, s, "owner@example.com")
+	stranger := mustCreateUser(t, s, "stranger@example.com")
+	reservedUntil := time.Now().Add(time.Hour)
+
+	product := mustCreateProduct(t, s, owner.ID, newProductRequest("Hammer", "Tools", "T-1", 5, 1000, file.FileID))
+
+	restock, err := s.Products.AdjustStock(product.ID, owner.ID, dto.StockAdjustmentRequest{Delta: 3, Reason: models.StockMovementRestock, Note: "delivery"})
+	if err != nil {
+		t.Fatalf("AdjustStock: %v", err)
+	}
+	if restock.QtyAfter != 8 || restock.UserID != owner.ID || restock.Note != "delivery" {
+		t.Fatalf("AdjustStock returned %+v", restock)
+	}
+	if _, err := s.Products.AdjustStock(product.ID, owner.ID, dto.StockAdjustmentRequest{Delta: -10, Reason: models.StockMovementAdjustment}); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("AdjustStock below zero: got %v, want ErrInsufficientStock", err)
+	}
+	if _, err := s.Products.AdjustStock(product.ID, stranger.ID, dto.StockAdjustmentRequest{Delta: 1, Reason: models.StockMovementRestock}); !errors.Is(err, repositories.ErrProductForbidden) {
+		t.Fatalf("AdjustStock by non-owner: got %v, want ErrProductForbidden", err)
+	}
+	if _, _, err := s.Products.StockHistory(product.ID, stranger.ID, 10, 0); !errors.Is(err, repositories.ErrProductForbidden) {
+		t.Fatalf("StockHistory by non-owner: got %v, want ErrProductForbidden", err)
+	}
+
+	qty := 6
+	if err := s.Products.UpdateProduct(product.ID, owner.ID, 0, dto.UpdateProductRequest{Qty: &qty}); err != nil {
+		t.Fatalf("UpdateProduct qty: %v", err)
+	}
+
+	purchase, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{product.ID: 2}), reservedUntil)
+	if err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+	if _, err := s.Purchases.ConfirmPayment(purchase.PublicID, []string{file.FileID}); err != nil {
+		t.Fatalf("ConfirmPayment: %v", err)
+	}
+
+	history, total, err := s.Products.StockHistory(product.ID, owner.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("StockHistory: %v", err)
+	}
+	want := []struct {
+		reason       string
+		delta, after int
+	}{
+		{models.StockMovementSale, -2, 4},
+		{models.StockMovementCorrection, -2, 6},
+		{models.StockMovementRestock, 3, 8},
+		{models.StockMovementRestock, 5, 5},
+	}
+	if total != len(want) || len(history) != len(want) {
+		t.Fatalf("StockHistory: got %d of %d movements, want %d", len(history), total, len(want))
+	}
+	for i, movement := range history {
+		if movement.Reason != want[i].reason || movement.Delta != want[i].delta || movement.QtyAfter != want[i].after {
+			t.Fatalf("movement %d: got %+v, want %+v", i, movement, want[i])
+		}
+	}
+	if history[0].PurchaseID != purchase.ID || history[0].PurchasePublicID != purchase.PublicID {
+		t.Fatalf("sale movement: got purchase %d (%q), want %d (%q)", history[0].PurchaseID, history[0].PurchasePublicID, purchase.ID, purchase.PublicID)
+	}
+	if page, _, _ := s.Products.StockHistory(product.ID, owner.ID, 2, 1); len(page) != 2 || page[0].ID != history[1].ID {
+		t.Fatalf("StockHistory page: got %+v", page)
+	}
+	if got, _ := s.Products.GetProductById(product.ID); got.Qty != 4 {
+		t.Fatalf("qty after the ledger: got %d, want 4", got.Qty)
+	}
+
+	req := newProductRequest("Screws", "Tools", "T-2", 1, 1000, file.FileID)
+	req.AllowNegativeStock = true
+	backorder := mustCreateProduct(t, s, owner.ID, req)
+	if movement, err := s.Products.AdjustStock(backorder.ID, owner.ID, dto.StockAdjustmentRequest{Delta: -3, Reason: models.StockMovementAdjustment}); err != nil || movement.QtyAfter != -2 {
+		t.Fatalf("AdjustStock below zero when allowed: got %+v, %v", movement, err)
+	}
+	no := false
+	if err := s.Products.UpdateProduct(backorder.ID, owner.ID, 0, dto.UpdateProductRequest{AllowNegativeStock: &no}); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("disallowing negative stock below zero: got %v, want ErrInsufficientStock", err)
+	}
+
+	variantReq := newProductRequest("Shirt", "Clothes", "S-1", 0, 0, file.FileID)
+	variantReq.Options = []string{"size"}
+	variantReq.Variants = []dto.CreateVariantRequest{{Options: map[string]string{"size": "M"}, SKU: "S-1-M", Price: 1000, Qty: 2}}
+	shirt := mustCreateProduct(t, s, owner.ID, variantReq)
+	if _, err := s.Products.AdjustStock(shirt.ID, owner.ID, dto.StockAdjustmentRequest{Delta: 1, Reason: models.StockMovementRestock}); !errors.Is(err, repositories.ErrVariantRequired) {
+		t.Fatalf("AdjustStock without a variant: got %v, want ErrVariantRequired", err)
+	}
+	variantId := fmt.Sprint(shirt.Variants[0].ID)
+	if movement, err := s.Products.AdjustStock(shirt.ID, owner.ID, dto.StockAdjustmentRequest{Delta: 4, Reason: models.StockMovementReturn, VariantID: variantId}); err != nil || movement.QtyAfter != 6 {
+		t.Fatalf("AdjustStock of a variant: got %+v, %v", movement, err)
+	}
+	if got, _ := s.Products.GetProductById(shirt.ID); got.Qty != 6 || got.Variants[0].Qty != 6 {
+		t.Fatalf("qty after adjusting a variant: got %+v", got)
+	}
+}
+
+func testStockAlerts(t *testing.T, s Stores) {
+	seller, file := mustCreateSeller(t, s, "seller@example.com")
+
+	req := newProductRequest("Hammer", "Tools", "T-1", 5, 1000, file.FileID)
+	req.LowStockThreshold = 2
+	hammer := mustCreateProduct(t, s, seller.ID, req)
+	untracked := mustCreateProduct(t, s, seller.ID, newProductRequest("Wrench", "Tools", "T-2", 1, 1000, file.FileID))
+
+	adjust := func(productId, delta int) {
+		t.Helper()
+		if _, err := s.Products.AdjustStock(productId, seller.ID, dto.StockAdjustmentRequest{Delta: delta, Reason: models.StockMovementAdjustment}); err != nil {
+			t.Fatalf("AdjustStock(%d): %v", delta, err)
+		}
+	}
+	assertPending := func(what string, want int) []models.StockAlert {
+		t.Helper()
+		alerts, err := s.StockAlerts.PendingStockAlerts(10)
+		if err != nil {
+			t.Fatalf("%s: PendingStockAlerts: %v", what, err)
+		}
+		if len(alerts) != want {
+			t.Fatalf("%s: got %d pending alerts, want %d", what, len(alerts), want)
+		}
+		return alerts
+	}
+
+	adjust(hammer.ID, -2)
+	adjust(untracked.ID, -1)
+	assertPending("above the threshold", 0)
+
+	adjust(hammer.ID, -1)
+	alerts := assertPending("at the threshold", 1)
+	if alerts[0].ProductID != hammer.ID || alerts[0].Qty != 2 || alerts[0].Threshold != 2 || alerts[0].Product.Name != "Hammer" || alerts[0].Seller.Email != "seller@example.com" {
+		t.Fatalf("PendingStockAlerts returned %+v", alerts[0])
+	}
+	adjust(hammer.ID, -1)
+	assertPending("below the threshold again", 1)
+
+	low := mustFilter(t, s, map[string]string{"user_id": fmt.Sprint(seller.ID), "low_stock": "true", "sort_by": "lowest_stock"})
+	if len(low) != 1 || low[0].ID != hammer.ID || low[0].LowStockThreshold != 2 {
+		t.Fatalf("low stock listing: got %+v", low)
+	}
+
+	if err := s.StockAlerts.MarkStockAlertDelivered(alerts[0].ID); err != nil {
+		t.Fatalf("MarkStockAlertDelivered: %v", err)
+	}
+	assertPending("after delivery", 0)
+
+	adjust(hammer.ID, 5)
+	assertPending("after restocking", 0)
+	qty := 1
+	if err := s.Products.UpdateProduct(hammer.ID, seller.ID, 0, dto.UpdateProductRequest{Qty: &qty}); err != nil {
+		t.Fatalf("UpdateProduct qty: %v", err)
+	}
+	assertPending("falling again after recovering", 1)
+
+	if err := s.Products.DeleteProduct(hammer.ID, seller.ID, 0); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	assertPending("after deleting the product", 0)
+	if err := s.Products.RestoreProduct(hammer.ID, seller.ID); err != nil {
+		t.Fatalf("RestoreProduct: %v", err)
+	}
+	assertPending("after restoring the product", 1)
+
+	threshold := 0
+	if err := s.Products.UpdateProduct(hammer.ID, seller.ID, 0, dto.UpdateProductRequest{LowStockThreshold: &threshold}); err != nil {
+		t.Fatalf("UpdateProduct lowStockThreshold: %v", err)
+	}
+	if low := mustFilter(t, s, map[string]string{"user_id": fmt.Sprint(seller.ID), "low_stock": "true"}); len(low) != 0 {
+		t.Fatalf("low stock listing with alerts off: got %d products, want 0", len(low))
+	}
+}
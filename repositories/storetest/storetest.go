@@ -0,0 +1,55 @@
+// Package storetest is the contract test suite for the store interfaces in
+// package repositories. Every implementation is expected to pass it, which
+// is what lets handler tests substitute the in-memory store for Postgres:
+//
+//	func TestMemoryStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) storetest.Stores {
+//			store := memory.New()
+//			return storetest.Stores{Products: store, Files: store, Users: store, Purchases: store, Categories: store, StockAlerts: store}
+//		})
+//	}
+//
+// The Postgres repositories are run the same way, with newStores returning
+// repositories built on a freshly migrated database.
+package storetest
+
+import (
+	"testing"
+	"tutuplapak/repositories"
+)
+
+type Stores struct {
+	Products    repositories.ProductStore
+	Files       repositories.FileStore
+	Users       repositories.UserStore
+	Purchases   repositories.PurchaseStore
+	Categories  repositories.CategoryStore
+	StockAlerts repositories.StockAlertStore
+}
+
+// Run executes the whole suite. newStores is called once per subtest and
+// must return stores that share one empty backing database.
+func Run(t *testing.T, newStores func(t *testing.T) Stores) {
+	t.Run("Files", func(t *testing.T) { testFiles(t, newStores(t)) })
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStores(t)) })
+	t.Run("Products", func(t *testing.T) { testProducts(t, newStores(t)) })
+	t.Run("ProductSKUs", func(t *testing.T) { testProductSKUs(t, newStores(t)) })
+	t.Run("ProductImport", func(t *testing.T) { testProductImport(t, newStores(t)) })
+	t.Run("ProductFilters", func(t *testing.T) { testProductFilters(t, newStores(t)) })
+	t.Run("ProductOffsetPages", func(t *testing.T) { testProductOffsetPages(t, newStores(t)) })
+	t.Run("ProductSearch", func(t *testing.T) { testProductSearch(t, newStores(t)) })
+	t.Run("ProductImages", func(t *testing.T) { testProductImages(t, newStores(t)) })
+	t.Run("ProductVariants", func(t *testing.T) { testProductVariants(t, newStores(t)) })
+	t.Run("ProductSoftDelete", func(t *testing.T) { testProductSoftDelete(t, newStores(t)) })
+	t.Run("StockLedger", func(t *testing.T) { testStockLedger(t, newStores(t)) })
+	t.Run("StockAlerts", func(t *testing.T) { testStockAlerts(t, newStores(t)) })
+	t.Run("Purchases", func(t *testing.T) { testPurchases(t, newStores(t)) })
+	t.Run("Reservations", func(t *testing.T) { testReservations(t, newStores(t)) })
+	t.Run("Categories", func(t *testing.T) { testCategories(t, newStores(t)) })
+	t.Run("CategoryTree", func(t *testing.T) { testCategoryTree(t, newStores(t)) })
+}
+
+const (
+	missingFileId     = "00000000-0000-4000-8000-000000000000"
+	missingPurchaseId = "00000000-0000-4000-8000-000000000000"
+)
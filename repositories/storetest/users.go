@@ -0,0 +1,104 @@
+package storetest
+
+import (
+	"errors"
+	"testing"
+	"tutuplapak/dto"
+	"tutuplapak/repositories"
+)
+
+func testFiles(t *testing.T, s Stores) {
+	file, err := s.Files.CreateFile("http://files/a.png", "http://files/a_thumbnail.jpg")
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	if file.FileID == "" || file.FileUri != "http://files/a.png" || file.FileThumbnailUri != "http://files/a_thumbnail.jpg" {
+		t.Fatalf("CreateFile returned %+v", file)
+	}
+
+	assertFileExists(t, s.Files, file.FileID, true)
+	assertFileExists(t, s.Files, missingFileId, false)
+	assertFileExists(t, s.Products, file.FileID, true)
+}
+
+func testUsers(t *testing.T, s Stores) {
+	user, err := s.Users.CreateUser("seller@example.com", "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.ID == 0 || user.Email != "seller@example.com" || user.Phone != "" || user.PasswordHash != "hash" {
+		t.Fatalf("CreateUser returned %+v", user)
+	}
+
+	if _, err := s.Users.CreateUser("seller@example.com", "", "hash"); !errors.Is(err, repositories.ErrUserAlreadyExists) {
+		t.Fatalf("CreateUser with duplicate email: got %v, want ErrUserAlreadyExists", err)
+	}
+
+	other, err := s.Users.CreateUser("", "+6281234567890", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser by phone: %v", err)
+	}
+
+	if got, err := s.Users.GetUserByEmail("seller@example.com"); err != nil || got.ID != user.ID {
+		t.Fatalf("GetUserByEmail: got %+v, %v", got, err)
+	}
+	if got, err := s.Users.GetUserByPhone("+6281234567890"); err != nil || got.ID != other.ID {
+		t.Fatalf("GetUserByPhone: got %+v, %v", got, err)
+	}
+	if _, err := s.Users.GetUserByEmail("nobody@example.com"); !errors.Is(err, repositories.ErrUserNotFound) {
+		t.Fatalf("GetUserByEmail for unknown email: got %v, want ErrUserNotFound", err)
+	}
+	if _, err := s.Users.GetUserById(user.ID + other.ID + 1000); !errors.Is(err, repositories.ErrUserNotFound) {
+		t.Fatalf("GetUserById for unknown id: got %v, want ErrUserNotFound", err)
+	}
+
+	file := mustCreateFile(t, s)
+	updated, err := s.Users.UpdateProfile(user.ID, dto.UpdateUserRequest{
+		FileID:            file.FileID,
+		BankAccountName:   "Bank Central",
+		BankAccountHolder: "Seller Name",
+		BankAccountNumber: "1234567890",
+	})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if updated.BankAccountNumber != "1234567890" || updated.File.FileID != file.FileID || updated.File.FileUri != file.FileUri {
+		t.Fatalf("UpdateProfile returned %+v", updated)
+	}
+
+	if _, err := s.Users.LinkPhone(user.ID, "+6281234567890"); !errors.Is(err, repositories.ErrUserAlreadyExists) {
+		t.Fatalf("LinkPhone to a taken phone: got %v, want ErrUserAlreadyExists", err)
+	}
+
+	linked, err := s.Users.LinkPhone(user.ID, "+6289999999999")
+	if err != nil {
+		t.Fatalf("LinkPhone: %v", err)
+	}
+	if linked.Phone != "+6289999999999" || linked.Email != "seller@example.com" {
+		t.Fatalf("LinkPhone returned %+v", linked)
+	}
+	if got, err := s.Users.GetUserByPhone("+6289999999999"); err != nil || got.ID != user.ID {
+		t.Fatalf("GetUserByPhone after link: got %+v, %v", got, err)
+	}
+
+	// An identifier the user already has is never replaced
+	if _, err := s.Users.LinkPhone(user.ID, "+6287777777777"); !errors.Is(err, repositories.ErrIdentifierLinked) {
+		t.Fatalf("LinkPhone for a user with a phone: got %v, want ErrIdentifierLinked", err)
+	}
+	if _, err := s.Users.LinkEmail(user.ID, "thief@example.com"); !errors.Is(err, repositories.ErrIdentifierLinked) {
+		t.Fatalf("LinkEmail for a user with an email: got %v, want ErrIdentifierLinked", err)
+	}
+	if got, err := s.Users.GetUserById(user.ID); err != nil || got.Email != "seller@example.com" || got.Phone != "+6289999999999" {
+		t.Fatalf("GetUserById after refused links: got %+v, %v", got, err)
+	}
+	if _, err := s.Users.LinkEmail(user.ID+other.ID+1000, "nobody@example.com"); !errors.Is(err, repositories.ErrUserNotFound) {
+		t.Fatalf("LinkEmail for unknown id: got %v, want ErrUserNotFound", err)
+	}
+
+	if _, err := s.Users.LinkEmail(other.ID, "seller@example.com"); !errors.Is(err, repositories.ErrUserAlreadyExists) {
+		t.Fatalf("LinkEmail to a taken email: got %v, want ErrUserAlreadyExists", err)
+	}
+	if _, err := s.Users.LinkEmail(other.ID, "buyer@example.com"); err != nil {
+		t.Fatalf("LinkEmail: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+	"tutuplapak/repositories"
+)
+
+func testProductVariants(t *testing.T, s Stores) {
+	seller := mustCreateUser(t, s, "seller@example.com")
+	other := mustCreateUser(t, s, "other@example.com")
+	file, swatch := mustCreateFile(t, s), mustCreateFile(t, s)
+	reservedUntil := time.Now().Add(time.Hour)
+
+	req := newProductRequest("Shirt", "Clothes", "S-1", 0, 0, file.FileID)
+	req.Options = []string{"size", "color"}
+	req.Variants = []dto.CreateVariantRequest{
+		{Options: map[string]string{"size": "M", "color": "red"}, SKU: "S-1-MR", Price: 50000, Qty: 2, FileID: swatch.FileID},
+		{Options: map[string]string{"size": "L", "color": "red"}, SKU: "S-1-LR", Price: 60000, Qty: 3},
+	}
+	shirt := mustCreateProduct(t, s, seller.ID, req)
+	if shirt.Qty != 5 || shirt.Price != 50000 || len(shirt.Variants) != 2 {
+		t.Fatalf("CreateProduct with variants returned qty %d, price %v, %d variants", shirt.Qty, shirt.Price, len(shirt.Variants))
+	}
+	medium, large := shirt.Variants[0], shirt.Variants[1]
+	if strings.Join(medium.OptionValues, ",") != "M,red" || medium.File.FileUri == "" || large.FileID != "" {
+		t.Fatalf("CreateProduct variants: got %+v and %+v", medium, large)
+	}
+
+	duplicate := req
+	duplicate.SKU = "S-2"
+	duplicate.Variants = append([]dto.CreateVariantRequest{}, req.Variants[0], req.Variants[0])
+	if _, err := s.Products.CreateProduct(seller.ID, duplicate); !errors.Is(err, repositories.ErrVariantAlreadyExists) {
+		t.Fatalf("CreateProduct with duplicate variants: got %v, want ErrVariantAlreadyExists", err)
+	}
+	mismatched := req
+	mismatched.SKU = "S-3"
+	mismatched.Variants = []dto.CreateVariantRequest{{Options: map[string]string{"size": "M"}, SKU: "S-1-M", Price: 50000, Qty: 1}}
+	if _, err := s.Products.CreateProduct(seller.ID, mismatched); !errors.Is(err, repositories.ErrVariantOptionsMismatch) {
+		t.Fatalf("CreateProduct with a missing option: got %v, want ErrVariantOptionsMismatch", err)
+	}
+
+	blue, err := s.Products.CreateVariant(shirt.ID, seller.ID, dto.CreateVariantRequest{
+		Options: map[string]string{"size": "M", "color": "blue"}, SKU: "S-1-MB", Price: 40000, Qty: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateVariant: %v", err)
+	}
+	if _, err := s.Products.CreateVariant(shirt.ID, other.ID, dto.CreateVariantRequest{
+		Options: map[string]string{"size": "S", "color": "blue"}, SKU: "S-1-SB", Price: 40000, Qty: 1,
+	}); !errors.Is(err, repositories.ErrProductForbidden) {
+		t.Fatalf("CreateVariant by another user: got %v, want ErrProductForbidden", err)
+	}
+	if _, err := s.Products.CreateVariant(shirt.ID, seller.ID, dto.CreateVariantRequest{
+		Options: map[string]string{"size": "M", "color": "blue"}, SKU: "S-1-MB", Price: 40000, Qty: 1,
+	}); !errors.Is(err, repositories.ErrVariantAlreadyExists) {
+		t.Fatalf("CreateVariant twice: got %v, want ErrVariantAlreadyExists", err)
+	}
+
+	simple := mustCreateProduct(t, s, seller.ID, newProductRequest("Hammer", "Tools", "T-1", 1, 1000, file.FileID))
+	if _, err := s.Products.CreateVariant(simple.ID, seller.ID, dto.CreateVariantRequest{
+		Options: map[string]string{"size": "M"}, SKU: "T-1-M", Price: 1000, Qty: 1,
+	}); !errors.Is(err, repositories.ErrProductHasNoOptions) {
+		t.Fatalf("CreateVariant on a product without options: got %v, want ErrProductHasNoOptions", err)
+	}
+
+	// A product with options but no variants yet keeps its own stock until
+	// it is taken out through the ledger
+	mug := newProductRequest("Mug", "Tools", "M-1", 4, 20000, file.FileID)
+	mug.Options = []string{"color"}
+	mugProduct := mustCreateProduct(t, s, seller.ID, mug)
+	white := dto.CreateVariantRequest{Options: map[string]string{"color": "white"}, SKU: "M-1-W", Price: 20000, Qty: 4}
+	if _, err := s.Products.CreateVariant(mugProduct.ID, seller.ID, white); !errors.Is(err, repositories.ErrProductHasStock) {
+		t.Fatalf("CreateVariant on a product with stock: got %v, want ErrProductHasStock", err)
+	}
+	if _, err := s.Products.AdjustStock(mugProduct.ID, seller.ID, dto.StockAdjustmentRequest{Delta: -4, Reason: models.StockMovementAdjustment}); err != nil {
+		t.Fatalf("AdjustStock: %v", err)
+	}
+	if _, err := s.Products.CreateVariant(mugProduct.ID, seller.ID, white); err != nil {
+		t.Fatalf("CreateVariant once the product's stock is 0: %v", err)
+	}
+
+	assertTotals := func(what string, qty int, price float64) {
+		t.Helper()
+		got, err := s.Products.GetProductById(shirt.ID)
+		if err != nil {
+			t.Fatalf("%s: GetProductById: %v", what, err)
+		}
+		if got.Qty != qty || got.Price != price {
+			t.Fatalf("%s: got qty %d, price %v, want qty %d, price %v", what, got.Qty, got.Price, qty, price)
+		}
+	}
+	assertTotals("after CreateVariant", 6, 40000)
+
+	price, qty, noFile := 70000, 10, ""
+	updated, err := s.Products.UpdateVariant(shirt.ID, medium.ID, seller.ID, dto.UpdateVariantRequest{Price: &price, Qty: &qty, FileID: &noFile})
+	if err != nil {
+		t.Fatalf("UpdateVariant: %v", err)
+	}
+	if updated.Price != 70000 || updated.Qty != 10 || updated.SKU != "S-1-MR" || updated.FileID != "" {
+		t.Fatalf("UpdateVariant returned %+v", updated)
+	}
+	assertTotals("after UpdateVariant", 14, 40000)
+
+	if _, err := s.Products.UpdateVariant(simple.ID, medium.ID, seller.ID, dto.UpdateVariantRequest{Qty: &qty}); !errors.Is(err, repositories.ErrVariantNotFound) {
+		t.Fatalf("UpdateVariant under another product: got %v, want ErrVariantNotFound", err)
+	}
+	if err := s.Products.UpdateProduct(shirt.ID, seller.ID, 0, dto.UpdateProductRequest{Qty: &qty}); !errors.Is(err, repositories.ErrProductHasVariants) {
+		t.Fatalf("UpdateProduct qty of a product with variants: got %v, want ErrProductHasVariants", err)
+	}
+
+	if err := s.Products.DeleteVariant(shirt.ID, blue.ID, seller.ID); err != nil {
+		t.Fatalf("DeleteVariant: %v", err)
+	}
+	if err := s.Products.DeleteVariant(shirt.ID, blue.ID, seller.ID); !errors.Is(err, repositories.ErrVariantNotFound) {
+		t.Fatalf("DeleteVariant twice: got %v, want ErrVariantNotFound", err)
+	}
+	assertTotals("after DeleteVariant", 13, 60000)
+
+	if _, err := s.Purchases.CreatePurchase(newPurchaseRequest(map[int]int{shirt.ID: 1}), reservedUntil); !errors.Is(err, repositories.ErrVariantRequired) {
+		t.Fatalf("CreatePurchase without a variant: got %v, want ErrVariantRequired", err)
+	}
+
+	purchaseReq := newPurchaseRequest(nil)
+	purchaseReq.PurchasedItems = []dto.PurchasedItemRequest{{ProductID: fmt.Sprint(shirt.ID), VariantID: fmt.Sprint(blue.ID), Qty: 1}}
+	if _, err := s.Purchases.CreatePurchase(purchaseReq, reservedUntil); !errors.Is(err, repositories.ErrVariantNotFound) {
+		t.Fatalf("CreatePurchase of a deleted variant: got %v, want ErrVariantNotFound", err)
+	}
+	purchaseReq.PurchasedItems = []dto.PurchasedItemRequest{{ProductID: fmt.Sprint(shirt.ID), VariantID: fmt.Sprint(large.ID), Qty: 4}}
+	if _, err := s.Purchases.CreatePurchase(purchaseReq, reservedUntil); !errors.Is(err, repositories.ErrInsufficientStock) {
+		t.Fatalf("CreatePurchase over variant stock: got %v, want ErrInsufficientStock", err)
+	}
+
+	purchaseReq.PurchasedItems = []dto.PurchasedItemRequest{
+		{ProductID: fmt.Sprint(shirt.ID), VariantID: fmt.Sprint(large.ID), Qty: 2},
+		{ProductID: fmt.Sprint(shirt.ID), VariantID: fmt.Sprint(medium.ID), Qty: 1},
+	}
+	purchase, err := s.Purchases.CreatePurchase(purchaseReq, reservedUntil)
+	if err != nil {
+		t.Fatalf("CreatePurchase of variants: %v", err)
+	}
+	if purchase.TotalPrice != 2*60000+70000 || len(purchase.Items) != 2 || purchase.Items[0].VariantID != medium.ID {
+		t.Fatalf("CreatePurchase of variants returned %+v", purchase)
+	}
+
+	if _, err := s.Purchases.ConfirmPayment(purchase.PublicID, []string{file.FileID}); err != nil {
+		t.Fatalf("ConfirmPayment: %v", err)
+	}
+	assertTotals("after payment", 10, 60000)
+	got, _ := s.Products.GetProductById(shirt.ID)
+	if got.Variants[0].Qty != 9 || got.Variants[1].Qty != 1 {
+		t.Fatalf("variant stock after payment: got %d and %d, want 9 and 1", got.Variants[0].Qty, got.Variants[1].Qty)
+	}
+
+	if err := s.Products.DeleteVariant(shirt.ID, large.ID, seller.ID); !errors.Is(err, repositories.ErrVariantInUse) {
+		t.Fatalf("DeleteVariant of a purchased variant: got %v, want ErrVariantInUse", err)
+	}
+}
@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserAlreadyExists = errors.New("user already exists")
+	// ErrIdentifierLinked is returned when linking an email or phone to a
+	// user who already has one; linking never replaces a login identifier.
+	ErrIdentifierLinked = errors.New("user already has this identifier")
+)
+
+// userSelect loads a user together with its optional profile image.
+const userSelect = `
+	SELECT
+		users.id,
+		COALESCE(users.email, ''),
+		COALESCE(users.phone, ''),
+		users.password_hash,
+		users.is_admin,
+		users.bank_account_name,
+		users.bank_account_holder,
+		users.bank_account_number,
+		COALESCE(files.id::text, ''),
+		COALESCE(files.original_file_uri, ''),
+		COALESCE(files.compressed_file_uri, ''),
+		users.created_at,
+		users.updated_at
+	FROM users
+	LEFT JOIN files ON files.id = users.file_id
+`
+
+type UserRepository struct {
+	DB *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{DB: db}
+}
+
+func (r *UserRepository) CreateUser(email, phone, passwordHash string) (models.User, error) {
+	query := `
+		INSERT INTO users (email, phone, password_hash)
+		VALUES (NULLIF($1, ''), NULLIF($2, ''), $3)
+		RETURNING id
+	`
+
+	var id uint
+	err := r.DB.QueryRow(query, email, phone, passwordHash).Scan(&id)
+	if isUniqueViolation(err) {
+		return models.User{}, ErrUserAlreadyExists
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	return r.GetUserById(id)
+}
+
+func (r *UserRepository) GetUserById(id uint) (models.User, error) {
+	return r.getUser("users.id = $1", id)
+}
+
+func (r *UserRepository) GetUserByEmail(email string) (models.User, error) {
+	return r.getUser("users.email = $1", email)
+}
+
+func (r *UserRepository) GetUserByPhone(phone string) (models.User, error) {
+	return r.getUser("users.phone = $1", phone)
+}
+
+func (r *UserRepository) UpdateProfile(id uint, req dto.UpdateUserRequest) (models.User, error) {
+	query := `
+		UPDATE users
+		SET file_id = NULLIF($1, '')::uuid,
+			bank_account_name = $2,
+			bank_account_holder = $3,
+			bank_account_number = $4,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+	`
+
+	result, err := r.DB.Exec(query, req.FileID, req.BankAccountName, req.BankAccountHolder, req.BankAccountNumber, id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to update user: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return models.User{}, ErrUserNotFound
+	}
+
+	return r.GetUserById(id)
+}
+
+// LinkEmail attaches email to a user who has none, failing with
+// ErrIdentifierLinked when they already have one and with
+// ErrUserAlreadyExists when another account already uses it.
+func (r *UserRepository) LinkEmail(id uint, email string) (models.User, error) {
+	return r.linkIdentifier(id, "email", email)
+}
+
+// LinkPhone attaches phone to a user who has none, failing with
+// ErrIdentifierLinked when they already have one and with
+// ErrUserAlreadyExists when another account already uses it.
+func (r *UserRepository) LinkPhone(id uint, phone string) (models.User, error) {
+	return r.linkIdentifier(id, "phone", phone)
+}
+
+func (r *UserRepository) linkIdentifier(id uint, column, value string) (models.User, error) {
+	// column is only ever one of the fixed identifiers above, never user input
+	query := fmt.Sprintf("UPDATE users SET %[1]s = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND %[1]s IS NULL", column)
+
+	result, err := r.DB.Exec(query, value, id)
+	if isUniqueViolation(err) {
+		return models.User{}, ErrUserAlreadyExists
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to link %s: %v", column, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		// Either there is no such user or they already have the identifier
+		if _, err := r.GetUserById(id); err != nil {
+			return models.User{}, err
+		}
+		return models.User{}, ErrIdentifierLinked
+	}
+
+	return r.GetUserById(id)
+}
+
+func (r *UserRepository) getUser(condition string, arg interface{}) (models.User, error) {
+	// condition is only ever one of the fixed clauses above, never user input
+	query := userSelect + " WHERE " + condition
+
+	var user models.User
+	err := r.DB.QueryRow(query, arg).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Phone,
+		&user.PasswordHash,
+		&user.IsAdmin,
+		&user.BankAccountName,
+		&user.BankAccountHolder,
+		&user.BankAccountNumber,
+		&user.File.FileID,
+		&user.File.FileUri,
+		&user.File.FileThumbnailUri,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to get user: %v", err)
+	}
+	user.FileID = user.File.FileID
+
+	return user, nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isCheckViolation reports whether err is a Postgres check_violation.
+func isCheckViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23514"
+}
+
+// isForeignKeyViolation reports whether err is a Postgres
+// foreign_key_violation.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
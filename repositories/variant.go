@@ -0,0 +1,411 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"tutuplapak/dto"
+	"tutuplapak/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrVariantNotFound        = errors.New("variant not found")
+	ErrVariantAlreadyExists   = errors.New("a variant with these options already exists")
+	ErrVariantOptionsMismatch = errors.New("variant options must have exactly one value for each product option")
+	ErrVariantRequired        = errors.New("product has variants, a variantId is required")
+	ErrVariantInUse           = errors.New("variant has been purchased and cannot be deleted")
+	ErrProductHasVariants     = errors.New("qty and price of a product with variants are set on its variants")
+	ErrProductHasNoOptions    = errors.New("product has no options to make variants of")
+	ErrProductHasStock        = errors.New("product has stock of its own, take its qty to 0 and settle its pending purchases before adding the first variant")
+)
+
+// VariantOptionValues orders the values of a variant's options the same way
+// as the product's option names, failing with ErrVariantOptionsMismatch
+// unless there is exactly one value per name.
+func VariantOptionValues(optionNames []string, options map[string]string) ([]string, error) {
+	if len(options) != len(optionNames) {
+		return nil, fmt.Errorf("%w: expected %s", ErrVariantOptionsMismatch, strings.Join(optionNames, ", "))
+	}
+
+	values := make([]string, len(optionNames))
+	for i, name := range optionNames {
+		value, ok := options[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing %s", ErrVariantOptionsMismatch, name)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// VariantTotals returns the qty and price to store on a new product: its
+// own, or the total stock and lowest price of its variants.
+func VariantTotals(req dto.CreateProductRequest) (int, int) {
+	if len(req.Variants) == 0 {
+		return req.Qty, req.Price
+	}
+
+	qty, price := 0, req.Variants[0].Price
+	for _, variant := range req.Variants {
+		qty += variant.Qty
+		if variant.Price < price {
+			price = variant.Price
+		}
+	}
+	return qty, price
+}
+
+// CreateVariant adds a variant to a product that has options, moving the
+// product's qty and price over to its variants' totals. The first variant is
+// refused with ErrProductHasStock while the product holds stock or
+// reservations of its own, which would otherwise vanish from the ledger.
+func (r *ProductRepository) CreateVariant(productId int, userId uint, req dto.CreateVariantRequest) (models.ProductVariant, error) {
+	if err := r.checkOwner(productId, userId); err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var optionNames []string
+	var qty, reservedQty int
+	var hasVariants bool
+	err = tx.QueryRow(`
+		SELECT option_names, qty, reserved_qty, EXISTS(SELECT 1 FROM product_variants WHERE product_id = products.id)
+		FROM products WHERE id = $1 FOR UPDATE`, productId,
+	).Scan(pq.Array(&optionNames), &qty, &reservedQty, &hasVariants)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ProductVariant{}, ErrProductNotFound
+	}
+	if err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to get product options: %v", err)
+	}
+	if len(optionNames) == 0 {
+		return models.ProductVariant{}, ErrProductHasNoOptions
+	}
+	if !hasVariants && (qty != 0 || reservedQty != 0) {
+		return models.ProductVariant{}, ErrProductHasStock
+	}
+
+	values, err := VariantOptionValues(optionNames, req.Options)
+	if err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	variantId, err := insertVariant(tx, productId, userId, values, req)
+	if err != nil {
+		return models.ProductVariant{}, err
+	}
+	if err := refreshVariantTotals(tx, productId); err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	variant, err := getVariant(tx, productId, variantId)
+	if err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to commit variant: %v", err)
+	}
+
+	return variant, nil
+}
+
+// UpdateVariant applies a partial update to a variant of the product.
+func (r *ProductRepository) UpdateVariant(productId, variantId int, userId uint, req dto.UpdateVariantRequest) (models.ProductVariant, error) {
+	if err := r.checkOwner(productId, userId); err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the product first, like purchases do, before touching its totals
+	if _, err := tx.Exec("SELECT 1 FROM products WHERE id = $1 FOR UPDATE", productId); err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to lock product: %v", err)
+	}
+
+	if req.Qty != nil {
+		if err := recordVariantCorrection(tx, productId, variantId, userId, *req.Qty, "qty set"); err != nil {
+			return models.ProductVariant{}, err
+		}
+	}
+
+	query := `
+		UPDATE product_variants
+		SET sku = COALESCE($1, sku),
+			price = COALESCE($2, price),
+			qty = COALESCE($3, qty),
+			file_id = CASE WHEN $4 THEN NULLIF($5, '')::uuid ELSE file_id END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6 AND product_id = $7
+	`
+
+	fileId := ""
+	if req.FileID != nil {
+		fileId = *req.FileID
+	}
+
+	result, err := tx.Exec(query, req.SKU, req.Price, req.Qty, req.FileID != nil, fileId, variantId, productId)
+	if err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to update variant: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return models.ProductVariant{}, ErrVariantNotFound
+	}
+
+	if err := refreshVariantTotals(tx, productId); err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	variant, err := getVariant(tx, productId, variantId)
+	if err != nil {
+		return models.ProductVariant{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to commit variant: %v", err)
+	}
+
+	return variant, nil
+}
+
+// DeleteVariant removes a variant that has never been purchased.
+func (r *ProductRepository) DeleteVariant(productId, variantId int, userId uint) error {
+	if err := r.checkOwner(productId, userId); err != nil {
+		return err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT 1 FROM products WHERE id = $1 FOR UPDATE", productId); err != nil {
+		return fmt.Errorf("failed to lock product: %v", err)
+	}
+
+	if err := recordVariantCorrection(tx, productId, variantId, userId, 0, "variant deleted"); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM product_variants WHERE id = $1 AND product_id = $2", variantId, productId)
+	if isForeignKeyViolation(err) {
+		return ErrVariantInUse
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete variant: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrVariantNotFound
+	}
+
+	if err := refreshVariantTotals(tx, productId); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit variant: %v", err)
+	}
+
+	return nil
+}
+
+// insertVariant creates the variant and records its initial stock.
+func insertVariant(tx *sql.Tx, productId int, userId uint, values []string, req dto.CreateVariantRequest) (int, error) {
+	query := `
+		INSERT INTO product_variants (product_id, option_values, sku, price, qty, file_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid)
+		RETURNING id
+	`
+
+	var id int
+	err := tx.QueryRow(query, productId, pq.Array(values), req.SKU, req.Price, req.Qty, req.FileID).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, ErrVariantAlreadyExists
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create variant: %v", err)
+	}
+
+	if req.Qty != 0 {
+		movement := models.StockMovement{
+			ProductID: productId,
+			VariantID: id,
+			Reason:    models.StockMovementRestock,
+			Delta:     req.Qty,
+			QtyAfter:  req.Qty,
+			Note:      "initial stock",
+			UserID:    userId,
+		}
+		if err := insertStockMovement(tx, &movement); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// recordVariantCorrection records the movement that brings the variant's
+// stock to qty, which the caller then writes. The product must already be
+// locked.
+func recordVariantCorrection(tx *sql.Tx, productId, variantId int, userId uint, qty int, note string) error {
+	var current, reserved int
+	var allowNegative bool
+	err := tx.QueryRow(`
+		SELECT product_variants.qty, product_variants.reserved_qty, products.allow_negative_stock
+		FROM product_variants
+		JOIN products ON products.id = product_variants.product_id
+		WHERE product_variants.id = $1 AND product_variants.product_id = $2
+		FOR UPDATE OF product_variants
+	`, variantId, productId).Scan(&current, &reserved, &allowNegative)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrVariantNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock variant stock: %v", err)
+	}
+	if qty == current {
+		return nil
+	}
+	if err := CheckStockFloor(productId, current, qty, reserved, allowNegative); err != nil {
+		return err
+	}
+
+	movement := models.StockMovement{
+		ProductID: productId,
+		VariantID: variantId,
+		Reason:    models.StockMovementCorrection,
+		Delta:     qty - current,
+		QtyAfter:  qty,
+		Note:      note,
+		UserID:    userId,
+	}
+	return insertStockMovement(tx, &movement)
+}
+
+// refreshVariantTotals sets the product's qty to the stock of all its
+// variants and its price to the cheapest one, so that listing filters and
+// sorts keep working on the products table alone. A product left without
+// variants keeps its last price with no stock. The new qty may raise a low
+// stock alert.
+func refreshVariantTotals(tx *sql.Tx, productId int) error {
+	query := `
+		UPDATE products
+		SET qty = COALESCE((SELECT SUM(qty) FROM product_variants WHERE product_id = $1), 0),
+			price = COALESCE((SELECT MIN(price) FROM product_variants WHERE product_id = $1), price),
+			version = version + 1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	if _, err := tx.Exec(query, productId); err != nil {
+		return fmt.Errorf("failed to update product totals: %v", err)
+	}
+	return checkLowStock(tx, productId)
+}
+
+// variantSelect loads variants together with their optional image.
+const variantSelect = `
+	SELECT
+		product_variants.id,
+		product_variants.product_id,
+		product_variants.option_values,
+		product_variants.sku,
+		product_variants.price,
+		product_variants.qty,
+		product_variants.reserved_qty,
+		COALESCE(files.id::text, ''),
+		COALESCE(files.original_file_uri, ''),
+		COALESCE(files.compressed_file_uri, ''),
+		product_variants.created_at,
+		product_variants.updated_at
+	FROM product_variants
+	LEFT JOIN files ON files.id = product_variants.file_id
+`
+
+func scanVariant(row interface{ Scan(...interface{}) error }) (models.ProductVariant, error) {
+	var variant models.ProductVariant
+	err := row.Scan(
+		&variant.ID,
+		&variant.ProductID,
+		pq.Array(&variant.OptionValues),
+		&variant.SKU,
+		&variant.Price,
+		&variant.Qty,
+		&variant.ReservedQty,
+		&variant.File.FileID,
+		&variant.File.FileUri,
+		&variant.File.FileThumbnailUri,
+		&variant.CreatedAt,
+		&variant.UpdatedAt,
+	)
+	variant.FileID = variant.File.FileID
+	return variant, err
+}
+
+func getVariant(tx *sql.Tx, productId, variantId int) (models.ProductVariant, error) {
+	row := tx.QueryRow(variantSelect+" WHERE product_variants.id = $1 AND product_variants.product_id = $2", variantId, productId)
+	variant, err := scanVariant(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ProductVariant{}, ErrVariantNotFound
+	}
+	if err != nil {
+		return models.ProductVariant{}, fmt.Errorf("failed to get variant: %v", err)
+	}
+	return variant, nil
+}
+
+// attachVariants loads the variants of products in a single query.
+func (r *ProductRepository) attachVariants(products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(products))
+	productIds := make([]int64, len(products))
+	for i, product := range products {
+		index[product.ID] = i
+		productIds[i] = int64(product.ID)
+	}
+	sort.Slice(productIds, func(i, j int) bool { return productIds[i] < productIds[j] })
+
+	rows, err := r.DB.Query(variantSelect+" WHERE product_variants.product_id = ANY($1) ORDER BY product_variants.id", pq.Array(productIds))
+	if err != nil {
+		return fmt.Errorf("failed to load variants: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan variant: %v", err)
+		}
+		i := index[variant.ProductID]
+		products[i].Variants = append(products[i].Variants, variant)
+	}
+
+	return rows.Err()
+}
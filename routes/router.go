@@ -2,27 +2,86 @@ package routes
 
 import (
 	"database/sql"
+	"log"
+	"time"
 	"tutuplapak/config"
 	v1Handlers "tutuplapak/handlers/v1"
 	"tutuplapak/middleware"
+	"tutuplapak/repositories"
+	"tutuplapak/storage"
+	"tutuplapak/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRouter(cfg *config.Config, db *sql.DB) *gin.Engine {
+	// Tokens signed with an empty key could be forged by anyone
+	if cfg.JWTSecret == "" {
+		log.Fatal("JWT_SECRET is required")
+	}
+	utils.JWTSecret = []byte(cfg.JWTSecret)
+
 	router := gin.Default()
 	jwtMiddleware := middleware.JWTAuth()
 
 	v1Group := router.Group("/v1")
 
-	productHandler := v1Handlers.NewProductHandler(db)
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	if cfg.StorageDriver == "local" {
+		router.Static("/uploads", cfg.LocalStorageDir)
+	}
+
+	categories := repositories.NewCategoryCache(repositories.NewCategoryRepository(db), time.Minute)
+	adminMiddleware := middleware.RequireAdmin(repositories.NewUserRepository(db))
+
+	authHandler := v1Handlers.NewAuthHandler(db)
+	categoryHandler := v1Handlers.NewCategoryHandler(db, categories)
+	fileHandler := v1Handlers.NewFileHandler(db, cfg, store)
+	productHandler := v1Handlers.NewProductHandler(db, categories)
+	purchaseHandler := v1Handlers.NewPurchaseHandler(db, cfg)
+	userHandler := v1Handlers.NewUserHandler(db)
+
+	v1Group.POST("/register/email", authHandler.RegisterEmail)
+	v1Group.POST("/register/phone", authHandler.RegisterPhone)
+	v1Group.POST("/login/email", authHandler.LoginEmail)
+	v1Group.POST("/login/phone", authHandler.LoginPhone)
+
+	v1Group.POST("/file", jwtMiddleware, fileHandler.UploadFile)
+
+	userRouter := v1Group.Group("user")
+	userRouter.Use(jwtMiddleware)
+	userRouter.GET("", userHandler.GetUser)
+	userRouter.PUT("", userHandler.UpdateUser)
+	userRouter.POST("/link/email", userHandler.LinkEmail)
+	userRouter.POST("/link/phone", userHandler.LinkPhone)
+
+	v1Group.GET("/category", categoryHandler.GetCategories)
+	v1Group.GET("/category/tree", categoryHandler.GetCategoryTree)
+	v1Group.POST("/category", jwtMiddleware, adminMiddleware, categoryHandler.CreateCategory)
+	v1Group.PATCH("/category/:categoryId", jwtMiddleware, adminMiddleware, categoryHandler.UpdateCategory)
+	v1Group.DELETE("/category/:categoryId", jwtMiddleware, adminMiddleware, categoryHandler.DeleteCategory)
 
 	productRouter := v1Group.Group("product")
 	productRouter.Use(jwtMiddleware)
 	productRouter.POST("/", productHandler.CreateProduct)
 	productRouter.GET("/", productHandler.GetProducts)
+	productRouter.GET("/low-stock", productHandler.GetLowStockProducts)
+	productRouter.POST("/import", productHandler.ImportProducts)
+	productRouter.GET("/:productId", productHandler.GetProduct)
 	productRouter.PATCH("/:productId", productHandler.UpdateProduct)
 	productRouter.DELETE("/:productId", productHandler.DeleteProduct)
+	productRouter.POST("/:productId/restore", productHandler.RestoreProduct)
+	productRouter.POST("/:productId/stock", productHandler.AdjustStock)
+	productRouter.GET("/:productId/stock/history", productHandler.GetStockHistory)
+	productRouter.POST("/:productId/variant", productHandler.CreateVariant)
+	productRouter.PATCH("/:productId/variant/:variantId", productHandler.UpdateVariant)
+	productRouter.DELETE("/:productId/variant/:variantId", productHandler.DeleteVariant)
+
+	v1Group.POST("/purchase", purchaseHandler.CreatePurchase)
+	v1Group.POST("/purchase/:purchaseId", purchaseHandler.ConfirmPurchase)
 
 	return router
 }
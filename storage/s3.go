@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"tutuplapak/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Storage uploads objects to an S3 bucket. When an endpoint is configured
+// it talks path-style to that endpoint instead, which is what MinIO and
+// other S3-compatible servers expect.
+//
+// Objects are uploaded without an ACL, so the URIs it returns are only
+// readable once a bucket policy or a CDN in front of the bucket allows
+// public reads. New S3 buckets enforce bucket owner object ownership and
+// reject ACLs; PublicReadACL (AWS_S3_PUBLIC_READ_ACL) is only for buckets
+// that still accept them.
+type S3Storage struct {
+	Client        *s3.Client
+	Bucket        string
+	BaseURL       string
+	PublicReadACL bool
+}
+
+func NewS3Storage(cfg *config.Config) (*S3Storage, error) {
+	if cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("AWS_S3_BUCKET_NAME is required for the s3 storage driver")
+	}
+
+	client := s3.New(s3.Options{
+		Region:      cfg.AwsRegion,
+		Credentials: credentials.NewStaticCredentialsProvider(cfg.AwsAccessKeyId, cfg.AwsSecretAccessKey, ""),
+	}, func(o *s3.Options) {
+		if cfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	baseURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", cfg.S3Bucket, cfg.AwsRegion)
+	if cfg.S3Endpoint != "" {
+		baseURL = strings.TrimSuffix(cfg.S3Endpoint, "/") + "/" + cfg.S3Bucket
+	}
+
+	return &S3Storage{Client: client, Bucket: cfg.S3Bucket, BaseURL: baseURL, PublicReadACL: cfg.S3PublicReadACL}, nil
+}
+
+func (s *S3Storage) Upload(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(s.Bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(contentType),
+	}
+	if s.PublicReadACL {
+		input.ACL = types.ObjectCannedACLPublicRead
+	}
+
+	_, err := s.Client.PutObject(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload to s3: %v", err)
+	}
+
+	return s.BaseURL + "/" + key, nil
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"tutuplapak/config"
+)
+
+// Storage persists uploaded objects and returns the public URI they can be
+// fetched from.
+type Storage interface {
+	Upload(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error)
+}
+
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "local":
+		return NewLocalStorage(cfg.LocalStorageDir, cfg.LocalStorageBaseURL)
+	case "s3":
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"tutuplapak/config"
+)
+
+func TestLocalStorageUpload(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	s, err := NewLocalStorage(dir, "http://localhost:8080/uploads/")
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	uri, err := s.Upload(context.Background(), "ab/photo.png", bytes.NewReader([]byte("data")), 4, "image/png")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if uri != "http://localhost:8080/uploads/ab/photo.png" {
+		t.Fatalf("Upload returned %q", uri)
+	}
+	if stored, err := os.ReadFile(filepath.Join(dir, "ab", "photo.png")); err != nil || string(stored) != "data" {
+		t.Fatalf("stored object: got %q, %v", stored, err)
+	}
+}
+
+func TestS3StorageUpload(t *testing.T) {
+	for _, publicRead := range []bool{false, true} {
+		var method, path, contentType, acl string
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+			contentType, acl = r.Header.Get("Content-Type"), r.Header.Get("X-Amz-Acl")
+			body, _ = io.ReadAll(r.Body)
+		}))
+
+		s, err := NewS3Storage(&config.Config{
+			AwsRegion:          "us-east-1",
+			AwsAccessKeyId:     "key",
+			AwsSecretAccessKey: "secret",
+			S3Bucket:           "bucket",
+			S3Endpoint:         server.URL + "/",
+			S3PublicReadACL:    publicRead,
+		})
+		if err != nil {
+			t.Fatalf("NewS3Storage: %v", err)
+		}
+
+		uri, err := s.Upload(context.Background(), "photo.png", bytes.NewReader([]byte("data")), 4, "image/png")
+		server.Close()
+		if err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+		if uri != server.URL+"/bucket/photo.png" {
+			t.Fatalf("Upload returned %q, want %q", uri, server.URL+"/bucket/photo.png")
+		}
+		if method != http.MethodPut || path != "/bucket/photo.png" || contentType != "image/png" || string(body) != "data" {
+			t.Fatalf("PutObject request: got %s %s with Content-Type %q and body %q", method, path, contentType, body)
+		}
+
+		// Only buckets that accept ACLs get one
+		wantACL := ""
+		if publicRead {
+			wantACL = "public-read"
+		}
+		if acl != wantACL {
+			t.Fatalf("PutObject with PublicReadACL %v: got ACL %q, want %q", publicRead, acl, wantACL)
+		}
+	}
+}
+
+func TestS3StorageRequiresBucket(t *testing.T) {
+	if _, err := NewS3Storage(&config.Config{}); err == nil {
+		t.Fatal("NewS3Storage without a bucket: got nil error")
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxImagePixels caps the width times height of an image GenerateThumbnail
+// decodes. A few kilobytes of compressed data can declare dimensions whose
+// decoded pixels would not fit in memory.
+const MaxImagePixels = 4096 * 4096
+
+var ErrImageTooLarge = fmt.Errorf("image must not exceed %d pixels", MaxImagePixels)
+
+// GenerateThumbnail decodes a JPEG or PNG image and re-encodes it as a JPEG
+// whose longest side is at most maxDimension pixels. Images that are already
+// small enough are only recompressed, never upscaled. Transparent areas are
+// flattened onto white since JPEG has no alpha channel.
+func GenerateThumbnail(data []byte, maxDimension, quality int) ([]byte, error) {
+	// The header is enough to know the size, so check it before decoding
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxDimension || height > maxDimension {
+		if width >= height {
+			height = max(1, height*maxDimension/width)
+			width = maxDimension
+		} else {
+			width = max(1, width*maxDimension/height)
+			height = maxDimension
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"testing"
+)
+
+func encodeImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), 200, uint8(255 - x%2*255)})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encoding %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+// withDimensions rewrites the size an encoded image declares in its header,
+// leaving the pixel data for the original size behind it.
+func withDimensions(t *testing.T, format string, data []byte, width, height int) []byte {
+	t.Helper()
+	data = bytes.Clone(data)
+
+	if format == "png" {
+		// The IHDR chunk directly follows the 8 byte signature: length, type,
+		// width, height, five more bytes of data and its CRC
+		binary.BigEndian.PutUint32(data[16:], uint32(width))
+		binary.BigEndian.PutUint32(data[20:], uint32(height))
+		binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+		return data
+	}
+
+	// A baseline JPEG's SOF0 segment holds the precision, then height and
+	// width
+	sof := bytes.Index(data, []byte{0xFF, 0xC0})
+	if sof < 0 {
+		t.Fatal("no SOF0 segment in the encoded JPEG")
+	}
+	binary.BigEndian.PutUint16(data[sof+5:], uint16(height))
+	binary.BigEndian.PutUint16(data[sof+7:], uint16(width))
+	return data
+}
+
+func TestGenerateThumbnail(t *testing.T) {
+	for _, tc := range []struct {
+		name                  string
+		format                string
+		width, height         int
+		maxDimension          int
+		wantWidth, wantHeight int
+	}{
+		{"landscape png", "png", 400, 100, 256, 256, 64},
+		{"portrait png", "png", 90, 360, 120, 30, 120},
+		{"landscape jpeg", "jpeg", 300, 200, 150, 150, 100},
+		{"portrait jpeg", "jpeg", 200, 500, 100, 40, 100},
+		{"square jpeg", "jpeg", 256, 256, 256, 256, 256},
+		{"small png is not upscaled", "png", 50, 30, 256, 50, 30},
+		{"sliver keeps a pixel", "png", 1000, 1, 100, 100, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			thumbnail, err := GenerateThumbnail(encodeImage(t, tc.format, tc.width, tc.height), tc.maxDimension, 70)
+			if err != nil {
+				t.Fatalf("GenerateThumbnail: %v", err)
+			}
+
+			if contentType := http.DetectContentType(thumbnail); contentType != "image/jpeg" {
+				t.Fatalf("thumbnail content type: got %q, want image/jpeg", contentType)
+			}
+			decoded, err := jpeg.Decode(bytes.NewReader(thumbnail))
+			if err != nil {
+				t.Fatalf("decoding thumbnail: %v", err)
+			}
+			if bounds := decoded.Bounds(); bounds.Dx() != tc.wantWidth || bounds.Dy() != tc.wantHeight {
+				t.Fatalf("thumbnail of %dx%d with max %d: got %dx%d, want %dx%d",
+					tc.width, tc.height, tc.maxDimension, bounds.Dx(), bounds.Dy(), tc.wantWidth, tc.wantHeight)
+			}
+		})
+	}
+}
+
+func TestGenerateThumbnailRejected(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		format        string
+		width, height int
+		wantTooLarge  bool
+	}{
+		{"png over the pixel limit", "png", 5000, 5000, true},
+		{"jpeg over the pixel limit", "jpeg", 4097, 4096, true},
+		{"png with a zero width", "png", 0, 16, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The data behind the header is only 16x16, so decoding it at the
+			// declared size would fail rather than report ErrImageTooLarge
+			data := withDimensions(t, tc.format, encodeImage(t, tc.format, 16, 16), tc.width, tc.height)
+
+			_, err := GenerateThumbnail(data, 256, 70)
+			if err == nil {
+				t.Fatal("GenerateThumbnail: got nil error")
+			}
+			if tc.wantTooLarge && !errors.Is(err, ErrImageTooLarge) {
+				t.Fatalf("GenerateThumbnail: got %v, want ErrImageTooLarge", err)
+			}
+		})
+	}
+
+	if _, err := GenerateThumbnail([]byte("not an image"), 256, 70); err == nil || errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("GenerateThumbnail of text: got %v, want a decode error", err)
+	}
+}
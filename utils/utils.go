@@ -2,7 +2,7 @@ package utils
 
 import (
 	"errors"
-	"os"
+	"net/http"
 	"strings"
 	"time"
 
@@ -16,7 +16,9 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-var JWTSecret = []byte(os.Getenv("JWT_SECRET")) // need to update
+// JWTSecret signs and verifies tokens. routes.SetupRouter sets it from
+// config.Config.JWTSecret once the environment, .env included, is loaded.
+var JWTSecret []byte
 
 func GenerateJWT(userId uint, email string) (string, error) {
 	claims := Claims{
@@ -53,13 +55,28 @@ func ValidateJWT(tokenString string) (*Claims, error) {
 }
 
 func IsImageURI(fl validator.FieldLevel) bool {
-	uri := fl.Field().String()
-	// Check if the URI ends with common image file extensions
+	return HasImageExtension(fl.Field().String())
+}
+
+func HasImageExtension(name string) bool {
+	// Check if the name ends with common image file extensions
 	allowedExtensions := []string{".jpg", ".jpeg", ".png"}
 	for _, ext := range allowedExtensions {
-		if strings.HasSuffix(strings.ToLower(uri), ext) {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
 			return true
 		}
 	}
 	return false
 }
+
+// SniffImageContentType inspects the leading bytes of data and returns the
+// detected content type, reporting false unless it is a JPEG or PNG image.
+func SniffImageContentType(data []byte) (string, bool) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png":
+		return contentType, true
+	default:
+		return contentType, false
+	}
+}